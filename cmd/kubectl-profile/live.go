@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"pod_profiler/pkg/api/capture"
+	"pod_profiler/pkg/api/results"
+	"time"
+)
+
+// Prints a line for each container of each sample as it is captured, until stop is closed
+func showLive(samples <-chan capture.Record, stop <-chan struct{}) {
+
+	fmt.Printf("%-8s  %-48s  %-24s  %8s  %10s\n", "TIME", "POD", "CONTAINER", "CPU", "MEMORY")
+
+	for {
+		select {
+		case record := <-samples:
+			timestamp := time.Unix(record.DateStamp, 0).Format(time.TimeOnly)
+			for _, container := range record.Pod.Containers {
+				fmt.Printf("%-8s  %-48s  %-24s  %8s  %10s\n",
+					timestamp,
+					record.Pod.Name,
+					container.Name,
+					results.FormatCpu(container.Cpu),
+					results.FormatMemory(container.Memory),
+				)
+			}
+
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"pod_profiler/pkg/api/analysis"
+	"pod_profiler/pkg/api/capture"
+	"pod_profiler/pkg/api/defaults"
+	kubernetesClient "pod_profiler/pkg/api/kubernetes-client"
+	"pod_profiler/pkg/api/logging"
+	"pod_profiler/pkg/api/profiler"
+	"pod_profiler/pkg/api/report"
+	"pod_profiler/pkg/api/results"
+	"pod_profiler/pkg/api/version"
+	"syscall"
+	"time"
+)
+
+// The formats the profile can be written in, chosen by the extension of the output file
+var outputFormats = map[string]func(file *os.File, read *results.Results) error{
+	".json": func(file *os.File, read *results.Results) error {
+		return results.WriteJSON(file, read)
+	},
+	".csv": func(file *os.File, read *results.Results) error {
+		return results.WriteCSV(file, read)
+	},
+	".html": func(file *os.File, read *results.Results) error {
+		return report.WriteHTML(file, read, read.Summarise(true), read.DetectLeaks(analysis.DefaultLeakOptions))
+	},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+
+	if len(args) > 0 && args[0] == "version" {
+		fmt.Println(version.String("kubectl-profile"))
+		return 0
+	}
+
+	flags := flag.NewFlagSet("kubectl profile", flag.ExitOnError)
+	namespace := flags.String("namespace", "", "the namespace of the workload, defaults to the namespace of the kubeconfig context")
+	flags.StringVar(namespace, "n", "", "shorthand for --namespace")
+	flags.StringVar(&kubernetesClient.ConfigPath, "kubeconfig", "", "the kubeconfig file to use instead of $KUBECONFIG or ~/.kube/config")
+	flags.StringVar(&kubernetesClient.Context, "context", "", "the kubeconfig context to use instead of its current context")
+	duration := flags.Duration("for", 0, "how long to profile for, by default until interrupted")
+	output := flags.String("output", "", "the file to write the profile to, its extension chooses the format (.html, .json or .csv)")
+	flags.StringVar(output, "o", "", "shorthand for --output")
+	resultsPath := flags.String("results-path", "", "keeps the raw results files in this directory rather than a temporary one")
+	collector := flags.String("collector", defaults.COLLECTOR, "where the usage is collected from, one of metrics-server, kubelet or cadvisor")
+	logLevel := flags.String("log-level", "error", "the minimum level of the messages to log, one of debug, info or error")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: kubectl profile TYPE/NAME [FLAGS]\n\n")
+		fmt.Fprintf(flags.Output(), "Profiles the pods of a deployment, stateful set, daemon set or job from this machine, for example:\n\n")
+		fmt.Fprintf(flags.Output(), "  kubectl profile deploy/sps-api --for 10m -o report.html\n\nFlags:\n")
+		flags.PrintDefaults()
+	}
+
+	// Allow the flags to come after the workload, as they do for kubectl
+	workloads := []string{}
+	flags.Parse(args)
+	for flags.NArg() > 0 {
+		workloads = append(workloads, flags.Arg(0))
+		flags.Parse(flags.Args()[1:])
+	}
+
+	if len(workloads) != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	level, err := logging.ParseLevel(*logLevel)
+	if err != nil {
+		return fail(err)
+	}
+	logging.SetLevel(level)
+
+	// Check the output before profiling, rather than finding out it can't be written once the profile is done
+	write, known := outputFormats[filepath.Ext(*output)]
+	if *output != "" && !known {
+		return fail(fmt.Errorf("unknown output format %q, the output file must end in .html, .json or .csv", filepath.Ext(*output)))
+	}
+
+	// We always run from the user's machine, even when it happens to be a pod in a cluster
+	kubernetesClient.UseInClusterConfig = false
+
+	if *namespace == "" {
+		*namespace, err = kubernetesClient.Namespace()
+		if err != nil {
+			return fail(err)
+		}
+	}
+
+	client, err := profiler.NewK8sClient(*namespace, false)
+	if err != nil {
+		return fail(err)
+	}
+
+	target, err := resolveWorkload(client, *namespace, workloads[0])
+	if err != nil {
+		return fail(err)
+	}
+
+	directory := *resultsPath
+	if directory == "" {
+		directory, err = os.MkdirTemp("", "kubectl-profile-")
+		if err != nil {
+			return fail(err)
+		}
+		defer os.RemoveAll(directory)
+	} else if err := os.MkdirAll(directory, os.ModePerm); err != nil {
+		return fail(err)
+	}
+
+	profile, err := capture.NewWithSelector(client, directory, target.name, target.selector, capture.CollectorType(*collector), target.mode)
+	if err != nil {
+		return fail(err)
+	}
+	profile.Samples = make(chan capture.Record, 64)
+
+	profile.StartCapture()
+
+	fmt.Fprintf(os.Stderr, "Profiling %s in %s, press Ctrl+C to stop\n", workloads[0], *namespace)
+	showLive(profile.Samples, stopSignal(*duration))
+
+	profile.StopCapture()
+
+	read, err := results.Read(directory)
+	if err != nil {
+		return fail(err)
+	}
+
+	if *output == "" {
+		err = results.WriteTable(os.Stdout, read.Summarise(true))
+		if err != nil {
+			return fail(err)
+		}
+		err = results.WriteLeakTable(os.Stdout, read.DetectLeaks(analysis.DefaultLeakOptions))
+		if err != nil {
+			return fail(err)
+		}
+		return 0
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		return fail(err)
+	}
+	defer file.Close()
+
+	if err := write(file, read); err != nil {
+		return fail(err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Wrote %s\n", *output)
+	return 0
+}
+
+// Returns a channel that is closed once the duration has passed or the user interrupts the profile
+func stopSignal(duration time.Duration) <-chan struct{} {
+
+	stop := make(chan struct{})
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+
+	var timeout <-chan time.Time
+	if duration > 0 {
+		timeout = time.After(duration)
+	}
+
+	go func() {
+		select {
+		case <-interrupt:
+		case <-timeout:
+		}
+		signal.Stop(interrupt)
+		close(stop)
+	}()
+
+	return stop
+}
+
+// Prints the error and returns the exit code for it
+func fail(err error) int {
+	fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+	return 1
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"pod_profiler/pkg/api/capture"
+	kubernetesClient "pod_profiler/pkg/api/kubernetes-client"
+	"strings"
+
+	v1Meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// The workload being profiled and the selector of its pods
+type workload struct {
+	name     string
+	mode     capture.CaptureMode
+	selector labels.Selector
+}
+
+// Looks up a workload given in the TYPE/NAME form kubectl uses, a bare name is taken to be a deployment
+func resolveWorkload(client *kubernetesClient.Client, namespace, reference string) (*workload, error) {
+
+	kind, name, found := strings.Cut(reference, "/")
+	if !found {
+		kind, name = "deployment", reference
+	}
+
+	ctx := context.Background()
+	options := v1Meta.GetOptions{}
+
+	var selector *v1Meta.LabelSelector
+	mode := capture.CaptureMode_Workload
+
+	switch strings.ToLower(kind) {
+	case "deploy", "deployment", "deployments":
+		deployment, err := client.Clientset.AppsV1().Deployments(namespace).Get(ctx, name, options)
+		if err != nil {
+			return nil, err
+		}
+		selector = deployment.Spec.Selector
+
+	case "sts", "statefulset", "statefulsets":
+		statefulSet, err := client.Clientset.AppsV1().StatefulSets(namespace).Get(ctx, name, options)
+		if err != nil {
+			return nil, err
+		}
+		selector = statefulSet.Spec.Selector
+
+	case "ds", "daemonset", "daemonsets":
+		daemonSet, err := client.Clientset.AppsV1().DaemonSets(namespace).Get(ctx, name, options)
+		if err != nil {
+			return nil, err
+		}
+		selector = daemonSet.Spec.Selector
+
+	case "job", "jobs":
+		job, err := client.Clientset.BatchV1().Jobs(namespace).Get(ctx, name, options)
+		if err != nil {
+			return nil, err
+		}
+		selector = job.Spec.Selector
+		mode = capture.CaptureMode_Job
+
+	default:
+		return nil, fmt.Errorf("unable to profile %q, the type must be a deployment, stateful set, daemon set or job", kind)
+	}
+
+	podSelector, err := v1Meta.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	return &workload{name: name, mode: mode, selector: podSelector}, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"pod_profiler/pkg/api/cost"
+	"pod_profiler/pkg/api/defaults/cloud"
+	kubernetesClient "pod_profiler/pkg/api/kubernetes-client"
+	"pod_profiler/pkg/api/results"
+	"time"
+)
+
+// The cost report formats
+var costFormats = map[string]func(writer io.Writer, report *cost.Report) error{
+	"text":     cost.WriteTable,
+	"markdown": cost.WriteMarkdown,
+	"json":     cost.WriteJSON,
+}
+
+// The format chosen by the extension of the output file when no format is given
+var costExtensions = map[string]string{
+	".md":   "markdown",
+	".json": "json",
+}
+
+// Estimates what each workload costs with its current requests, with its actual usage and with the recommended requests
+func estimateCost(args []string) int {
+
+	opts := &options{}
+	flags := flag.NewFlagSet("cost", flag.ExitOnError)
+	opts.registerClusterFlags(flags)
+	opts.registerConfigFlags(flags)
+	pricingFile := flags.String("pricing", os.Getenv("PROFILER_PRICING_FILE"), "the JSON or YAML file of the vCPU-hour and GB-hour prices of each platform")
+	platform := flags.String("platform", "", "the platform whose prices are used, by default the platform of the kubeconfig's cluster")
+	cpuType := flags.String("cpu-type", "", "the CPU type whose prices are used, for platforms such as coreweave that price them separately")
+	period := flags.Duration("period", 730*time.Hour, "the period the costs are estimated over, by default a month")
+	headroom := flags.Float64("headroom", 0.2, "the fraction added on top of the observed usage by the recommendations, such as 0.2 for 20%")
+	includeSidecars := flags.Bool("include-sidecars", false, "includes sidecar containers in the costs")
+	format := flags.String("format", "", "the format of the estimate, one of text, markdown or json. Defaults to the format of the output's extension, or text")
+	output := flags.String("output", "-", "the file to write the estimate to, - writes to stdout")
+	setUsage(flags, "", "Estimates the cost of each workload's requests and usage, and the savings from applying the recommendations.")
+	flags.Parse(args)
+
+	if err := opts.apply(); err != nil {
+		return fail(err)
+	}
+
+	if *pricingFile == "" {
+		return fail(fmt.Errorf("--pricing is required"))
+	}
+	if *period <= 0 {
+		return fail(fmt.Errorf("--period must be greater than 0"))
+	}
+	if *headroom < 0 {
+		return fail(fmt.Errorf("headroom can not be negative"))
+	}
+
+	if *format == "" {
+		*format = "text"
+		if extensionFormat, known := costExtensions[filepath.Ext(*output)]; known {
+			*format = extensionFormat
+		}
+	}
+	if _, known := costFormats[*format]; !known {
+		return fail(fmt.Errorf("unknown format %q, must be one of text, markdown or json", *format))
+	}
+
+	pricing, err := cost.LoadPricing(*pricingFile)
+	if err != nil {
+		return fail(err)
+	}
+
+	path, err := opts.results()
+	if err != nil {
+		return fail(err)
+	}
+
+	read, err := results.Read(path)
+	if err != nil {
+		return fail(err)
+	}
+
+	pricedPlatform, err := costPlatform(*platform)
+	if err != nil {
+		return fail(err)
+	}
+
+	recommendations := results.Recommend(read.Summarise(*includeSidecars), *headroom)
+	report, err := cost.Estimate(read, recommendations, pricing, pricedPlatform, *cpuType, *period)
+	if err != nil {
+		return fail(err)
+	}
+
+	var writer io.Writer = os.Stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fail(err)
+		}
+		defer file.Close()
+		writer = file
+	}
+
+	err = costFormats[*format](writer, report)
+	if err != nil {
+		return fail(err)
+	}
+
+	return 0
+}
+
+// Returns the platform with the given name, or detects the platform of the cluster when no name is given
+func costPlatform(name string) (cloud.CloudPlatformType, error) {
+
+	if name != "" {
+		return cloud.ParsePlatform(name)
+	}
+
+	client, err := kubernetesClient.NewClient()
+	if err != nil {
+		return "", fmt.Errorf("unable to detect the platform, set --platform: %s", err.Error())
+	}
+
+	return client.Platform(), nil
+}
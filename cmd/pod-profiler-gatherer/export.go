@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"pod_profiler/pkg/api/results"
+)
+
+// Writes the results directory as a single document, so it can be loaded into other tools
+func export(args []string) int {
+
+	opts := &options{}
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	opts.registerConfigFlags(flags)
+	format := flags.String("format", "json", "the format of the document, json or csv")
+	output := flags.String("output", "-", "the file to write the document to, - writes to stdout")
+	setUsage(flags, "", "Writes the results as a single JSON document, or as a CSV file with a row per container sample.")
+	flags.Parse(args)
+
+	if err := opts.apply(); err != nil {
+		return fail(err)
+	}
+
+	if *format != "json" && *format != "csv" {
+		return fail(fmt.Errorf("unknown format %q, must be json or csv", *format))
+	}
+
+	path, err := opts.results()
+	if err != nil {
+		return fail(err)
+	}
+
+	read, err := results.Read(path)
+	if err != nil {
+		return fail(err)
+	}
+
+	var writer io.Writer = os.Stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fail(err)
+		}
+		defer file.Close()
+		writer = file
+	}
+
+	if *format == "csv" {
+		err = results.WriteCSV(writer, read)
+	} else {
+		err = results.WriteJSON(writer, read)
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	return 0
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"pod_profiler/pkg/api/config"
+	kubernetesClient "pod_profiler/pkg/api/kubernetes-client"
+	"pod_profiler/pkg/api/logging"
+	"time"
+)
+
+// The flags shared by the subcommands, each subcommand registers the groups it uses
+type options struct {
+	kubeconfig  string
+	context     string
+	namespace   string
+	config      string
+	resultsPath string
+	logLevel    string
+	qps         float64
+	burst       int
+	timeout     time.Duration
+	userAgent   string
+
+	// Set once the cluster flags are registered, the subcommands that don't connect to a cluster leave the client settings alone
+	cluster bool
+}
+
+// Registers the flags that choose the cluster and namespace to connect to
+func (opts *options) registerClusterFlags(flags *flag.FlagSet) {
+	opts.cluster = true
+	flags.StringVar(&opts.kubeconfig, "kubeconfig", "", "the kubeconfig file to use instead of the in-cluster config, $KUBECONFIG or ~/.kube/config")
+	flags.StringVar(&opts.context, "context", "", "the kubeconfig context to use instead of its current context")
+	flags.StringVar(&opts.namespace, "namespace", "", "the namespace to profile, overrides the config")
+	flags.Float64Var(&opts.qps, "kube-qps", float64(kubernetesClient.QPS), "the number of requests per second allowed to the API server")
+	flags.IntVar(&opts.burst, "kube-burst", kubernetesClient.Burst, "the number of requests allowed to the API server in a burst")
+	flags.DurationVar(&opts.timeout, "kube-timeout", kubernetesClient.Timeout, "the timeout of each request to the API server, zero for no timeout. This also limits the informer watches")
+	flags.StringVar(&opts.userAgent, "user-agent", kubernetesClient.UserAgent, "the user agent sent to the API server")
+}
+
+// Registers the flags that choose the config, the results directory and the log level
+func (opts *options) registerConfigFlags(flags *flag.FlagSet) {
+	flags.StringVar(&opts.config, "config", "", "the config file to use instead of searching for config.json or config.yaml")
+	flags.StringVar(&opts.resultsPath, "results-path", "", "the results directory, overrides the config. Commands that read results also accept a JSON document written by export")
+	flags.StringVar(&opts.logLevel, "log-level", "info", "the minimum level of the messages to log, one of debug, info or error")
+}
+
+// Applies the flags that were set to the packages they configure
+func (opts *options) apply() error {
+
+	level, err := logging.ParseLevel(opts.logLevel)
+	if err != nil {
+		return err
+	}
+	logging.SetLevel(level)
+
+	if opts.kubeconfig != "" {
+		kubernetesClient.ConfigPath = opts.kubeconfig
+		kubernetesClient.UseInClusterConfig = false
+	}
+
+	if opts.context != "" {
+		kubernetesClient.Context = opts.context
+		kubernetesClient.UseInClusterConfig = false
+	}
+
+	if opts.cluster {
+		kubernetesClient.QPS = float32(opts.qps)
+		kubernetesClient.Burst = opts.burst
+		kubernetesClient.Timeout = opts.timeout
+		kubernetesClient.UserAgent = opts.userAgent
+	}
+
+	if opts.config != "" {
+		config.ConfigFile = opts.config
+	}
+
+	if opts.namespace != "" {
+		config.Overrides["namespace"] = opts.namespace
+	}
+
+	if opts.resultsPath != "" {
+		config.Overrides["resultspath"] = opts.resultsPath
+	}
+
+	return nil
+}
+
+// Returns the results directory from the flag, or from the config if the flag isn't set
+func (opts *options) results() (string, error) {
+
+	if opts.resultsPath != "" {
+		return opts.resultsPath, nil
+	}
+
+	loaded, err := config.Load(false)
+	if err != nil {
+		return "", fmt.Errorf("unable to find the results directory, set --results-path or --config: %s", err.Error())
+	}
+
+	return loaded.ResultsPath, nil
+}
+
+// Sets the usage of a subcommand's flags
+func setUsage(flags *flag.FlagSet, arguments, description string) {
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s %s [FLAGS]%s\n\n%s\n\nFlags:\n", os.Args[0], flags.Name(), arguments, description)
+		flags.PrintDefaults()
+	}
+}
+
+// Prints the error and returns the exit code for it
+func fail(err error) int {
+	fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+	return 1
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"os/signal"
+	"pod_profiler/pkg/api/logging"
+	"pod_profiler/pkg/api/profiler"
+	"syscall"
+)
+
+// Captures the usage of the configured targets until the process is stopped
+func gather(args []string) int {
+
+	opts := &options{}
+	flags := flag.NewFlagSet("gather", flag.ExitOnError)
+	opts.registerClusterFlags(flags)
+	opts.registerConfigFlags(flags)
+	setUsage(flags, "", "Captures the usage of the configured targets until the process is stopped.")
+	flags.Parse(args)
+
+	if err := opts.apply(); err != nil {
+		return fail(err)
+	}
+
+	profiler, err := profiler.New()
+	if err != nil {
+		logging.Error().Printf("error initialising config: %s", err.Error())
+		return 1
+	}
+
+	// Stop the captures when the pod is terminated, such as when its node is drained, so their files are closed
+	// and a standby replica can take over before we exit
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	go profiler.Start()
+	for {
+		select {
+		case err := <-profiler.Errors:
+			logging.Error().Printf("%s\n", err.Error())
+
+		case <-stop:
+			logging.Info().Println("Stopping capture")
+			profiler.Stop()
+			return 0
+		}
+	}
+}
@@ -1,22 +1,56 @@
 package main
 
 import (
-	"log"
-	"pod_profiler/pkg/api/profiler"
+	"fmt"
+	"os"
+	"strings"
 )
 
+// A subcommand of the gatherer, run returns the exit code
+type command struct {
+	name        string
+	description string
+	run         func(args []string) int
+}
+
+var commands = []command{
+	{"gather", "captures the usage of the configured targets, this is the default when no subcommand is given", gather},
+	{"watch", "shows a continuously refreshing table of the usage of the configured targets", watch},
+	{"report", "prints a summary of the usage in a results directory", report},
+	{"recommend", "suggests requests and limits for each container from its usage", recommend},
+	{"cost", "estimates the cost of each workload and the savings from the recommendations", estimateCost},
+	{"export", "writes the results as a single JSON or CSV document", export},
+	{"validate-config", "checks config files against the config schema", validateConfig},
+	{"version", "prints the version", printVersion},
+}
+
 func main() {
 
-	profiler, err := profiler.New()
-	if err != nil {
-		log.Default().Fatalf("error initialising config: %s", err.Error())
+	// The container runs the gatherer without arguments, so gathering stays the default
+	if len(os.Args) < 2 || strings.HasPrefix(os.Args[1], "-") {
+		os.Exit(gather(os.Args[1:]))
 	}
 
-	go profiler.Start()
-	for err := range profiler.Errors {
-		log.Printf("%s\n", err.Error())
+	for _, command := range commands {
+		if command.name == os.Args[1] {
+			os.Exit(command.run(os.Args[2:]))
+		}
 	}
 
-	log.Default().Println("Stopping capture")
+	if os.Args[1] == "help" {
+		usage()
+		os.Exit(0)
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", os.Args[1])
+	usage()
+	os.Exit(2)
+}
 
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s COMMAND [FLAGS]\n\nCommands:\n", os.Args[0])
+	for _, command := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", command.name, command.description)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun %s COMMAND -h for the flags of a command.\n", os.Args[0])
 }
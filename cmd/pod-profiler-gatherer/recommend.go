@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"pod_profiler/pkg/api/results"
+	"text/tabwriter"
+)
+
+// Prints the suggested requests and limits of each container alongside the current ones
+func recommend(args []string) int {
+
+	opts := &options{}
+	flags := flag.NewFlagSet("recommend", flag.ExitOnError)
+	opts.registerConfigFlags(flags)
+	headroom := flags.Float64("headroom", 0.2, "the fraction added on top of the observed usage, such as 0.2 for 20%")
+	includeSidecars := flags.Bool("include-sidecars", false, "includes sidecar containers in the recommendations")
+	setUsage(flags, "", "Suggests requests that cover the 95th percentile of each container's usage and limits that cover its peak.")
+	flags.Parse(args)
+
+	if err := opts.apply(); err != nil {
+		return fail(err)
+	}
+
+	if *headroom < 0 {
+		return fail(fmt.Errorf("headroom can not be negative"))
+	}
+
+	path, err := opts.results()
+	if err != nil {
+		return fail(err)
+	}
+
+	read, err := results.Read(path)
+	if err != nil {
+		return fail(err)
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "TARGET\tCONTAINER\tCPU REQUEST\tCPU LIMIT\tMEMORY REQUEST\tMEMORY LIMIT")
+
+	// Show the change from the current value, or just the recommendation if nothing is set
+	change := func(current, recommended *int64, format func(int64) string) string {
+		if current == nil {
+			return results.FormatResource(recommended, format)
+		}
+		return fmt.Sprintf("%s -> %s", format(*current), results.FormatResource(recommended, format))
+	}
+
+	for _, recommendation := range results.Recommend(read.Summarise(*includeSidecars), *headroom) {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			recommendation.Target,
+			recommendation.Container,
+			change(recommendation.Requests.Cpu, recommendation.RecommendedRequests.Cpu, results.FormatCpu),
+			change(recommendation.Limits.Cpu, recommendation.RecommendedLimits.Cpu, results.FormatCpu),
+			change(recommendation.Requests.Memory, recommendation.RecommendedRequests.Memory, results.FormatMemory),
+			change(recommendation.Limits.Memory, recommendation.RecommendedLimits.Memory, results.FormatMemory),
+		)
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fail(err)
+	}
+
+	return 0
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"pod_profiler/pkg/api/analysis"
+	htmlReport "pod_profiler/pkg/api/report"
+	"pod_profiler/pkg/api/results"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// The exit code of the report when --fail-on-leak is set and a container is leaking memory
+const exitCode_Leaking = 3
+
+// The report formats, each is a rendering of the same summaries and leaks
+var reportFormats = map[string]func(writer io.Writer, read *results.Results, summaries []results.ContainerSummary, leaks []results.Leak) error{
+	"text": func(writer io.Writer, read *results.Results, summaries []results.ContainerSummary, leaks []results.Leak) error {
+		if err := results.WriteTable(writer, summaries); err != nil {
+			return err
+		}
+		return results.WriteLeakTable(writer, leaks)
+	},
+	"html": htmlReport.WriteHTML,
+	"markdown": func(writer io.Writer, read *results.Results, summaries []results.ContainerSummary, leaks []results.Leak) error {
+		return results.WriteMarkdown(writer, summaries, leaks)
+	},
+	"json": func(writer io.Writer, read *results.Results, summaries []results.ContainerSummary, leaks []results.Leak) error {
+		return results.WriteSummaryJSON(writer, read.Path, summaries, leaks)
+	},
+}
+
+// The format chosen by the extension of the output file when no format is given
+var reportExtensions = map[string]string{
+	".html": "html",
+	".md":   "markdown",
+	".json": "json",
+}
+
+// Summarises the usage of each container of each target in the results directory, as a table in the terminal,
+// a self-contained HTML report, or Markdown and JSON summaries for CI pipelines. Containers whose memory keeps
+// growing are flagged as leaking
+func report(args []string) int {
+
+	opts := &options{}
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	opts.registerConfigFlags(flags)
+	includeSidecars := flags.Bool("include-sidecars", false, "includes sidecar containers in the report")
+	format := flags.String("format", "", "the format of the report, one of text, html, markdown or json. Defaults to the format of the output's extension, or text")
+	output := flags.String("output", "-", "the file to write the report to, - writes to stdout")
+	leakOptions := analysis.DefaultLeakOptions
+	flags.Float64Var(&leakOptions.MinConfidence, "leak-confidence", leakOptions.MinConfidence, "the confidence, between 0 and 1, the memory must grow steadily with to be flagged as a leak")
+	leakGrowth := flags.String("leak-min-growth", "1Mi", "the memory growth per hour below which a container is not flagged as leaking")
+	flags.DurationVar(&leakOptions.MinDuration, "leak-min-duration", leakOptions.MinDuration, "how long the memory must have been growing for to be flagged as a leak")
+	failOnLeak := flags.Bool("fail-on-leak", false, fmt.Sprintf("exits with %d when a container is leaking memory", exitCode_Leaking))
+	setUsage(flags, "", "Summarises the usage of each container in the results directory, or in a results document written by export.")
+	flags.Parse(args)
+
+	if err := opts.apply(); err != nil {
+		return fail(err)
+	}
+
+	if *format == "" {
+		*format = "text"
+		if extensionFormat, known := reportExtensions[filepath.Ext(*output)]; known {
+			*format = extensionFormat
+		}
+	}
+	if _, known := reportFormats[*format]; !known {
+		return fail(fmt.Errorf("unknown format %q, must be one of text, html, markdown or json", *format))
+	}
+
+	if leakOptions.MinConfidence < 0 || leakOptions.MinConfidence > 1 {
+		return fail(fmt.Errorf("--leak-confidence must be between 0 and 1"))
+	}
+	growth, err := resource.ParseQuantity(*leakGrowth)
+	if err != nil {
+		return fail(fmt.Errorf("invalid --leak-min-growth %q: %s", *leakGrowth, err.Error()))
+	}
+	leakOptions.MinGrowth = float64(growth.Value())
+
+	path, err := opts.results()
+	if err != nil {
+		return fail(err)
+	}
+
+	read, err := results.Read(path)
+	if err != nil {
+		return fail(err)
+	}
+
+	var writer io.Writer = os.Stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fail(err)
+		}
+		defer file.Close()
+		writer = file
+	}
+
+	leaks := read.DetectLeaks(leakOptions)
+
+	err = reportFormats[*format](writer, read, read.Summarise(*includeSidecars), leaks)
+	if err != nil {
+		return fail(err)
+	}
+
+	if *failOnLeak && len(results.Leaking(leaks)) > 0 {
+		return exitCode_Leaking
+	}
+
+	return 0
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"pod_profiler/pkg/api/config"
+)
+
+// Checks each of the given config files against the config schema without connecting to a cluster.
+// Returns the exit code, which is non-zero if any of the files are invalid
+func validateConfig(args []string) int {
+
+	flags := flag.NewFlagSet("validate-config", flag.ExitOnError)
+	printSchema := flags.Bool("schema", false, "prints the JSON Schema for the config file and exits")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s validate-config [--schema] FILE...\n\nUse - as the FILE to read the config from stdin.\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *printSchema {
+		os.Stdout.Write(config.Schema)
+		return 0
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	exitCode := 0
+	for _, filename := range flags.Args() {
+		err := config.ValidateFile(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: invalid\n%s\n", filename, err.Error())
+			exitCode = 1
+			continue
+		}
+
+		fmt.Printf("%s: valid\n", filename)
+	}
+
+	return exitCode
+}
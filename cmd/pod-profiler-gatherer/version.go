@@ -0,0 +1,11 @@
+package main
+
+import (
+	"fmt"
+	"pod_profiler/pkg/api/version"
+)
+
+func printVersion(args []string) int {
+	fmt.Println(version.String("pod-profiler-gatherer"))
+	return 0
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"pod_profiler/pkg/api/capture"
+	"pod_profiler/pkg/api/config"
+	"pod_profiler/pkg/api/defaults"
+	kubernetesClient "pod_profiler/pkg/api/kubernetes-client"
+	"pod_profiler/pkg/api/logging"
+	"pod_profiler/pkg/api/profiler"
+	"pod_profiler/pkg/api/results"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	v1Core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// The current usage of a container along with its range since the watch started
+type watchedContainer struct {
+	target    string
+	pod       string
+	container string
+	cpu       int64
+	memory    int64
+	minCpu    int64
+	maxCpu    int64
+	minMemory int64
+	maxMemory int64
+	requests  results.Resources
+	limits    results.Resources
+	restarts  int32
+	seen      bool
+}
+
+// The orders the table can be sorted in, the numeric columns are sorted highest first
+var watchSorts = map[string]func(a, b *watchedContainer) bool{
+	"name": func(a, b *watchedContainer) bool {
+		return a.key() < b.key()
+	},
+	"cpu": func(a, b *watchedContainer) bool {
+		return a.cpu > b.cpu
+	},
+	"memory": func(a, b *watchedContainer) bool {
+		return a.memory > b.memory
+	},
+	"restarts": func(a, b *watchedContainer) bool {
+		return a.restarts > b.restarts
+	},
+}
+
+// Shows a continuously refreshing table of the usage of the containers of the configured targets
+func watch(args []string) int {
+
+	opts := &options{}
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	opts.registerClusterFlags(flags)
+	opts.registerConfigFlags(flags)
+
+	// The table is drawn over the log output, so only log errors unless asked otherwise
+	opts.logLevel = "error"
+	flags.Lookup("log-level").DefValue = opts.logLevel
+
+	interval := flags.Duration("interval", defaults.POLL_INTERVAL, "how often the table is refreshed")
+	sortBy := flags.String("sort", "name", "the column to sort by, one of name, cpu, memory or restarts")
+	filter := flags.String("filter", "", "only shows the containers whose target/pod/container contains this text")
+	once := flags.Bool("once", false, "prints the table once rather than refreshing it")
+	setUsage(flags, "", "Shows the current usage of each container of the configured targets, refreshing the table until interrupted.")
+	flags.Parse(args)
+
+	if err := opts.apply(); err != nil {
+		return fail(err)
+	}
+
+	less, known := watchSorts[*sortBy]
+	if !known {
+		return fail(fmt.Errorf("unknown sort %q, must be one of name, cpu, memory or restarts", *sortBy))
+	}
+
+	loaded, err := config.Load(false)
+	if err != nil {
+		return fail(err)
+	}
+
+	client, err := profiler.NewK8sClient(loaded.Namespace, false)
+	if err != nil {
+		return fail(err)
+	}
+
+	collector, err := capture.NewCollector(client, capture.CollectorType(loaded.Collector), *interval)
+	if err != nil {
+		return fail(err)
+	}
+
+	targets := append(append([]string{}, loaded.PodLabels...), loaded.Jobs...)
+	watched := map[string]*watchedContainer{}
+	started := time.Now()
+
+	for {
+		err := refresh(client, collector, targets, watched)
+		if err != nil {
+			logging.Error().Printf("error: %s\n", err.Error())
+		}
+
+		rows := []*watchedContainer{}
+		for _, container := range watched {
+			if container.seen && strings.Contains(container.key(), *filter) {
+				rows = append(rows, container)
+			}
+		}
+		sort.SliceStable(rows, func(i, j int) bool {
+			return less(rows[i], rows[j])
+		})
+
+		if !*once {
+			// Move to the top left and clear the screen
+			fmt.Print("\033[H\033[2J")
+			fmt.Printf("%s in %s, watching for %s, refreshing every %s\n\n", loaded.Collector, loaded.Namespace, time.Since(started).Round(time.Second), *interval)
+		}
+
+		if err := writeWatchTable(os.Stdout, rows); err != nil {
+			return fail(err)
+		}
+
+		if *once {
+			return 0
+		}
+		time.Sleep(*interval)
+	}
+}
+
+// Collects the current usage of every pod of the targets and updates the watched containers.
+// Containers that are no longer running are removed
+func refresh(client *kubernetesClient.Client, collector *capture.Collector, targets []string, watched map[string]*watchedContainer) error {
+
+	type sample struct {
+		target string
+		pod    *v1Core.Pod
+		record *capture.Record
+		err    error
+	}
+
+	samples := make(chan sample)
+	wait := sync.WaitGroup{}
+
+	// The pods being polled keyed by target/pod, the containers of any other pod are no longer shown
+	polled := map[string]bool{}
+
+	for _, target := range targets {
+		selector, err := labels.Parse(defaults.KUBERNETES_NAME_LABEL + "=" + target)
+		if err != nil {
+			return err
+		}
+
+		pods, err := client.Cache.Pod().List(selector)
+		if err != nil {
+			return err
+		}
+
+		// Collect the pods in parallel, the kubelet and cadvisor collectors make a request per node
+		for _, pod := range pods {
+			if pod.Status.Phase != v1Core.PodRunning {
+				continue
+			}
+
+			polled[target+"/"+pod.GetName()] = true
+
+			wait.Add(1)
+			go func(target string, pod *v1Core.Pod) {
+				defer wait.Done()
+				record, err := collector.Collect(pod)
+				samples <- sample{target, pod, record, err}
+			}(target, pod)
+		}
+	}
+
+	go func() {
+		wait.Wait()
+		close(samples)
+	}()
+
+	errors := []string{}
+
+	for sample := range samples {
+		if sample.err != nil {
+			errors = append(errors, sample.err.Error())
+			continue
+		}
+		if sample.record == nil {
+			continue
+		}
+
+		// The pod is shared by the cache, so its container lists are read rather than appended to
+		specs := map[string]v1Core.ResourceRequirements{}
+		for _, containers := range [][]v1Core.Container{sample.pod.Spec.InitContainers, sample.pod.Spec.Containers} {
+			for _, container := range containers {
+				specs[container.Name] = container.Resources
+			}
+		}
+
+		restarts := map[string]int32{}
+		for _, statuses := range [][]v1Core.ContainerStatus{sample.pod.Status.InitContainerStatuses, sample.pod.Status.ContainerStatuses} {
+			for _, status := range statuses {
+				restarts[status.Name] = status.RestartCount
+			}
+		}
+
+		for _, usage := range sample.record.Pod.Containers {
+			container := &watchedContainer{target: sample.target, pod: sample.pod.GetName(), container: usage.Name}
+			if existing, exists := watched[container.key()]; exists {
+				container = existing
+			}
+			watched[container.key()] = container
+
+			container.update(usage.Cpu, usage.Memory)
+			container.requests = resources(specs[usage.Name].Requests)
+			container.limits = resources(specs[usage.Name].Limits)
+			container.restarts = restarts[usage.Name]
+		}
+	}
+
+	// A pod that couldn't be sampled this time keeps its last usage
+	for key, container := range watched {
+		if !polled[container.target+"/"+container.pod] {
+			delete(watched, key)
+		}
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("unable to collect %d pods: %s", len(errors), errors[0])
+	}
+	return nil
+}
+
+func (container *watchedContainer) key() string {
+	return container.target + "/" + container.pod + "/" + container.container
+}
+
+// Records the latest usage and widens the range seen since the watch started
+func (container *watchedContainer) update(cpu, memory int64) {
+	container.cpu = cpu
+	container.memory = memory
+
+	if !container.seen {
+		container.minCpu, container.maxCpu = cpu, cpu
+		container.minMemory, container.maxMemory = memory, memory
+		container.seen = true
+		return
+	}
+
+	container.minCpu = min(container.minCpu, cpu)
+	container.maxCpu = max(container.maxCpu, cpu)
+	container.minMemory = min(container.minMemory, memory)
+	container.maxMemory = max(container.maxMemory, memory)
+}
+
+// Returns the cpu in millicores and memory in bytes of a container's requests or limits
+func resources(list v1Core.ResourceList) results.Resources {
+	resources := results.Resources{}
+	if cpu, exists := list[v1Core.ResourceCPU]; exists {
+		value := cpu.MilliValue()
+		resources.Cpu = &value
+	}
+	if memory, exists := list[v1Core.ResourceMemory]; exists {
+		value := memory.Value()
+		resources.Memory = &value
+	}
+	return resources
+}
+
+// Formats the usage as a percentage of a request or limit, which is shown as a dash when it isn't set
+func percentage(usage int64, of *int64) string {
+	if of == nil || *of == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", float64(usage)*100/float64(*of))
+}
+
+func writeWatchTable(writer io.Writer, rows []*watchedContainer) error {
+
+	table := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "TARGET\tPOD\tCONTAINER\tCPU\t%REQUEST\t%LIMIT\tMIN\tMAX\tMEMORY\t%REQUEST\t%LIMIT\tMIN\tMAX\tRESTARTS")
+
+	for _, row := range rows {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\n",
+			row.target,
+			row.pod,
+			row.container,
+			results.FormatCpu(row.cpu),
+			percentage(row.cpu, row.requests.Cpu),
+			percentage(row.cpu, row.limits.Cpu),
+			results.FormatCpu(row.minCpu),
+			results.FormatCpu(row.maxCpu),
+			results.FormatMemory(row.memory),
+			percentage(row.memory, row.requests.Memory),
+			percentage(row.memory, row.limits.Memory),
+			results.FormatMemory(row.minMemory),
+			results.FormatMemory(row.maxMemory),
+			row.restarts,
+		)
+	}
+
+	return table.Flush()
+}
@@ -0,0 +1,344 @@
+package alert
+
+import (
+	"fmt"
+	"pod_profiler/pkg/api/analysis"
+	"pod_profiler/pkg/api/capture"
+	"pod_profiler/pkg/api/config"
+	"pod_profiler/pkg/api/defaults"
+	"pod_profiler/pkg/api/logging"
+	"reflect"
+	"sync"
+
+	v1Core "k8s.io/api/core/v1"
+)
+
+// The rule name of the alerts raised for spikes
+const AnomalyRule = "anomaly"
+
+// The smallest rise above the moving average that is a spike, so an idle container using a few more millicores
+// or a container allocating a little memory isn't reported
+var minimumSpike = map[string]float64{
+	"cpu":    10,
+	"memory": 1024 * 1024,
+}
+
+// Alert is raised when a container's usage spikes or stays above one of the rules' thresholds
+type Alert struct {
+	DateStamp int64  `json:"datestamp"`
+	Target    string `json:"target"`
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+
+	// The name of the rule that fired, or anomaly for a spike
+	Rule   string `json:"rule"`
+	Metric string `json:"metric"`
+
+	// The usage and the threshold it crossed, in millicores or bytes
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+
+	Message string `json:"message"`
+}
+
+// Returns the alert as an event of its pod, so it is kept in the results alongside restarts and evictions
+func (alert Alert) Event() capture.Event {
+	return capture.Event{
+		DateStamp: alert.DateStamp,
+		Pod:       alert.Pod,
+		Container: alert.Container,
+		Type:      capture.EventType_Alert,
+		Reason:    alert.Rule,
+		Message:   alert.Message,
+	}
+}
+
+// The state of the rules and moving averages of one container of one pod
+type containerState struct {
+	seen int64
+
+	// The moving average of each metric and whether it is currently spiking
+	averages map[string]*analysis.EWMA
+	spiking  map[string]bool
+
+	// When the usage went above each rule's threshold, and whether the rule has fired since
+	breached map[string]int64
+	fired    map[string]bool
+}
+
+// Monitor checks the records of every capture against the alert config and passes the alerts it raises to the notifiers.
+// Each alert is only raised once until the usage drops back below the threshold
+type Monitor struct {
+	alerts    config.Alerts
+	notifiers []Notifier
+
+	// The state of each container, keyed by target, pod and container
+	containers map[string]*containerState
+
+	// The most recent alerts, oldest first
+	recent []Alert
+
+	// The datestamp of the last time the state of containers that are no longer captured was removed
+	pruned int64
+
+	mutex sync.Mutex
+}
+
+// Creates a monitor of the given alert config, which is expected to have been validated
+func NewMonitor(alerts config.Alerts) *Monitor {
+	monitor := &Monitor{containers: map[string]*containerState{}}
+	monitor.Configure(alerts)
+	return monitor
+}
+
+// Applies a new alert config. The state of the containers is only reset if the anomaly detection or rules changed
+func (monitor *Monitor) Configure(alerts config.Alerts) {
+
+	notifiers := []Notifier{}
+	for _, notifierConfig := range alerts.Notifiers {
+		notifier, err := NewNotifier(notifierConfig)
+		if err != nil {
+			logging.Error().Printf("error: %s\n", err.Error())
+			continue
+		}
+		notifiers = append(notifiers, notifier)
+	}
+
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+
+	if !reflect.DeepEqual(monitor.alerts.Anomaly, alerts.Anomaly) || !reflect.DeepEqual(monitor.alerts.Rules, alerts.Rules) {
+		monitor.containers = map[string]*containerState{}
+	}
+
+	monitor.alerts = alerts
+	monitor.notifiers = notifiers
+}
+
+// Checks each container of the record and returns the alerts it raises. The pod is used for the requests and limits
+// of rules relative to them, without it those rules are skipped
+func (monitor *Monitor) Check(target string, record capture.Record, pod *v1Core.Pod) []Alert {
+
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+
+	if !monitor.alerts.Enabled() {
+		return nil
+	}
+
+	raised := []Alert{}
+
+	for _, container := range record.Pod.Containers {
+
+		key := target + "/" + record.Pod.Name + "/" + container.Name
+		state, exists := monitor.containers[key]
+		if !exists {
+			state = &containerState{
+				averages: map[string]*analysis.EWMA{},
+				spiking:  map[string]bool{},
+				breached: map[string]int64{},
+				fired:    map[string]bool{},
+			}
+			monitor.containers[key] = state
+		}
+		state.seen = record.DateStamp
+
+		alert := Alert{
+			DateStamp: record.DateStamp,
+			Target:    target,
+			Pod:       record.Pod.Name,
+			Container: container.Name,
+		}
+
+		values := map[string]float64{
+			"cpu":    float64(container.Cpu),
+			"memory": float64(container.Memory),
+		}
+
+		if monitor.alerts.Anomaly.Enabled {
+			for _, metric := range []string{"cpu", "memory"} {
+				if spike, ok := monitor.checkAnomaly(state, metric, record.DateStamp, values[metric]); ok {
+					spike.DateStamp, spike.Target, spike.Pod, spike.Container = alert.DateStamp, alert.Target, alert.Pod, alert.Container
+					spike.Message = container.Name + " " + spike.Message
+					raised = append(raised, spike)
+				}
+			}
+		}
+
+		for _, rule := range monitor.alerts.Rules {
+			threshold, ok := ruleThreshold(rule, pod, container.Name)
+			if !ok {
+				continue
+			}
+
+			value := values[rule.Metric]
+			if value <= threshold {
+				delete(state.breached, rule.Name)
+				delete(state.fired, rule.Name)
+				continue
+			}
+
+			if _, breached := state.breached[rule.Name]; !breached {
+				state.breached[rule.Name] = record.DateStamp
+			}
+			if state.fired[rule.Name] || record.DateStamp-state.breached[rule.Name] < int64(rule.ForDuration().Seconds()) {
+				continue
+			}
+			state.fired[rule.Name] = true
+
+			fired := alert
+			fired.Rule = rule.Name
+			fired.Metric = rule.Metric
+			fired.Value = value
+			fired.Threshold = threshold
+			fired.Message = fmt.Sprintf("%s %s is %s, above %s", container.Name, rule.Metric, format(rule.Metric, value), describeThreshold(rule, threshold))
+			if rule.For != "" {
+				fired.Message += " for " + rule.ForDuration().String()
+			}
+			raised = append(raised, fired)
+		}
+	}
+
+	monitor.prune(record.DateStamp)
+
+	monitor.recent = append(monitor.recent, raised...)
+	if len(monitor.recent) > defaults.ALERT_HISTORY {
+		monitor.recent = monitor.recent[len(monitor.recent)-defaults.ALERT_HISTORY:]
+	}
+
+	return raised
+}
+
+// Scores the value against the moving average of the metric before adding it, and returns an alert if it is the start of a spike
+func (monitor *Monitor) checkAnomaly(state *containerState, metric string, timestamp int64, value float64) (Alert, bool) {
+
+	options := monitor.alerts.Anomaly
+
+	average, exists := state.averages[metric]
+	if !exists {
+		average = analysis.NewEWMA(options.Alpha)
+		state.averages[metric] = average
+	}
+	defer average.Add(timestamp, value)
+
+	if average.Count() < options.Warmup {
+		return Alert{}, false
+	}
+
+	mean := average.Mean()
+	rise := value - mean
+	spiking := average.ZScore(value) >= options.Threshold && rise >= options.MinChange*mean && rise >= minimumSpike[metric]
+
+	// Only the first sample of a spike raises an alert
+	started := spiking && !state.spiking[metric]
+	state.spiking[metric] = spiking
+	if !started {
+		return Alert{}, false
+	}
+
+	threshold := mean + options.Threshold*average.StdDev()
+	return Alert{
+		Rule:      AnomalyRule,
+		Metric:    metric,
+		Value:     value,
+		Threshold: threshold,
+		Message:   fmt.Sprintf("%s spiked to %s from an average of %s", metric, format(metric, value), format(metric, mean)),
+	}, true
+}
+
+// Returns the threshold of the rule in millicores or bytes for the container, and false if it is relative to a request
+// or limit the container doesn't have
+func ruleThreshold(rule config.AlertRule, pod *v1Core.Pod, containerName string) (float64, bool) {
+
+	if rule.Of == "" {
+		return rule.Above, true
+	}
+	if pod == nil {
+		return 0, false
+	}
+
+	resourceName := v1Core.ResourceCPU
+	if rule.Metric == "memory" {
+		resourceName = v1Core.ResourceMemory
+	}
+
+	for _, container := range pod.Spec.Containers {
+		if container.Name != containerName {
+			continue
+		}
+
+		resources := container.Resources.Limits
+		if rule.Of == "request" {
+			resources = container.Resources.Requests
+		}
+
+		quantity, exists := resources[resourceName]
+		if !exists || quantity.IsZero() {
+			return 0, false
+		}
+
+		total := float64(quantity.Value())
+		if rule.Metric == "cpu" {
+			total = float64(quantity.MilliValue())
+		}
+		return total * rule.Above / 100, true
+	}
+
+	return 0, false
+}
+
+// Describes what the rule's threshold is, such as "90% of its limit (450m)"
+func describeThreshold(rule config.AlertRule, threshold float64) string {
+	if rule.Of == "" {
+		return format(rule.Metric, threshold)
+	}
+	return fmt.Sprintf("%g%% of its %s (%s)", rule.Above, rule.Of, format(rule.Metric, threshold))
+}
+
+// Formats a cpu value in millicores or a memory value in bytes
+func format(metric string, value float64) string {
+	if metric == "cpu" {
+		return fmt.Sprintf("%.0fm", value)
+	}
+	return fmt.Sprintf("%.1fMi", value/(1024*1024))
+}
+
+// Removes the state of the containers that haven't been seen for a while, such as those of deleted pods
+func (monitor *Monitor) prune(now int64) {
+
+	expiry := int64(defaults.ALERT_STATE_EXPIRY.Seconds())
+	if now-monitor.pruned < expiry {
+		return
+	}
+	monitor.pruned = now
+
+	for key, state := range monitor.containers {
+		if now-state.seen > expiry {
+			delete(monitor.containers, key)
+		}
+	}
+}
+
+// Passes the alert to each notifier. The notifiers run in the background so a slow webhook doesn't hold up the capture
+func (monitor *Monitor) Notify(alert Alert) {
+
+	monitor.mutex.Lock()
+	notifiers := monitor.notifiers
+	monitor.mutex.Unlock()
+
+	for _, notifier := range notifiers {
+		go func(notifier Notifier) {
+			if err := notifier.Notify(alert); err != nil {
+				logging.Error().Printf("error: %s\n", err.Error())
+			}
+		}(notifier)
+	}
+}
+
+// Returns the most recent alerts, oldest first
+func (monitor *Monitor) Recent() []Alert {
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+
+	return append([]Alert{}, monitor.recent...)
+}
@@ -0,0 +1,363 @@
+package alert
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"pod_profiler/pkg/api/capture"
+	"pod_profiler/pkg/api/config"
+	"reflect"
+	"strings"
+	"testing"
+
+	v1Core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// A sample of the api container and the messages of the alerts it is expected to raise
+type step struct {
+	timestamp   int64
+	cpu, memory int64
+	messages    []string
+}
+
+// Returns a pod whose api container has the given cpu request and limit
+func apiPod(request, limit string) *v1Core.Pod {
+	return &v1Core.Pod{
+		Spec: v1Core.PodSpec{Containers: []v1Core.Container{{
+			Name: "api",
+			Resources: v1Core.ResourceRequirements{
+				Requests: v1Core.ResourceList{v1Core.ResourceCPU: resource.MustParse(request)},
+				Limits:   v1Core.ResourceList{v1Core.ResourceCPU: resource.MustParse(limit)},
+			},
+		}}},
+	}
+}
+
+// Checks each step's sample in turn and compares the messages of the alerts it raises
+func checkSteps(t *testing.T, name string, monitor *Monitor, pod *v1Core.Pod, steps []step) {
+	for i, step := range steps {
+		record := capture.Record{
+			DateStamp: step.timestamp,
+			Pod:       capture.Pod{Name: "api-abcde", Containers: []capture.Container{{Name: "api", Cpu: step.cpu, Memory: step.memory}}},
+		}
+
+		messages := []string{}
+		for _, alert := range monitor.Check("api", record, pod) {
+			messages = append(messages, alert.Message)
+		}
+		if step.messages == nil {
+			step.messages = []string{}
+		}
+
+		if !reflect.DeepEqual(messages, step.messages) {
+			t.Errorf("%s: step %d: expected %q, got %q", name, i, step.messages, messages)
+		}
+	}
+}
+
+func TestRules(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		rule  config.AlertRule
+		pod   *v1Core.Pod
+		steps []step
+	}{
+		{
+			// Each breach fires once until the usage drops back to the threshold
+			name: "absolute",
+			rule: config.AlertRule{Name: "cpu", Metric: "cpu", Above: 500},
+			steps: []step{
+				{timestamp: 0, cpu: 500},
+				{timestamp: 10, cpu: 600, messages: []string{"api cpu is 600m, above 500m"}},
+				{timestamp: 20, cpu: 700},
+				{timestamp: 30, cpu: 400},
+				{timestamp: 40, cpu: 501, messages: []string{"api cpu is 501m, above 500m"}},
+			},
+		},
+		{
+			name: "memory",
+			rule: config.AlertRule{Name: "memory", Metric: "memory", Above: 256 * 1024 * 1024},
+			steps: []step{
+				{timestamp: 0, memory: 128 * 1024 * 1024},
+				{timestamp: 10, memory: 300 * 1024 * 1024, messages: []string{"api memory is 300.0Mi, above 256.0Mi"}},
+			},
+		},
+		{
+			// The usage must stay above the threshold for the whole duration
+			name: "for",
+			rule: config.AlertRule{Name: "cpu", Metric: "cpu", Above: 500, For: "2m"},
+			steps: []step{
+				{timestamp: 0, cpu: 600},
+				{timestamp: 60, cpu: 600},
+				{timestamp: 90, cpu: 400},
+				{timestamp: 100, cpu: 600},
+				{timestamp: 219, cpu: 600},
+				{timestamp: 220, cpu: 650, messages: []string{"api cpu is 650m, above 500m for 2m0s"}},
+				{timestamp: 300, cpu: 650},
+			},
+		},
+		{
+			name: "limit",
+			rule: config.AlertRule{Name: "cpu", Metric: "cpu", Of: "limit", Above: 90},
+			pod:  apiPod("250m", "500m"),
+			steps: []step{
+				{timestamp: 0, cpu: 450},
+				{timestamp: 10, cpu: 460, messages: []string{"api cpu is 460m, above 90% of its limit (450m)"}},
+			},
+		},
+		{
+			name: "request",
+			rule: config.AlertRule{Name: "cpu", Metric: "cpu", Of: "request", Above: 150},
+			pod:  apiPod("200m", "1"),
+			steps: []step{
+				{timestamp: 0, cpu: 301, messages: []string{"api cpu is 301m, above 150% of its request (300m)"}},
+			},
+		},
+		{
+			// Without the pod there is no limit to compare against
+			name: "no pod",
+			rule: config.AlertRule{Name: "cpu", Metric: "cpu", Of: "limit", Above: 90},
+			steps: []step{
+				{timestamp: 0, cpu: 10000},
+			},
+		},
+		{
+			name: "no memory limit",
+			rule: config.AlertRule{Name: "memory", Metric: "memory", Of: "limit", Above: 90},
+			pod:  apiPod("250m", "500m"),
+			steps: []step{
+				{timestamp: 0, memory: 1024 * 1024 * 1024},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		monitor := NewMonitor(config.Alerts{Rules: []config.AlertRule{test.rule}})
+		checkSteps(t, test.name, monitor, test.pod, test.steps)
+	}
+}
+
+func TestAnomaly(t *testing.T) {
+
+	anomaly := config.Anomaly{Enabled: true, Threshold: 4, Alpha: 0.1, Warmup: 5, MinChange: 0.2}
+
+	// A steady series that alternates around 100m and 100Mi
+	steady := func(from int64, count int) []step {
+		steps := []step{}
+		for i := 0; i < count; i++ {
+			steps = append(steps, step{timestamp: from + int64(i)*10, cpu: 98 + int64(i%2)*4, memory: 100 * 1024 * 1024})
+		}
+		return steps
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name:  "steady",
+			steps: steady(0, 20),
+		},
+		{
+			// The average hasn't settled during the warmup
+			name:  "warmup",
+			steps: append(steady(0, 4), step{timestamp: 40, cpu: 1000, memory: 100 * 1024 * 1024}),
+		},
+		{
+			// Only the first sample of a spike raises an alert
+			name: "spike",
+			steps: append(steady(0, 10),
+				step{timestamp: 100, cpu: 400, memory: 100 * 1024 * 1024, messages: []string{"api cpu spiked to 400m from an average of 99m"}},
+				step{timestamp: 110, cpu: 400, memory: 100 * 1024 * 1024},
+			),
+		},
+		{
+			name: "memory spike",
+			steps: append(steady(0, 10),
+				step{timestamp: 100, cpu: 100, memory: 200 * 1024 * 1024, messages: []string{"api memory spiked to 200.0Mi from an average of 100.0Mi"}},
+			),
+		},
+		{
+			// A rise of many standard deviations is still too small to be a spike
+			name: "small rise",
+			steps: append(steady(0, 10),
+				step{timestamp: 100, cpu: 115, memory: 100 * 1024 * 1024},
+				step{timestamp: 110, cpu: 100, memory: 101 * 1024 * 1024},
+			),
+		},
+	}
+
+	for _, test := range tests {
+		monitor := NewMonitor(config.Alerts{Anomaly: anomaly})
+		checkSteps(t, test.name, monitor, nil, test.steps)
+	}
+}
+
+func TestAlert(t *testing.T) {
+
+	monitor := NewMonitor(config.Alerts{Rules: []config.AlertRule{{Name: "hot", Metric: "cpu", Above: 500}}})
+	record := capture.Record{
+		DateStamp: 1700000000,
+		Pod:       capture.Pod{Name: "api-abcde", Containers: []capture.Container{{Name: "api", Cpu: 600}, {Name: "proxy", Cpu: 10}}},
+	}
+
+	alerts := monitor.Check("sps-api", record, nil)
+
+	expected := []Alert{{
+		DateStamp: 1700000000,
+		Target:    "sps-api",
+		Pod:       "api-abcde",
+		Container: "api",
+		Rule:      "hot",
+		Metric:    "cpu",
+		Value:     600,
+		Threshold: 500,
+		Message:   "api cpu is 600m, above 500m",
+	}}
+	if !reflect.DeepEqual(alerts, expected) {
+		t.Errorf("expected %+v, got %+v", expected, alerts)
+	}
+	if !reflect.DeepEqual(monitor.Recent(), expected) {
+		t.Errorf("expected the alert to be recent, got %+v", monitor.Recent())
+	}
+
+	event := alerts[0].Event()
+	if event.Type != capture.EventType_Alert || event.Pod != "api-abcde" || event.Container != "api" || event.Reason != "hot" || event.Message != alerts[0].Message {
+		t.Errorf("unexpected event %+v", event)
+	}
+}
+
+func TestConfigure(t *testing.T) {
+
+	rules := config.Alerts{Rules: []config.AlertRule{{Name: "cpu", Metric: "cpu", Above: 500}}}
+	monitor := NewMonitor(rules)
+	monitor.Check("api", capture.Record{DateStamp: 0, Pod: capture.Pod{Name: "api-abcde", Containers: []capture.Container{{Name: "api", Cpu: 600}}}}, nil)
+
+	// Reapplying the same rules, such as when another part of the config changed, keeps the fired rules quiet
+	monitor.Configure(rules)
+	if alerts := monitor.Check("api", capture.Record{DateStamp: 10, Pod: capture.Pod{Name: "api-abcde", Containers: []capture.Container{{Name: "api", Cpu: 600}}}}, nil); len(alerts) != 0 {
+		t.Errorf("expected no alerts after reapplying the rules, got %+v", alerts)
+	}
+
+	// A changed rule starts again
+	rules = config.Alerts{Rules: []config.AlertRule{{Name: "cpu", Metric: "cpu", Above: 550}}}
+	monitor.Configure(rules)
+	if alerts := monitor.Check("api", capture.Record{DateStamp: 20, Pod: capture.Pod{Name: "api-abcde", Containers: []capture.Container{{Name: "api", Cpu: 600}}}}, nil); len(alerts) != 1 {
+		t.Errorf("expected an alert after changing the rules, got %+v", alerts)
+	}
+
+	// Without anomaly detection or rules nothing is checked
+	monitor.Configure(config.Alerts{})
+	if alerts := monitor.Check("api", capture.Record{DateStamp: 30, Pod: capture.Pod{Name: "api-abcde", Containers: []capture.Container{{Name: "api", Cpu: 6000}}}}, nil); alerts != nil {
+		t.Errorf("expected no alerts without rules, got %+v", alerts)
+	}
+}
+
+var testAlert = Alert{
+	DateStamp: 1700000000,
+	Target:    "sps-api",
+	Pod:       "api-abcde",
+	Container: "api",
+	Rule:      "cpu",
+	Metric:    "cpu",
+	Value:     600,
+	Threshold: 500,
+	Message:   "api cpu is 600m, above 500m",
+}
+
+func TestWebhook(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		status int
+		err    string
+	}{
+		{"ok", http.StatusOK, ""},
+		{"accepted", http.StatusAccepted, ""},
+		{"error", http.StatusInternalServerError, "500 Internal Server Error"},
+	}
+
+	for _, test := range tests {
+		var received Alert
+		var contentType, method string
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			method, contentType = r.Method, r.Header.Get("Content-Type")
+			body, _ := io.ReadAll(r.Body)
+			json.Unmarshal(body, &received)
+			w.WriteHeader(test.status)
+		}))
+
+		notifier, err := NewNotifier(config.AlertNotifier{Type: "webhook", URL: server.URL})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = notifier.Notify(testAlert)
+		server.Close()
+
+		if test.err == "" && err != nil {
+			t.Errorf("%s: expected no error, got %s", test.name, err.Error())
+		}
+		if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%s: expected %s, got %v", test.name, test.err, err)
+		}
+		if method != http.MethodPost || contentType != "application/json" {
+			t.Errorf("%s: expected a JSON post, got %s %s", test.name, method, contentType)
+		}
+		if received != testAlert {
+			t.Errorf("%s: expected %+v, got %+v", test.name, testAlert, received)
+		}
+	}
+}
+
+func TestCommand(t *testing.T) {
+
+	output := path.Join(t.TempDir(), "alert")
+
+	// The command is given the alert as JSON on stdin and its main fields as environment variables
+	notifier, err := NewNotifier(config.AlertNotifier{
+		Type:    "command",
+		Command: []string{"sh", "-c", `cat > "$0.json" && echo "$PROFILER_ALERT_RULE $PROFILER_ALERT_TARGET $PROFILER_ALERT_POD $PROFILER_ALERT_CONTAINER" > "$0.env"`, output},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := notifier.Notify(testAlert); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(output + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := Alert{}
+	if err := json.Unmarshal(data, &received); err != nil {
+		t.Fatal(err)
+	}
+	if received != testAlert {
+		t.Errorf("expected %+v, got %+v", testAlert, received)
+	}
+
+	env, err := os.ReadFile(output + ".env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "cpu sps-api api-abcde api\n"; string(env) != expected {
+		t.Errorf("expected %q, got %q", expected, string(env))
+	}
+
+	// A failing command reports its output
+	notifier, _ = NewNotifier(config.AlertNotifier{Type: "command", Command: []string{"sh", "-c", "echo unreachable >&2; exit 1"}})
+	if err := notifier.Notify(testAlert); err == nil || !strings.HasSuffix(err.Error(), ": unreachable") {
+		t.Errorf("expected the command's output in the error, got %v", err)
+	}
+
+	if _, err := NewNotifier(config.AlertNotifier{Type: "email"}); err == nil {
+		t.Errorf("expected an error for an unknown notifier")
+	}
+}
@@ -0,0 +1,107 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"pod_profiler/pkg/api/config"
+	"pod_profiler/pkg/api/version"
+	"strings"
+	"time"
+)
+
+// Notifier is told about each alert that is raised
+type Notifier interface {
+	Notify(alert Alert) error
+}
+
+// Creates the notifier described by the config
+func NewNotifier(notifier config.AlertNotifier) (Notifier, error) {
+	switch notifier.Type {
+	case "webhook":
+		return &webhook{
+			url:    notifier.URL,
+			client: &http.Client{Timeout: notifier.TimeoutDuration()},
+		}, nil
+	case "command":
+		if len(notifier.Command) == 0 {
+			return nil, fmt.Errorf("the command notifier has no command")
+		}
+		return &command{
+			args:    notifier.Command,
+			timeout: notifier.TimeoutDuration(),
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown notifier type %q", notifier.Type)
+}
+
+// Posts each alert to a URL as JSON
+type webhook struct {
+	url    string
+	client *http.Client
+}
+
+func (webhook *webhook) Notify(alert Alert) error {
+
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, webhook.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "pod-profiler/"+version.Version)
+
+	response, err := webhook.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("unable to notify %s: %s", webhook.url, err.Error())
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("unable to notify %s: %s", webhook.url, response.Status)
+	}
+
+	return nil
+}
+
+// Runs a local command for each alert, the alert is given as JSON on stdin and its main fields as environment variables
+type command struct {
+	args    []string
+	timeout time.Duration
+}
+
+func (command *command) Notify(alert Alert) error {
+
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), command.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, command.args[0], command.args[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"PROFILER_ALERT_RULE="+alert.Rule,
+		"PROFILER_ALERT_TARGET="+alert.Target,
+		"PROFILER_ALERT_POD="+alert.Pod,
+		"PROFILER_ALERT_CONTAINER="+alert.Container,
+		"PROFILER_ALERT_MESSAGE="+alert.Message,
+	)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("notify command %s failed: %s: %s", command.args[0], err.Error(), strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
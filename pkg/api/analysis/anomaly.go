@@ -0,0 +1,60 @@
+package analysis
+
+import "math"
+
+// EWMA is an exponentially weighted moving mean and variance. Unlike Moments it follows a series as it drifts,
+// so a sample can be scored against the recent behaviour of the series rather than its whole history
+type EWMA struct {
+	alpha    float64
+	count    int
+	mean     float64
+	variance float64
+}
+
+// Creates a moving average where alpha, between 0 and 1, is the weight of each new sample
+func NewEWMA(alpha float64) *EWMA {
+	return &EWMA{alpha: alpha}
+}
+
+func (ewma *EWMA) Add(timestamp int64, value float64) {
+	ewma.count++
+	if ewma.count == 1 {
+		ewma.mean = value
+		return
+	}
+
+	delta := value - ewma.mean
+	increment := ewma.alpha * delta
+	ewma.mean += increment
+	ewma.variance = (1 - ewma.alpha) * (ewma.variance + delta*increment)
+}
+
+func (ewma *EWMA) Count() int {
+	return ewma.count
+}
+
+// Returns the moving mean, or zero if there are no samples
+func (ewma *EWMA) Mean() float64 {
+	return ewma.mean
+}
+
+// Returns the moving standard deviation
+func (ewma *EWMA) StdDev() float64 {
+	return math.Sqrt(ewma.variance)
+}
+
+// Returns how many standard deviations the value is above the moving mean, which is negative below it.
+// A series that hasn't varied yet has no spread to score against, so any change from it scores infinitely
+func (ewma *EWMA) ZScore(value float64) float64 {
+	stddev := ewma.StdDev()
+	if stddev == 0 {
+		switch {
+		case value > ewma.mean:
+			return math.Inf(1)
+		case value < ewma.mean:
+			return math.Inf(-1)
+		}
+		return 0
+	}
+	return (value - ewma.mean) / stddev
+}
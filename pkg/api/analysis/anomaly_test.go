@@ -0,0 +1,70 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEWMA(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		alpha  float64
+		values []float64
+		mean   float64
+		stddev float64
+	}{
+		{"empty", 0.5, nil, 0, 0},
+		{"first sample", 0.5, []float64{10}, 10, 0},
+		{"two samples", 0.5, []float64{10, 20}, 15, 5},
+		{"back to the mean", 0.5, []float64{10, 20, 15}, 15, math.Sqrt(12.5)},
+		{"constant", 0.1, []float64{7, 7, 7, 7}, 7, 0},
+		// The weight of the first sample falls to (1 - alpha)^n
+		{"step", 0.5, []float64{0, 8, 8, 8}, 7, math.Sqrt(7)},
+	}
+
+	for _, test := range tests {
+		ewma := NewEWMA(test.alpha)
+		for i, value := range test.values {
+			ewma.Add(int64(i), value)
+		}
+
+		if ewma.Count() != len(test.values) {
+			t.Errorf("%s: expected count %d, got %d", test.name, len(test.values), ewma.Count())
+		}
+		if math.Abs(ewma.Mean()-test.mean) > 1e-9 {
+			t.Errorf("%s: expected mean %g, got %g", test.name, test.mean, ewma.Mean())
+		}
+		if math.Abs(ewma.StdDev()-test.stddev) > 1e-9 {
+			t.Errorf("%s: expected standard deviation %g, got %g", test.name, test.stddev, ewma.StdDev())
+		}
+	}
+}
+
+func TestEWMAZScore(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		values   []float64
+		value    float64
+		expected float64
+	}{
+		{"above", []float64{10, 20}, 25, 2},
+		{"below", []float64{10, 20}, 5, -2},
+		{"at the mean", []float64{10, 20}, 15, 0},
+		{"no spread above", []float64{10, 10}, 11, math.Inf(1)},
+		{"no spread below", []float64{10, 10}, 9, math.Inf(-1)},
+		{"no spread at the mean", []float64{10, 10}, 10, 0},
+	}
+
+	for _, test := range tests {
+		ewma := NewEWMA(0.5)
+		for i, value := range test.values {
+			ewma.Add(int64(i), value)
+		}
+
+		if actual := ewma.ZScore(test.value); actual != test.expected && math.Abs(actual-test.expected) > 1e-9 {
+			t.Errorf("%s: expected %g, got %g", test.name, test.expected, actual)
+		}
+	}
+}
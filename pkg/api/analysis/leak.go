@@ -0,0 +1,186 @@
+package analysis
+
+import (
+	"sort"
+	"time"
+)
+
+// Point is a sample of a series, the timestamp is in unix seconds
+type Point struct {
+	Time  int64
+	Value float64
+}
+
+// LeakOptions decide when a memory trend is reported as a leak
+type LeakOptions struct {
+
+	// The smallest R² of the trend, between 0 and 1, for it to count as sustained
+	MinConfidence float64
+
+	// The smallest growth per hour, in the units of the series, worth reporting
+	MinGrowth float64
+
+	// The least amount of data the trend must cover
+	MinDuration time.Duration
+
+	// The memory is reduced to its lowest value in each window before fitting, so the trend follows
+	// the floor the garbage collector returns to rather than the sawtooth above it
+	Window time.Duration
+}
+
+// The leak options used unless others are given, which flag growth of at least 1Mi per hour over half an hour or more
+var DefaultLeakOptions = LeakOptions{
+	MinConfidence: 0.8,
+	MinGrowth:     1024 * 1024,
+	MinDuration:   30 * time.Minute,
+	Window:        5 * time.Minute,
+}
+
+// Trend is the linear trend of a memory series
+type Trend struct {
+
+	// The growth per hour, in the units of the series
+	GrowthPerHour float64 `json:"growthPerHour"`
+
+	// The R² of the fit between 0 and 1, how much of the variation the trend explains
+	Confidence float64 `json:"confidence"`
+
+	// How much of the series the trend was fitted to, in seconds
+	Duration int64 `json:"duration"`
+
+	// The value of the trend at the last sample
+	Current float64 `json:"current"`
+
+	// The estimated seconds until the trend reaches the limit, nil without a limit or growth
+	TimeToLimit *int64 `json:"timeToLimit,omitempty"`
+
+	// Whether the trend meets the options for a leak
+	Leaking bool `json:"leaking"`
+}
+
+// Fits a trend to a memory series. The series is split at the given restart times, and wherever the value
+// drops by more than half, so each run of the container is fitted separately with a shared slope
+func DetectLeak(points []Point, restarts []int64, limit *float64, options LeakOptions) Trend {
+
+	segments := [][]Point{}
+	for _, segment := range split(points, restarts) {
+		if reduced := windowMinima(segment, options.Window); len(reduced) >= 3 {
+			segments = append(segments, reduced)
+		}
+	}
+
+	trend := Trend{}
+	if len(segments) == 0 {
+		return trend
+	}
+
+	// Least squares with an intercept per segment, so the drop at each restart doesn't count against the trend
+	var covariance, variance, total, residual float64
+	means := make([][2]float64, len(segments))
+
+	for i, segment := range segments {
+		var meanTime, meanValue float64
+		for _, point := range segment {
+			meanTime += float64(point.Time)
+			meanValue += point.Value
+		}
+		meanTime /= float64(len(segment))
+		meanValue /= float64(len(segment))
+		means[i] = [2]float64{meanTime, meanValue}
+
+		for _, point := range segment {
+			covariance += (float64(point.Time) - meanTime) * (point.Value - meanValue)
+			variance += (float64(point.Time) - meanTime) * (float64(point.Time) - meanTime)
+			total += (point.Value - meanValue) * (point.Value - meanValue)
+		}
+		trend.Duration += segment[len(segment)-1].Time - segment[0].Time
+	}
+
+	if variance == 0 {
+		return trend
+	}
+	slope := covariance / variance
+
+	for i, segment := range segments {
+		for _, point := range segment {
+			fitted := means[i][1] + slope*(float64(point.Time)-means[i][0])
+			residual += (point.Value - fitted) * (point.Value - fitted)
+		}
+	}
+
+	trend.GrowthPerHour = slope * 3600
+	if total > 0 {
+		trend.Confidence = max(0, 1-residual/total)
+	}
+
+	last := segments[len(segments)-1]
+	lastMeans := means[len(means)-1]
+	trend.Current = lastMeans[1] + slope*(float64(last[len(last)-1].Time)-lastMeans[0])
+
+	if limit != nil && slope > 0 {
+		remaining := int64(max(0, (*limit-trend.Current)/slope))
+		trend.TimeToLimit = &remaining
+	}
+
+	trend.Leaking = trend.GrowthPerHour >= options.MinGrowth &&
+		trend.Confidence >= options.MinConfidence &&
+		time.Duration(trend.Duration)*time.Second >= options.MinDuration
+
+	return trend
+}
+
+// Splits the series at the restarts and at any drop of more than half, which is a restart we didn't see
+func split(points []Point, restarts []int64) [][]Point {
+
+	sorted := append([]Point{}, points...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Time < sorted[j].Time })
+	sort.Slice(restarts, func(i, j int) bool { return restarts[i] < restarts[j] })
+
+	segments := [][]Point{}
+	current := []Point{}
+	next := 0
+
+	for _, point := range sorted {
+		restarted := false
+		for next < len(restarts) && restarts[next] <= point.Time {
+			restarted = true
+			next++
+		}
+
+		dropped := len(current) > 0 && point.Value < current[len(current)-1].Value/2
+		if (restarted || dropped) && len(current) > 0 {
+			segments = append(segments, current)
+			current = []Point{}
+		}
+		current = append(current, point)
+	}
+
+	if len(current) > 0 {
+		segments = append(segments, current)
+	}
+
+	return segments
+}
+
+// Returns the lowest point in each window of the segment. Segments too short for three windows are returned whole
+func windowMinima(segment []Point, window time.Duration) []Point {
+
+	seconds := int64(window.Seconds())
+	if seconds <= 0 || len(segment) == 0 || segment[len(segment)-1].Time-segment[0].Time < 3*seconds {
+		return segment
+	}
+
+	minima := []Point{}
+	current := int64(-1)
+	for _, point := range segment {
+		window := (point.Time - segment[0].Time) / seconds
+		if window != current {
+			minima = append(minima, point)
+			current = window
+		} else if point.Value < minima[len(minima)-1].Value {
+			minima[len(minima)-1] = point
+		}
+	}
+
+	return minima
+}
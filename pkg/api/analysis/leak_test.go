@@ -0,0 +1,154 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+)
+
+const mebibyte = 1024 * 1024
+
+// Returns a point every minute from start to end inclusive, with the value given by the function of the time
+func series(start, end int64, value func(t int64) float64) []Point {
+	points := []Point{}
+	for t := start; t <= end; t += 60 {
+		points = append(points, Point{t, value(t)})
+	}
+	return points
+}
+
+func TestDetectLeak(t *testing.T) {
+
+	// Growing by 1Ki a second is 3.5Mi an hour
+	growing := func(t int64) float64 { return 100*mebibyte + 1024*float64(t) }
+
+	tests := []struct {
+		name       string
+		points     []Point
+		restarts   []int64
+		growth     float64
+		confidence float64
+		duration   int64
+		leaking    bool
+	}{
+		{
+			name:   "too few points",
+			points: series(0, 60, growing),
+		},
+		{
+			name:     "flat",
+			points:   series(0, 3600, func(t int64) float64 { return 100 * mebibyte }),
+			duration: 3600,
+		},
+		{
+			name:       "linear",
+			points:     series(0, 3600, growing),
+			growth:     3686400,
+			confidence: 1,
+			duration:   3600,
+			leaking:    true,
+		},
+		{
+			name:       "too short",
+			points:     series(0, 1200, growing),
+			growth:     3686400,
+			confidence: 1,
+			duration:   1200,
+		},
+		{
+			// Each run is fitted with its own intercept, so the restart doesn't hide the growth
+			name: "restarted",
+			points: append(
+				series(0, 1800, growing),
+				series(1860, 3600, func(t int64) float64 { return growing(t - 1860) })...,
+			),
+			restarts:   []int64{1860},
+			growth:     3686400,
+			confidence: 1,
+			duration:   1800 + 1500,
+			leaking:    true,
+		},
+		{
+			// The garbage collector drops back to the floor every two minutes, the floor is what grows
+			name:       "sawtooth",
+			points:     series(0, 3600, func(t int64) float64 { return growing(t) + float64(t%120)*mebibyte/10 }),
+			growth:     3686400,
+			confidence: 1,
+			duration:   3600,
+			leaking:    true,
+		},
+		{
+			// A perfect fit, but shrinking memory isn't a leak. The lowest point of the first window is its last
+			name:       "shrinking",
+			points:     series(0, 3600, func(t int64) float64 { return 200*mebibyte - 1024*float64(t) }),
+			growth:     -3686400,
+			confidence: 1,
+			duration:   3600 - 240,
+		},
+	}
+
+	for _, test := range tests {
+		trend := DetectLeak(test.points, test.restarts, nil, DefaultLeakOptions)
+
+		if math.Abs(trend.GrowthPerHour-test.growth) > 1e-3 {
+			t.Errorf("%s: expected growth of %g an hour, got %g", test.name, test.growth, trend.GrowthPerHour)
+		}
+		if math.Abs(trend.Confidence-test.confidence) > 1e-9 {
+			t.Errorf("%s: expected confidence %g, got %g", test.name, test.confidence, trend.Confidence)
+		}
+		if trend.Duration != test.duration {
+			t.Errorf("%s: expected a duration of %d, got %d", test.name, test.duration, trend.Duration)
+		}
+		if trend.Leaking != test.leaking {
+			t.Errorf("%s: expected leaking %t, got %t", test.name, test.leaking, trend.Leaking)
+		}
+		if trend.TimeToLimit != nil {
+			t.Errorf("%s: expected no time to limit without a limit, got %d", test.name, *trend.TimeToLimit)
+		}
+	}
+}
+
+func TestDetectLeakUnseenRestart(t *testing.T) {
+
+	// Dropping by more than half is treated as a restart even when it wasn't recorded
+	points := append(
+		series(0, 1800, func(t int64) float64 { return 300*mebibyte + 1024*float64(t) }),
+		series(1860, 3600, func(t int64) float64 { return 100*mebibyte + 1024*float64(t-1860) })...,
+	)
+
+	trend := DetectLeak(points, nil, nil, DefaultLeakOptions)
+	if math.Abs(trend.GrowthPerHour-3686400) > 1e-3 || !trend.Leaking {
+		t.Errorf("expected a leak of 3686400 an hour, got %+v", trend)
+	}
+}
+
+func TestDetectLeakTimeToLimit(t *testing.T) {
+
+	points := series(0, 3600, func(t int64) float64 { return 100*mebibyte + 1024*float64(t) })
+
+	tests := []struct {
+		name     string
+		limit    float64
+		expected int64
+	}{
+		{"below the limit", 100*mebibyte + 1024*3600 + 1024*1000, 1000},
+		{"at the limit", 100*mebibyte + 1024*3600, 0},
+		{"over the limit", 100 * mebibyte, 0},
+	}
+
+	for _, test := range tests {
+		trend := DetectLeak(points, nil, &test.limit, DefaultLeakOptions)
+
+		if math.Abs(trend.Current-(100*mebibyte+1024*3600)) > 1e-3 {
+			t.Errorf("%s: expected the trend to be at %d, got %g", test.name, 100*mebibyte+1024*3600, trend.Current)
+		}
+		if trend.TimeToLimit == nil || *trend.TimeToLimit != test.expected {
+			t.Errorf("%s: expected %d seconds to the limit, got %v", test.name, test.expected, trend.TimeToLimit)
+		}
+	}
+
+	flat := series(0, 3600, func(t int64) float64 { return 100 * mebibyte })
+	limit := float64(200 * mebibyte)
+	if trend := DetectLeak(flat, nil, &limit, DefaultLeakOptions); trend.TimeToLimit != nil {
+		t.Errorf("expected no time to limit without growth, got %d", *trend.TimeToLimit)
+	}
+}
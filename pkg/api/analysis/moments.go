@@ -0,0 +1,72 @@
+package analysis
+
+import "math"
+
+// Aggregator is updated with each sample of a series, the timestamps are unix seconds
+type Aggregator interface {
+	Add(timestamp int64, value float64)
+}
+
+// Moments is the count, mean and standard deviation of a series, calculated in a single pass
+// with Welford's algorithm so that long series don't lose precision
+type Moments struct {
+	count int
+	mean  float64
+	m2    float64
+}
+
+func (moments *Moments) Add(timestamp int64, value float64) {
+	moments.count++
+	delta := value - moments.mean
+	moments.mean += delta / float64(moments.count)
+	moments.m2 += delta * (value - moments.mean)
+}
+
+func (moments *Moments) Count() int {
+	return moments.count
+}
+
+// Returns the mean, or zero if there are no samples
+func (moments *Moments) Mean() float64 {
+	return moments.mean
+}
+
+// Returns the population variance, or zero if there are fewer than two samples
+func (moments *Moments) Variance() float64 {
+	if moments.count < 2 {
+		return 0
+	}
+	return moments.m2 / float64(moments.count)
+}
+
+// Returns the population standard deviation
+func (moments *Moments) StdDev() float64 {
+	return math.Sqrt(moments.Variance())
+}
+
+// Extremes is the smallest and largest values of a series
+type Extremes struct {
+	count int
+	min   float64
+	max   float64
+}
+
+func (extremes *Extremes) Add(timestamp int64, value float64) {
+	if extremes.count == 0 || value < extremes.min {
+		extremes.min = value
+	}
+	if extremes.count == 0 || value > extremes.max {
+		extremes.max = value
+	}
+	extremes.count++
+}
+
+// Returns the smallest value, or zero if there are no samples
+func (extremes *Extremes) Min() float64 {
+	return extremes.min
+}
+
+// Returns the largest value, or zero if there are no samples
+func (extremes *Extremes) Max() float64 {
+	return extremes.max
+}
@@ -0,0 +1,67 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMoments(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		values   []float64
+		mean     float64
+		variance float64
+	}{
+		{"empty", nil, 0, 0},
+		{"single", []float64{3}, 3, 0},
+		{"textbook", []float64{2, 4, 4, 4, 5, 5, 7, 9}, 5, 4},
+		{"negative", []float64{-1, 1, -1, 1}, 0, 1},
+		// A large offset loses precision with the sum of squares, but not with Welford's algorithm
+		{"large offset", []float64{1e9 + 4, 1e9 + 7, 1e9 + 13, 1e9 + 16}, 1e9 + 10, 22.5},
+	}
+
+	for _, test := range tests {
+		moments := &Moments{}
+		for i, value := range test.values {
+			moments.Add(int64(i), value)
+		}
+
+		if moments.Count() != len(test.values) {
+			t.Errorf("%s: expected count %d, got %d", test.name, len(test.values), moments.Count())
+		}
+		if math.Abs(moments.Mean()-test.mean) > 1e-9 {
+			t.Errorf("%s: expected mean %g, got %g", test.name, test.mean, moments.Mean())
+		}
+		if math.Abs(moments.Variance()-test.variance) > 1e-9 {
+			t.Errorf("%s: expected variance %g, got %g", test.name, test.variance, moments.Variance())
+		}
+		if math.Abs(moments.StdDev()-math.Sqrt(test.variance)) > 1e-9 {
+			t.Errorf("%s: expected standard deviation %g, got %g", test.name, math.Sqrt(test.variance), moments.StdDev())
+		}
+	}
+}
+
+func TestExtremes(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		values   []float64
+		min, max float64
+	}{
+		{"empty", nil, 0, 0},
+		{"single", []float64{-2}, -2, -2},
+		{"mixed", []float64{3, -1, 5, 0}, -1, 5},
+	}
+
+	for _, test := range tests {
+		extremes := &Extremes{}
+		for i, value := range test.values {
+			extremes.Add(int64(i), value)
+		}
+
+		if extremes.Min() != test.min || extremes.Max() != test.max {
+			t.Errorf("%s: expected %g to %g, got %g to %g", test.name, test.min, test.max, extremes.Min(), extremes.Max())
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+)
+
+// Percentiles keeps every value so it can return exact percentiles. Use a Digest for series that are too long to keep
+type Percentiles struct {
+	values []float64
+	sorted bool
+}
+
+func (percentiles *Percentiles) Add(timestamp int64, value float64) {
+	percentiles.values = append(percentiles.values, value)
+	percentiles.sorted = false
+}
+
+func (percentiles *Percentiles) sort() {
+	if !percentiles.sorted {
+		sort.Float64s(percentiles.values)
+		percentiles.sorted = true
+	}
+}
+
+// Returns the percentile p, between 0 and 100, interpolating linearly between the closest ranks. This matches
+// the default of numpy and spreadsheets. Returns zero if there are no samples
+func (percentiles *Percentiles) Percentile(p float64) float64 {
+
+	if len(percentiles.values) == 0 {
+		return 0
+	}
+	percentiles.sort()
+
+	rank := clamp(p, 0, 100) / 100 * float64(len(percentiles.values)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+
+	return percentiles.values[lower] + (rank-float64(lower))*(percentiles.values[upper]-percentiles.values[lower])
+}
+
+// Returns the percentile p, between 0 and 100, using the nearest rank method so the result is always one of the
+// samples. Recommendations use this, so they are never based on a value that wasn't observed
+func (percentiles *Percentiles) NearestRank(p float64) float64 {
+
+	if len(percentiles.values) == 0 {
+		return 0
+	}
+	percentiles.sort()
+
+	rank := int(math.Ceil(clamp(p, 0, 100)/100*float64(len(percentiles.values)))) - 1
+	return percentiles.values[max(rank, 0)]
+}
+
+func clamp(value, low, high float64) float64 {
+	return math.Max(low, math.Min(high, value))
+}
+
+// Digest estimates percentiles in a fixed amount of memory with a merging t-digest. The estimates are most
+// accurate at the tails, which is where the percentiles used for sizing are
+type Digest struct {
+	compression float64
+	centroids   []centroid
+	buffer      []centroid
+	count       float64
+	min         float64
+	max         float64
+}
+
+type centroid struct {
+	mean   float64
+	weight float64
+}
+
+// The default compression, which keeps at most a few hundred centroids
+const DefaultCompression = 100
+
+// Create a digest with the given compression, higher values are more accurate and use more memory
+func NewDigest(compression float64) *Digest {
+	if compression <= 0 {
+		compression = DefaultCompression
+	}
+	return &Digest{compression: compression}
+}
+
+func (digest *Digest) Add(timestamp int64, value float64) {
+
+	if digest.count == 0 || value < digest.min {
+		digest.min = value
+	}
+	if digest.count == 0 || value > digest.max {
+		digest.max = value
+	}
+	digest.count++
+
+	digest.buffer = append(digest.buffer, centroid{value, 1})
+	if len(digest.buffer) >= int(digest.compression)*5 {
+		digest.merge()
+	}
+}
+
+func (digest *Digest) Count() int {
+	return int(digest.count)
+}
+
+// Merges the buffered values into the centroids, keeping each centroid within the size the scale function allows
+func (digest *Digest) merge() {
+
+	if len(digest.buffer) == 0 {
+		return
+	}
+
+	all := append(digest.centroids, digest.buffer...)
+	sort.Slice(all, func(i, j int) bool { return all[i].mean < all[j].mean })
+	digest.buffer = digest.buffer[:0]
+
+	merged := []centroid{all[0]}
+	sofar := 0.0
+	limit := digest.count * digest.quantileLimit(0)
+
+	for _, next := range all[1:] {
+		current := &merged[len(merged)-1]
+		if sofar+current.weight+next.weight <= limit {
+			current.mean += (next.mean - current.mean) * next.weight / (current.weight + next.weight)
+			current.weight += next.weight
+			continue
+		}
+
+		sofar += current.weight
+		limit = digest.count * digest.quantileLimit(sofar/digest.count)
+		merged = append(merged, next)
+	}
+
+	digest.centroids = merged
+}
+
+// Returns the quantile up to which the centroid starting at q may extend, using the k1 scale function
+// so that centroids near the tails stay small
+func (digest *Digest) quantileLimit(q float64) float64 {
+	k := digest.compression / (2 * math.Pi) * math.Asin(2*q-1)
+	return (math.Sin(math.Min(k+1, digest.compression/4)*2*math.Pi/digest.compression) + 1) / 2
+}
+
+// Returns the estimated percentile p, between 0 and 100, or zero if there are no samples. While every value is still
+// its own centroid, as it is for short series, the percentile is exact and matches Percentiles.Percentile
+func (digest *Digest) Percentile(p float64) float64 {
+
+	digest.merge()
+
+	if digest.count == 0 {
+		return 0
+	}
+	if len(digest.centroids) == 1 {
+		return digest.centroids[0].mean
+	}
+
+	if len(digest.centroids) == int(digest.count) {
+		rank := clamp(p, 0, 100) / 100 * (digest.count - 1)
+		lower := int(math.Floor(rank))
+		upper := int(math.Ceil(rank))
+		return digest.centroids[lower].mean + (rank-float64(lower))*(digest.centroids[upper].mean-digest.centroids[lower].mean)
+	}
+
+	target := clamp(p, 0, 100) / 100 * digest.count
+	if target <= digest.centroids[0].weight/2 {
+		return digest.min
+	}
+
+	// Interpolate between the midpoints of the centroids either side of the target
+	cumulative := 0.0
+	for i := 0; i < len(digest.centroids)-1; i++ {
+		current, next := digest.centroids[i], digest.centroids[i+1]
+		midpoint := cumulative + current.weight/2
+		nextMidpoint := cumulative + current.weight + next.weight/2
+
+		if target <= nextMidpoint {
+			fraction := (target - midpoint) / (nextMidpoint - midpoint)
+			return current.mean + fraction*(next.mean-current.mean)
+		}
+		cumulative += current.weight
+	}
+
+	return digest.max
+}
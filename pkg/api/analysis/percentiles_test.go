@@ -0,0 +1,152 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+)
+
+// Added out of order, so the percentiles have to sort them
+var percentileValues = []float64{50, 15, 40, 20, 35}
+
+func TestPercentile(t *testing.T) {
+
+	tests := []struct {
+		p        float64
+		expected float64
+	}{
+		{0, 15},
+		{25, 20},
+		{40, 29},
+		{50, 35},
+		{90, 46},
+		{100, 50},
+		{-10, 15},
+		{150, 50},
+	}
+
+	percentiles := &Percentiles{}
+	for i, value := range percentileValues {
+		percentiles.Add(int64(i), value)
+	}
+
+	for _, test := range tests {
+		if actual := percentiles.Percentile(test.p); math.Abs(actual-test.expected) > 1e-9 {
+			t.Errorf("p%g: expected %g, got %g", test.p, test.expected, actual)
+		}
+	}
+}
+
+func TestNearestRank(t *testing.T) {
+
+	tests := []struct {
+		p        float64
+		expected float64
+	}{
+		{0, 15},
+		{5, 15},
+		{30, 20},
+		{40, 20},
+		{50, 35},
+		{95, 50},
+		{100, 50},
+	}
+
+	percentiles := &Percentiles{}
+	for i, value := range percentileValues {
+		percentiles.Add(int64(i), value)
+	}
+
+	for _, test := range tests {
+		if actual := percentiles.NearestRank(test.p); actual != test.expected {
+			t.Errorf("p%g: expected %g, got %g", test.p, test.expected, actual)
+		}
+	}
+}
+
+func TestPercentilesEmpty(t *testing.T) {
+
+	percentiles := &Percentiles{}
+	if percentiles.Percentile(50) != 0 || percentiles.NearestRank(50) != 0 {
+		t.Errorf("expected zero percentiles without samples")
+	}
+
+	if NewDigest(DefaultCompression).Percentile(50) != 0 {
+		t.Errorf("expected a zero percentile from an empty digest")
+	}
+}
+
+func TestDigestSmall(t *testing.T) {
+
+	// Every value is still its own centroid, so the digest matches the exact percentiles rather than
+	// returning the largest value for the upper percentiles
+	tests := []struct {
+		p        float64
+		expected float64
+	}{
+		{0, 10},
+		{10, 19},
+		{50, 55},
+		{95, 95.5},
+		{99, 99.1},
+		{100, 100},
+	}
+
+	digest := NewDigest(DefaultCompression)
+	percentiles := &Percentiles{}
+	for i, value := range []float64{70, 10, 100, 40, 20, 90, 60, 30, 80, 50} {
+		digest.Add(int64(i), value)
+		percentiles.Add(int64(i), value)
+	}
+
+	for _, test := range tests {
+		if actual := digest.Percentile(test.p); math.Abs(actual-test.expected) > 1e-9 {
+			t.Errorf("p%g: expected %g, got %g", test.p, test.expected, actual)
+		}
+		if actual, exact := digest.Percentile(test.p), percentiles.Percentile(test.p); math.Abs(actual-exact) > 1e-9 {
+			t.Errorf("p%g: expected the exact %g, got %g", test.p, exact, actual)
+		}
+	}
+
+	single := NewDigest(DefaultCompression)
+	single.Add(0, 42)
+	if actual := single.Percentile(95); actual != 42 {
+		t.Errorf("expected 42 from a single sample, got %g", actual)
+	}
+}
+
+func TestDigestLarge(t *testing.T) {
+
+	// Each of 0 to 99999 once, in a scrambled order
+	digest := NewDigest(DefaultCompression)
+	for i := 0; i < 100000; i++ {
+		digest.Add(int64(i), float64((i*7919)%100000))
+	}
+
+	if digest.Count() != 100000 {
+		t.Fatalf("expected 100000 samples, got %d", digest.Count())
+	}
+	if len(digest.centroids) > 5*DefaultCompression {
+		t.Errorf("expected the centroids to be bounded by the compression, got %d", len(digest.centroids))
+	}
+
+	// The tails are estimated more closely than the middle
+	tests := []struct {
+		p         float64
+		tolerance float64
+	}{
+		{0, 0},
+		{1, 100},
+		{50, 500},
+		{95, 100},
+		{99, 50},
+		{99.9, 20},
+		{100, 0},
+	}
+
+	for _, test := range tests {
+		exact := test.p / 100 * 99999
+		if actual := digest.Percentile(test.p); math.Abs(actual-exact) > test.tolerance {
+			t.Errorf("p%g: expected %g within %g, got %g", test.p, exact, test.tolerance, actual)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package analysis
+
+import (
+	"pod_profiler/pkg/api/capture"
+	"sort"
+)
+
+// ContainerUsage is the summary of the cpu (millicores) and memory (bytes) of a container
+type ContainerUsage struct {
+	Name   string
+	Role   capture.ContainerRole
+	Cpu    *Summary
+	Memory *Summary
+}
+
+// RecordAggregator summarises a stream of capture records by container. Containers with the same name in
+// different pods are aggregated together, so a stream of a workload's records summarises the workload
+type RecordAggregator struct {
+	exact      bool
+	containers map[string]*ContainerUsage
+}
+
+// Create a record aggregator, exact keeps every value for exact percentiles rather than estimating them
+func NewRecordAggregator(exact bool) *RecordAggregator {
+	return &RecordAggregator{exact: exact, containers: map[string]*ContainerUsage{}}
+}
+
+// Adds the usage of each of the record's containers
+func (aggregator *RecordAggregator) AddRecord(record capture.Record) {
+	for _, container := range record.Pod.Containers {
+		aggregator.AddSample(record.DateStamp, container.Name, container.Role, container.Cpu, container.Memory)
+	}
+}
+
+// Adds a single container sample, for streams that have been read back from the results files
+func (aggregator *RecordAggregator) AddSample(timestamp int64, name string, role capture.ContainerRole, cpu, memory int64) {
+
+	usage, exists := aggregator.containers[name]
+	if !exists {
+		usage = &ContainerUsage{Name: name, Cpu: NewSummary(aggregator.exact), Memory: NewSummary(aggregator.exact)}
+		aggregator.containers[name] = usage
+	}
+
+	if role != "" {
+		usage.Role = role
+	}
+	usage.Cpu.Add(timestamp, float64(cpu))
+	usage.Memory.Add(timestamp, float64(memory))
+}
+
+// Returns the usage of a container, or nil if none of the records included it
+func (aggregator *RecordAggregator) Container(name string) *ContainerUsage {
+	return aggregator.containers[name]
+}
+
+// Returns the usage of every container ordered by name
+func (aggregator *RecordAggregator) Containers() []*ContainerUsage {
+	containers := []*ContainerUsage{}
+	for _, usage := range aggregator.containers {
+		containers = append(containers, usage)
+	}
+	sort.Slice(containers, func(i, j int) bool {
+		return containers[i].Name < containers[j].Name
+	})
+	return containers
+}
@@ -0,0 +1,111 @@
+package analysis
+
+// Rate is how quickly a series changes, in units per second
+type Rate struct {
+	count     int
+	first     float64
+	firstTime int64
+	last      float64
+	lastTime  int64
+	previous  float64
+	prevTime  int64
+}
+
+func (rate *Rate) Add(timestamp int64, value float64) {
+	if rate.count == 0 {
+		rate.first, rate.firstTime = value, timestamp
+	}
+	rate.previous, rate.prevTime = rate.last, rate.lastTime
+	rate.last, rate.lastTime = value, timestamp
+	rate.count++
+}
+
+// Returns the average rate of change from the first sample to the last, or zero if there is less than a second between them
+func (rate *Rate) Overall() float64 {
+	if rate.lastTime <= rate.firstTime {
+		return 0
+	}
+	return (rate.last - rate.first) / float64(rate.lastTime-rate.firstTime)
+}
+
+// Returns the rate of change between the last two samples, or zero if there are fewer than two
+func (rate *Rate) Latest() float64 {
+	if rate.count < 2 || rate.lastTime <= rate.prevTime {
+		return 0
+	}
+	return (rate.last - rate.previous) / float64(rate.lastTime-rate.prevTime)
+}
+
+// TimeWeighted is the average of a series weighted by how long each value lasted, so that gaps in irregular
+// sampling don't skew it towards the periods that were sampled more often. Values are interpolated linearly
+// between samples
+type TimeWeighted struct {
+	count    int
+	area     float64
+	duration float64
+	last     float64
+	lastTime int64
+}
+
+func (weighted *TimeWeighted) Add(timestamp int64, value float64) {
+
+	// Samples that arrive out of order or at the same time only replace the last value
+	if weighted.count > 0 && timestamp > weighted.lastTime {
+		elapsed := float64(timestamp - weighted.lastTime)
+		weighted.area += (weighted.last + value) / 2 * elapsed
+		weighted.duration += elapsed
+	}
+
+	weighted.last, weighted.lastTime = value, timestamp
+	weighted.count++
+}
+
+// Returns the time weighted average, which is the last value if all of the samples were taken at the same time
+func (weighted *TimeWeighted) Average() float64 {
+	if weighted.duration == 0 {
+		return weighted.last
+	}
+	return weighted.area / weighted.duration
+}
+
+// Summary aggregates every statistic of a series. Exact percentiles are only kept when requested,
+// otherwise the percentiles are estimated with a digest
+type Summary struct {
+	Moments
+	Extremes
+	Rate         Rate
+	TimeWeighted TimeWeighted
+	digest       *Digest
+	exact        *Percentiles
+}
+
+// Create a summary, exact keeps every value for exact percentiles rather than estimating them
+func NewSummary(exact bool) *Summary {
+	summary := &Summary{}
+	if exact {
+		summary.exact = &Percentiles{}
+	} else {
+		summary.digest = NewDigest(DefaultCompression)
+	}
+	return summary
+}
+
+func (summary *Summary) Add(timestamp int64, value float64) {
+	summary.Moments.Add(timestamp, value)
+	summary.Extremes.Add(timestamp, value)
+	summary.Rate.Add(timestamp, value)
+	summary.TimeWeighted.Add(timestamp, value)
+	if summary.exact != nil {
+		summary.exact.Add(timestamp, value)
+	} else {
+		summary.digest.Add(timestamp, value)
+	}
+}
+
+// Returns the percentile p, between 0 and 100. Exact summaries use the nearest rank so the result is always one of the samples
+func (summary *Summary) Percentile(p float64) float64 {
+	if summary.exact != nil {
+		return summary.exact.NearestRank(p)
+	}
+	return summary.digest.Percentile(p)
+}
@@ -0,0 +1,91 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+)
+
+type point struct {
+	timestamp int64
+	value     float64
+}
+
+func TestRate(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		points  []point
+		overall float64
+		latest  float64
+	}{
+		{"empty", nil, 0, 0},
+		{"single", []point{{0, 10}}, 0, 0},
+		{"rising", []point{{0, 10}, {10, 30}, {20, 35}}, 1.25, 0.5},
+		{"falling", []point{{0, 100}, {50, 50}, {100, 40}}, -0.6, -0.2},
+		{"same time", []point{{5, 10}, {5, 20}}, 0, 0},
+	}
+
+	for _, test := range tests {
+		rate := &Rate{}
+		for _, point := range test.points {
+			rate.Add(point.timestamp, point.value)
+		}
+
+		if math.Abs(rate.Overall()-test.overall) > 1e-9 {
+			t.Errorf("%s: expected an overall rate of %g, got %g", test.name, test.overall, rate.Overall())
+		}
+		if math.Abs(rate.Latest()-test.latest) > 1e-9 {
+			t.Errorf("%s: expected a latest rate of %g, got %g", test.name, test.latest, rate.Latest())
+		}
+	}
+}
+
+func TestTimeWeighted(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		points   []point
+		expected float64
+	}{
+		{"empty", nil, 0},
+		{"single", []point{{0, 7}}, 7},
+		{"ramp", []point{{0, 0}, {10, 10}}, 5},
+		// Three closely spaced samples don't outweigh the long gap before the last, the plain mean would be 12
+		{"irregular", []point{{0, 10}, {1, 10}, {2, 10}, {3, 10}, {100, 20}}, 14.85},
+		{"same time", []point{{0, 4}, {0, 8}, {10, 8}}, 8},
+		{"out of order", []point{{10, 5}, {5, 100}}, 100},
+	}
+
+	for _, test := range tests {
+		weighted := &TimeWeighted{}
+		for _, point := range test.points {
+			weighted.Add(point.timestamp, point.value)
+		}
+
+		if math.Abs(weighted.Average()-test.expected) > 1e-9 {
+			t.Errorf("%s: expected %g, got %g", test.name, test.expected, weighted.Average())
+		}
+	}
+}
+
+func TestSummary(t *testing.T) {
+
+	exact := NewSummary(true)
+	estimated := NewSummary(false)
+	for i, value := range []float64{70, 10, 100, 40, 20, 90, 60, 30, 80, 50} {
+		exact.Add(int64(i*10), value)
+		estimated.Add(int64(i*10), value)
+	}
+
+	// Exact summaries use the nearest rank, estimated ones interpolate
+	if actual := exact.Percentile(95); actual != 100 {
+		t.Errorf("expected an exact p95 of 100, got %g", actual)
+	}
+	if actual := estimated.Percentile(95); math.Abs(actual-95.5) > 1e-9 {
+		t.Errorf("expected an estimated p95 of 95.5, got %g", actual)
+	}
+
+	if exact.Mean() != 55 || exact.Min() != 10 || exact.Max() != 100 || exact.Count() != 10 {
+		t.Errorf("unexpected summary mean %g, min %g, max %g, count %d", exact.Mean(), exact.Min(), exact.Max(), exact.Count())
+	}
+}
@@ -1,30 +1,100 @@
 package capture
 
 import (
-	"context"
 	"encoding/csv"
 	"fmt"
-	"log"
+	"io"
 	"os"
 	"pod_profiler/pkg/api/defaults"
 	kubernetesClient "pod_profiler/pkg/api/kubernetes-client"
+	"pod_profiler/pkg/api/logging"
+	"pod_profiler/pkg/api/rollup"
+	"reflect"
 	"strconv"
+	"sync"
 	"time"
 
+	v1Batch "k8s.io/api/batch/v1"
 	v1Core "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	v1Meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
 )
 
 type Capture struct {
 	client      *kubernetesClient.Client
-	csvFile     *os.File
+	collector   collector
+	files       map[string]*os.File
 	resultsPath string
-	Deployment  string `json:"deployment"`
+	Deployment  string      `json:"deployment"`
+	Mode        CaptureMode `json:"mode"`
 	OnRecord    chan Record
+	OnEvent     chan Event
 	Errors      chan error
-	running     chan bool
+	stop        chan struct{}
+
+	// The header of each open file that was created with an older set of columns, its rows are written with only those
+	// columns so they still line up with the header
+	headers map[string][]string
+
+	// When set, each record is also sent to Samples once it has been saved so it can be shown as it is captured.
+	// Records are dropped rather than holding up the capture if the channel is full
+	Samples chan Record
+
+	// The pods of the target, by default those labelled with its name
+	podSelector labels.Selector
+
+	// When set, only the pods of the target it returns true for are captured, so the pods can be spread across gatherers
+	podFilter func(pod *v1Core.Pod) bool
+
+	// When set, only the target's pods are captured and its rollouts and autoscaling are left to another capture of the
+	// target, such as that of another shard. Once the capture has started it is changed with Reshard
+	PodsOnly bool
+	reshard  chan bool
+
+	// How long raw samples are kept before only their rollups remain, zero keeps them forever
+	RawRetention time.Duration
+
+	// The aggregators of each rollup tier for each pod, keyed by pod
+	rollups map[string][]*rollup.Aggregator
+
+	// How often each pod is polled for a new sample
+	interval time.Duration
+
+	// Pods that started after the capture and jobs that have finished
+	onPod        chan *v1Core.Pod
+	onJob        chan *v1Batch.Job
+	onAnnotation chan Annotation
+	onHPA        chan HPASample
+	onPodDone    chan string
+
+	// The peak usage of each container of the jobs that are still running, keyed by job and then container, and
+	// the number of samples they were taken from keyed by job
+	jobPeaks   map[string]map[string]*ContainerPeak
+	jobSamples map[string]int
+
+	// The job of each job pod that is being polled, and the finished jobs whose summaries are waiting for the final
+	// samples of their pods
+	podJobs      map[string]string
+	finishedJobs map[string]*v1Batch.Job
+
+	// The names of the pods that are currently being polled
+	capturing map[string]bool
+	mutex     sync.Mutex
+
+	// The deployment changes waiting for their replica sets keyed by deployment, guarded by mutex since the
+	// deployment and replica set informers call in from their own goroutines
+	pendingRollouts map[string]*pendingRollout
+
+	// Closed once the capture has stopped processing, and then once its files have been closed
+	stopped chan struct{}
+	done    chan struct{}
+
+	// The informer event handlers registered to watch for lifecycle events, and those watching the target's rollouts
+	// and autoscaling which are removed when the capture becomes PodsOnly
+	registrations         []registration
+	workloadRegistrations []registration
 }
 
 type Record struct {
@@ -35,15 +105,133 @@ type Record struct {
 type Pod struct {
 	Name       string      `json:"name"`
 	Containers []Container `json:"containers"`
+
+	// The hash of the workload revision the pod was created from
+	Revision string `json:"revision,omitempty"`
+
+	// Only populated by the kubelet collector
+	Network *Network `json:"network,omitempty"`
+	Volumes []Volume `json:"volumes,omitempty"`
 }
 
 type Container struct {
-	Name   string `csv:"name"`
-	Cpu    int64  `csv:"cpu"`
-	Memory int64  `csv:"memory"`
+	Name        string        `csv:"name"`
+	Cpu         int64         `csv:"cpu"`
+	Memory      int64         `csv:"memory"`
+	Role        ContainerRole `csv:"role"`
+	Image       string        `csv:"image"`
+	ImageDigest string        `csv:"imagedigest"`
+
+	// Only populated by the kubelet collector
+	Stats *ContainerStats `json:"stats,omitempty"`
+
+	// Only populated by the cadvisor collector
+	Throttling *Throttling `json:"throttling,omitempty"`
+}
+
+// The additional container stats reported by the kubelet Summary API
+type ContainerStats struct {
+	RSS             int64 `csv:"rss"`
+	PageFaults      int64 `csv:"pagefaults"`
+	MajorPageFaults int64 `csv:"majorpagefaults"`
+	Rootfs          int64 `csv:"rootfs"`
+	Logs            int64 `csv:"logs"`
+}
+
+// The CFS throttling of a container since the previous sample
+type Throttling struct {
+	Periods          int64   `csv:"periods"`
+	ThrottledPeriods int64   `csv:"throttledperiods"`
+	Ratio            float64 `csv:"ratio"`
+}
+
+// The network usage of a pod, the counters are cumulative since the pod started
+type Network struct {
+	Interfaces []Interface `json:"interfaces"`
 }
 
-func New(client *kubernetesClient.Client, resultsPath, deploymentName string) (*Capture, error) {
+type Interface struct {
+	Name     string `csv:"interface"`
+	RxBytes  int64  `csv:"rxbytes"`
+	RxErrors int64  `csv:"rxerrors"`
+	TxBytes  int64  `csv:"txbytes"`
+	TxErrors int64  `csv:"txerrors"`
+}
+
+type Volume struct {
+	Name       string `csv:"name"`
+	Used       int64  `csv:"used"`
+	Capacity   int64  `csv:"capacity"`
+	InodesUsed int64  `csv:"inodesused"`
+}
+
+// The suffixes of the files that each pod's results are written to
+const (
+	FileSuffix_Usage       string = ".csv"
+	FileSuffix_Kubelet     string = ".kubelet.csv"
+	FileSuffix_Network     string = ".network.csv"
+	FileSuffix_Volumes     string = ".volumes.csv"
+	FileSuffix_Throttling  string = ".throttling.csv"
+	FileSuffix_Events      string = ".events.csv"
+	FileSuffix_Job         string = ".job.json"
+	FileSuffix_Annotations string = ".annotations.csv"
+	FileSuffix_HPA         string = ".hpa.csv"
+	FileSuffix_Pods        string = ".pods.csv"
+)
+
+// The header row written to each new results file
+var fileHeaders = map[string][]string{
+	FileSuffix_Usage:       {"time", "name", "cpu", "memory", "role", "image", "imagedigest", "revision"},
+	FileSuffix_Kubelet:     {"time", "name", "rss", "pagefaults", "majorpagefaults", "rootfs", "logs"},
+	FileSuffix_Network:     {"time", "interface", "rxbytes", "rxerrors", "txbytes", "txerrors"},
+	FileSuffix_Volumes:     {"time", "name", "used", "capacity", "inodesused"},
+	FileSuffix_Throttling:  {"time", "name", "periods", "throttledperiods", "ratio"},
+	FileSuffix_Events:      {"time", "name", "type", "reason", "exitcode", "message"},
+	FileSuffix_Annotations: {"time", "workload", "type", "revision", "message"},
+	FileSuffix_HPA:         {"time", "name", "currentreplicas", "desiredreplicas", "metric", "type", "current", "target"},
+	FileSuffix_Pods:        {"time", "pod", "container", "role", "cpurequest", "cpulimit", "memoryrequest", "memorylimit"},
+}
+
+func init() {
+	for _, tier := range rollup.Tiers {
+		fileHeaders[tier.Suffix] = rollup.Header
+	}
+}
+
+func New(client *kubernetesClient.Client, resultsPath, deploymentName string, collectorType CollectorType, mode CaptureMode) (*Capture, error) {
+
+	if deploymentName == "" {
+		return nil, fmt.Errorf("deployment name can not be blank")
+
+	}
+
+	selector, err := labels.Parse(defaults.KUBERNETES_NAME_LABEL + "=" + deploymentName)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWithSelector(client, resultsPath, deploymentName, selector, collectorType, mode)
+}
+
+// Create a capture of only the pods labelled with the target's name that the filter returns true for, such as the
+// pods that belong to one shard
+func NewWithFilter(client *kubernetesClient.Client, resultsPath, deploymentName string, filter func(pod *v1Core.Pod) bool, collectorType CollectorType, mode CaptureMode) (*Capture, error) {
+
+	selector, err := labels.Parse(defaults.KUBERNETES_NAME_LABEL + "=" + deploymentName)
+	if err != nil {
+		return nil, err
+	}
+
+	return newCapture(client, resultsPath, deploymentName, selector, filter, collectorType, mode)
+}
+
+// Create a capture of the pods that match the selector rather than those labelled with the target's name,
+// the results are still written under the given name
+func NewWithSelector(client *kubernetesClient.Client, resultsPath, deploymentName string, selector labels.Selector, collectorType CollectorType, mode CaptureMode) (*Capture, error) {
+	return newCapture(client, resultsPath, deploymentName, selector, nil, collectorType, mode)
+}
+
+func newCapture(client *kubernetesClient.Client, resultsPath, deploymentName string, selector labels.Selector, filter func(pod *v1Core.Pod) bool, collectorType CollectorType, mode CaptureMode) (*Capture, error) {
 
 	if deploymentName == "" {
 		return nil, fmt.Errorf("deployment name can not be blank")
@@ -53,22 +241,57 @@ func New(client *kubernetesClient.Client, resultsPath, deploymentName string) (*
 		return nil, fmt.Errorf("kubernetes client can not be nil")
 	}
 
+	// Jobs can finish before a slower poll would see them, so poll their pods more frequently
+	interval := defaults.POLL_INTERVAL
+	if mode == CaptureMode_Job {
+		interval = defaults.JOB_POLL_INTERVAL
+	}
+
+	collector, err := newCollector(client, collectorType, interval)
+	if err != nil {
+		return nil, err
+	}
+
 	capture := &Capture{
-		client:      client,
-		Deployment:  deploymentName,
-		resultsPath: resultsPath,
-		OnRecord:    make(chan Record),
-		Errors:      make(chan error),
-		running:     make(chan bool),
+		client:       client,
+		collector:    collector,
+		files:        map[string]*os.File{},
+		headers:      map[string][]string{},
+		Deployment:   deploymentName,
+		resultsPath:  resultsPath,
+		Mode:         mode,
+		OnRecord:     make(chan Record),
+		OnEvent:      make(chan Event),
+		Errors:       make(chan error),
+		stop:         make(chan struct{}),
+		reshard:      make(chan bool),
+		podSelector:  selector,
+		podFilter:    filter,
+		stopped:      make(chan struct{}),
+		done:         make(chan struct{}),
+		interval:     interval,
+		onPod:        make(chan *v1Core.Pod),
+		onJob:        make(chan *v1Batch.Job),
+		onAnnotation: make(chan Annotation),
+		onHPA:        make(chan HPASample),
+		onPodDone:    make(chan string),
+		rollups:      map[string][]*rollup.Aggregator{},
+		jobPeaks:     map[string]map[string]*ContainerPeak{},
+		jobSamples:   map[string]int{},
+		podJobs:      map[string]string{},
+		finishedJobs: map[string]*v1Batch.Job{},
+		capturing:    map[string]bool{},
+
+		pendingRollouts: map[string]*pendingRollout{},
 	}
 
 	pods, err := capture.GetPods()
 	if err != nil {
-		capture.Errors <- err
+		return nil, err
 	}
 
 	for _, pod := range pods {
-		err := capture.createFile(pod.GetName())
+		_, err := capture.file(pod.GetName(), FileSuffix_Usage)
 		if err != nil {
 			return nil, err
 		}
@@ -79,7 +302,7 @@ func New(client *kubernetesClient.Client, resultsPath, deploymentName string) (*
 }
 
 func (capture *Capture) GetPods() ([]*v1Core.Pod, error) {
-	label, err := labels.Parse(defaults.KUBERNETES_NAME_LABEL + "=" + capture.Deployment)
+	label, err := capture.selector()
 	if err != nil {
 		return nil, err
 	}
@@ -89,149 +312,560 @@ func (capture *Capture) GetPods() ([]*v1Core.Pod, error) {
 		return nil, err
 	}
 
-	return pods, nil
+	if capture.podFilter == nil {
+		return pods, nil
+	}
+
+	filtered := []*v1Core.Pod{}
+	for _, pod := range pods {
+		if capture.podFilter(pod) {
+			filtered = append(filtered, pod)
+		}
+	}
+	return filtered, nil
+
+}
 
+// Returns the label selector that matches the pods of the captured deployment
+func (capture *Capture) selector() (labels.Selector, error) {
+	return capture.podSelector, nil
 }
 
+// Returns true if the pod is one of the target's pods that this capture records
+func (capture *Capture) matches(pod *v1Core.Pod, selector labels.Selector) bool {
+	return selector.Matches(labels.Set(pod.GetLabels())) && (capture.podFilter == nil || capture.podFilter(pod))
+}
+
+// Returns true if the workload belongs to the target, either by being named after it or by matching its selector
+func (capture *Capture) owns(workload v1Meta.Object, selector labels.Selector) bool {
+	return workload.GetName() == capture.Deployment || selector.Matches(labels.Set(workload.GetLabels()))
+}
+
+// Starts the capture in the background, it can be stopped with StopCapture as soon as this returns
 func (capture *Capture) StartCapture() {
 
-	log.Default().Printf("Starting capture of %s\n", capture.Deployment)
+	logging.Info().Printf("Starting capture of %s\n", capture.Deployment)
 
 	go capture.process()
+}
+
+// Registers the event handlers on the target's rollouts and autoscaling
+func (capture *Capture) watchWorkloads() {
+
+	err := capture.watchRollouts()
+	if err != nil {
+		logging.Error().Printf("error: %s\n", err.Error())
+	}
+
+	err = capture.watchHPAs()
+	if err != nil {
+		logging.Error().Printf("error: %s\n", err.Error())
+	}
+}
+
+// Registers the event handlers and starts polling the target's pods
+func (capture *Capture) start() {
+
+	err := capture.watchEvents()
+	if err != nil {
+		logging.Error().Printf("error: %s\n", err.Error())
+	}
+
+	err = capture.watchPods()
+	if err != nil {
+		logging.Error().Printf("error: %s\n", err.Error())
+	}
+
+	if !capture.PodsOnly {
+		capture.watchWorkloads()
+	}
 
-	capture.running <- true
+	if capture.Mode == CaptureMode_Job {
+		err = capture.watchJobs()
+		if err != nil {
+			logging.Error().Printf("error: %s\n", err.Error())
+		}
+	}
+
+	pods, err := capture.GetPods()
+	if err != nil {
+		logging.Error().Printf("error: %s\n", err.Error())
+	}
+
+	for _, pod := range pods {
+		capture.capturePod(pod)
+	}
 }
 
 func (capture *Capture) process() {
 
+	// Raw samples are only pruned when there is a retention, otherwise the ticker never fires
+	var prune <-chan time.Time
+	if capture.RawRetention > 0 {
+		ticker := time.NewTicker(defaults.PRUNE_INTERVAL)
+		defer ticker.Stop()
+		prune = ticker.C
+	}
+
+	// Starting here rather than on a message means a stop can never be received before the start
+	capture.start()
+
 	for {
 		select {
 
-		case running := <-capture.running:
+		case <-capture.stop:
+			capture.unwatchEvents()
 
-			if running {
-				pods, err := capture.GetPods()
-				if err != nil {
-					capture.Errors <- err
-				}
+			// Let the pod captures and event handlers know that nothing is receiving anymore
+			close(capture.stopped)
 
-				for _, pod := range pods {
-					go capture.startContainerCapture(pod)
-				}
-			} else {
-				err := capture.csvFile.Close()
+			for podName := range capture.rollups {
+				capture.flushRollups(podName)
+			}
+
+			// Nothing will be polled anymore, so summarise the jobs that were waiting for their pods
+			capture.saveFinishedJobs(true)
+
+			for _, file := range capture.files {
+				err := file.Close()
 				if err != nil {
-					log.Default().Printf("error: %s\n", err.Error())
-					capture.Errors <- err
+					logging.Error().Printf("error: %s\n", err.Error())
 				}
-				return
 			}
+			close(capture.done)
+			return
 
 		case record := <-capture.OnRecord:
-			log.Default().Printf("on record %s\n", record.Pod.Name)
+			logging.Debug().Printf("on record %s\n", record.Pod.Name)
 			err := capture.saveRecord(record)
 			if err != nil {
-				log.Default().Printf("error: %s\n", err.Error())
-				capture.Errors <- err
+				logging.Error().Printf("error: %s\n", err.Error())
+			}
+
+			if capture.Mode == CaptureMode_Job {
+				capture.trackJobPeaks(record)
+			}
+
+			capture.rollupRecord(record)
+
+			if capture.Samples != nil {
+				select {
+				case capture.Samples <- record:
+				default:
+				}
+			}
+
+		case annotation := <-capture.onAnnotation:
+			logging.Debug().Printf("on annotation %s %s\n", annotation.Workload, annotation.Message)
+			err := capture.writeRows(capture.Deployment, FileSuffix_Annotations, [][]string{annotation.row()})
+			if err != nil {
+				logging.Error().Printf("error: %s\n", err.Error())
+			}
+
+		case sample := <-capture.onHPA:
+			err := capture.writeRows(capture.Deployment, FileSuffix_HPA, sample.rows())
+			if err != nil {
+				logging.Error().Printf("error: %s\n", err.Error())
+			}
+
+		case pod := <-capture.onPod:
+			capture.capturePod(pod)
+
+		case job := <-capture.onJob:
+			logging.Debug().Printf("on job finished %s\n", job.GetName())
+			capture.finishedJobs[job.GetName()] = job
+			capture.saveFinishedJobs(false)
+
+		case event := <-capture.OnEvent:
+			logging.Debug().Printf("on event %s %s %s\n", event.Pod, event.Type, event.Reason)
+			err := capture.writeRows(event.Pod, FileSuffix_Events, [][]string{event.row()})
+			if err != nil {
+				logging.Error().Printf("error: %s\n", err.Error())
+			}
+
+		case podsOnly := <-capture.reshard:
+			if podsOnly != capture.PodsOnly {
+				capture.PodsOnly = podsOnly
+				if podsOnly {
+					capture.unwatchWorkloads()
+				} else {
+					capture.watchWorkloads()
+				}
+			}
+
+			// Start polling the pods the filter now lets through, those it no longer does stop at their next poll
+			pods, err := capture.GetPods()
+			if err != nil {
+				logging.Error().Printf("error: %s\n", err.Error())
 			}
+			for _, pod := range pods {
+				capture.capturePod(pod)
+			}
+
+		case podName := <-capture.onPodDone:
+			capture.flushRollups(podName)
+			delete(capture.podJobs, podName)
+			capture.saveFinishedJobs(false)
+
+		case <-prune:
+			capture.pruneRawSamples()
 
 		case err := <-capture.Errors:
-			log.Default().Printf("error: %s", err.Error())
-			capture.Errors <- err
+			logging.Error().Printf("error: %s", err.Error())
 		}
 	}
 }
 
+// Stops a started capture and waits for its results files to be closed
 func (capture *Capture) StopCapture() {
-	capture.running <- false
+	capture.stop <- struct{}{}
+	<-capture.done
+}
+
+// Applies a change to the pod filter's choice of pods and to PodsOnly without restarting the capture, such as when the
+// shards change. Only the pods whose owner changed are started or stopped
+func (capture *Capture) Reshard(podsOnly bool) {
+	select {
+	case capture.reshard <- podsOnly:
+	case <-capture.stopped:
+	}
+}
+
+// Returns a channel that is closed once the capture has stopped, after which nothing receives from its channels
+func (capture *Capture) Stopped() <-chan struct{} {
+	return capture.stopped
+}
+
+// Starts polling the pod unless it is already being polled or has already finished
+func (capture *Capture) capturePod(pod *v1Core.Pod) {
+
+	if podFinished(pod) {
+		return
+	}
+
+	capture.mutex.Lock()
+	if capture.capturing[pod.GetName()] {
+		capture.mutex.Unlock()
+		return
+	}
+	capture.capturing[pod.GetName()] = true
+	capture.mutex.Unlock()
+
+	if job := jobName(pod); capture.Mode == CaptureMode_Job && job != "" {
+		capture.podJobs[pod.GetName()] = job
+	}
+
+	// Record which pods belong to the target along with their requests and limits, so the results can be grouped by target
+	err := capture.writeRows(capture.Deployment, FileSuffix_Pods, podRows(pod))
+	if err != nil {
+		logging.Error().Printf("error: %s\n", err.Error())
+	}
+
+	go capture.startContainerCapture(pod)
+}
+
+// Registers the event handler on the pod informer so that pods which start running after the capture started are also polled
+func (capture *Capture) watchPods() error {
+
+	selector, err := capture.selector()
+	if err != nil {
+		return err
+	}
+
+	onPod := func(obj interface{}) {
+		pod, ok := obj.(*v1Core.Pod)
+		if !ok || pod.Status.Phase != v1Core.PodRunning || !capture.matches(pod, selector) {
+			return
+		}
+
+		select {
+		case capture.onPod <- pod:
+		case <-capture.stopped:
+		}
+	}
+
+	informer := capture.client.Cache.Informers.Pod.Informer()
+	handle, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: onPod,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			onPod(newObj)
+		},
+	})
+	if err != nil {
+		return err
+	}
+	capture.registrations = append(capture.registrations, registration{informer, handle})
+
+	return nil
+}
+
+// Returns true if all of the pod's containers have terminated and it will not run again
+func podFinished(pod *v1Core.Pod) bool {
+	return pod.Status.Phase == v1Core.PodSucceeded || pod.Status.Phase == v1Core.PodFailed
 }
 
 func (capture *Capture) startContainerCapture(pod *v1Core.Pod) error {
 
-	var lastCapture v1Meta.Time
+	defer func() {
+		capture.mutex.Lock()
+		delete(capture.capturing, pod.GetName())
+		capture.mutex.Unlock()
+
+		// Close the pod's open rollup buckets, since no more samples will fall into them
+		select {
+		case capture.onPodDone <- pod.GetName():
+		case <-capture.stopped:
+		}
+	}()
+
+	var lastCapture int64
 
 	for {
 
-		data, err := capture.client.Metrics.Pod().Get(context.Background(), pod.GetName(), v1Meta.GetOptions{})
+		select {
+		case <-capture.stopped:
+			return nil
+		default:
+		}
+
+		// Refresh the pod from the cache so we see it being scheduled, and stop once it has been deleted
+		current, err := capture.client.Cache.Pod().Get(pod.GetName())
 		if err != nil {
 			if apierrors.IsNotFound(err) {
-				time.Sleep(10 * time.Second)
-				continue
+				return nil
 			}
-			capture.Errors <- err
+		} else {
+			pod = current
 		}
 
-		if data.Timestamp.Time == lastCapture.Time {
-			time.Sleep(10 * time.Second)
-			continue
+		// Stop once the pod belongs to another shard, which polls it from now on
+		if capture.podFilter != nil && !capture.podFilter(pod) {
+			return nil
 		}
 
-		lastCapture = data.Timestamp
+		// Once the pod has finished we take one last sample, so the final usage of short lived pods is kept
+		finished := podFinished(pod)
 
-		record := Record{
-			DateStamp: data.Timestamp.Unix(),
-			Pod: Pod{
-				Name: pod.GetName(),
-			},
-		}
+		record, err := capture.collector.collect(pod)
+		if err != nil {
+			capture.sendError(err)
+		} else if record != nil && record.DateStamp != lastCapture {
+			lastCapture = record.DateStamp
 
-		for _, container := range data.Containers {
-			containerResult := Container{
-				Name:   container.Name,
-				Cpu:    container.Usage.Cpu().MilliValue(),
-				Memory: container.Usage.Memory().Value(),
+			annotateContainers(pod, record)
+			record.Pod.Revision = podRevision(pod)
+
+			select {
+			case capture.OnRecord <- *record:
+			case <-capture.stopped:
+				return nil
 			}
+		}
 
-			record.Pod.Containers = append(record.Pod.Containers, containerResult)
+		if finished {
+			return nil
 		}
-		capture.OnRecord <- record
+
+		time.Sleep(capture.interval)
+	}
+}
+
+// Sends the error to the capture unless it has been stopped
+func (capture *Capture) sendError(err error) {
+	select {
+	case capture.Errors <- err:
+	case <-capture.stopped:
 	}
 }
 
-func (capture *Capture) createFile(podName string) error {
+// Returns the open results file for the given pod and suffix, creating the file and writing its header if it doesn't exist
+func (capture *Capture) file(podName string, suffix string) (*os.File, error) {
+
+	filename := fmt.Sprintf("%s/%s%s", capture.resultsPath, podName, suffix)
+
+	if file, exists := capture.files[filename]; exists {
+		return file, nil
+	}
 
-	filename := fmt.Sprintf("%s/%s.csv", capture.resultsPath, podName)
 	flags := os.O_APPEND | os.O_WRONLY
+	writeHeader := false
 
+	delete(capture.headers, filename)
 	if _, err := os.Stat(filename); os.IsNotExist(err) {
 		flags = os.O_APPEND | os.O_WRONLY | os.O_CREATE
+		writeHeader = true
+	} else {
+		header, err := readHeader(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(header) == 0 {
+			writeHeader = true
+		} else if !reflect.DeepEqual(header, fileHeaders[suffix]) {
+			capture.headers[filename] = header
+		}
 	}
 
-	var err error
-	capture.csvFile, err = os.OpenFile(filename, flags, 0777)
+	file, err := os.OpenFile(filename, flags, 0777)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	writer := csv.NewWriter(capture.csvFile)
+	capture.files[filename] = file
+
+	writer := csv.NewWriter(file)
 	defer writer.Flush()
 
-	if flags == os.O_APPEND|os.O_WRONLY|os.O_CREATE {
-		headerRow := []string{"time", "name", "cpu", "memory"}
-		err = writer.Write(headerRow)
+	if writeHeader {
+		err = writer.Write(fileHeaders[suffix])
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	return nil
+	return file, nil
 
 }
 
-func (capture *Capture) saveRecord(record Record) error {
+// Writes the rows to the results file for the given pod and suffix
+func (capture *Capture) writeRows(podName string, suffix string, rows [][]string) error {
+
+	if len(rows) == 0 {
+		return nil
+	}
 
-	writer := csv.NewWriter(capture.csvFile)
+	file, err := capture.file(podName, suffix)
+	if err != nil {
+		return err
+	}
+
+	if header, exists := capture.headers[file.Name()]; exists {
+		rows = projectRows(rows, fileHeaders[suffix], header)
+	}
+
+	writer := csv.NewWriter(file)
 	defer writer.Flush()
 
+	return writer.WriteAll(rows)
+}
+
+// Returns the first row of a results file
+func readHeader(filename string) ([]string, error) {
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	return header, err
+}
+
+// Rearranges rows written with the columns of one header into the columns of another, leaving out the columns the other
+// header doesn't have and leaving blank the ones the rows don't have
+func projectRows(rows [][]string, from, to []string) [][]string {
+
+	columns := map[string]int{}
+	for i, name := range from {
+		columns[name] = i
+	}
+
+	projected := make([][]string, len(rows))
+	for i, row := range rows {
+		projected[i] = make([]string, len(to))
+		for j, name := range to {
+			if column, exists := columns[name]; exists && column < len(row) {
+				projected[i][j] = row[column]
+			}
+		}
+	}
+	return projected
+}
+
+func (capture *Capture) saveRecord(record Record) error {
+
+	usageRows := [][]string{}
+	kubeletRows := [][]string{}
+	networkRows := [][]string{}
+	volumeRows := [][]string{}
+	throttlingRows := [][]string{}
+
+	timestamp := strconv.FormatInt(record.DateStamp, 10)
+
 	for _, container := range record.Pod.Containers {
-		row := []string{
-			strconv.FormatInt(time.Now().Unix(), 10),
+		usageRows = append(usageRows, []string{
+			timestamp,
 			container.Name,
 			strconv.FormatInt(container.Cpu, 10),
 			strconv.FormatInt(container.Memory, 10),
+			string(container.Role),
+			container.Image,
+			container.ImageDigest,
+			record.Pod.Revision,
+		})
+
+		if container.Stats != nil {
+			kubeletRows = append(kubeletRows, []string{
+				timestamp,
+				container.Name,
+				strconv.FormatInt(container.Stats.RSS, 10),
+				strconv.FormatInt(container.Stats.PageFaults, 10),
+				strconv.FormatInt(container.Stats.MajorPageFaults, 10),
+				strconv.FormatInt(container.Stats.Rootfs, 10),
+				strconv.FormatInt(container.Stats.Logs, 10),
+			})
 		}
 
-		err := writer.Write(row)
+		if container.Throttling != nil {
+			throttlingRows = append(throttlingRows, []string{
+				timestamp,
+				container.Name,
+				strconv.FormatInt(container.Throttling.Periods, 10),
+				strconv.FormatInt(container.Throttling.ThrottledPeriods, 10),
+				strconv.FormatFloat(container.Throttling.Ratio, 'f', 4, 64),
+			})
+		}
+	}
+
+	if record.Pod.Network != nil {
+		for _, iface := range record.Pod.Network.Interfaces {
+			networkRows = append(networkRows, []string{
+				timestamp,
+				iface.Name,
+				strconv.FormatInt(iface.RxBytes, 10),
+				strconv.FormatInt(iface.RxErrors, 10),
+				strconv.FormatInt(iface.TxBytes, 10),
+				strconv.FormatInt(iface.TxErrors, 10),
+			})
+		}
+	}
+
+	for _, volume := range record.Pod.Volumes {
+		volumeRows = append(volumeRows, []string{
+			timestamp,
+			volume.Name,
+			strconv.FormatInt(volume.Used, 10),
+			strconv.FormatInt(volume.Capacity, 10),
+			strconv.FormatInt(volume.InodesUsed, 10),
+		})
+	}
+
+	for suffix, rows := range map[string][][]string{
+		FileSuffix_Usage:      usageRows,
+		FileSuffix_Kubelet:    kubeletRows,
+		FileSuffix_Network:    networkRows,
+		FileSuffix_Volumes:    volumeRows,
+		FileSuffix_Throttling: throttlingRows,
+	} {
+		err := capture.writeRows(record.Pod.Name, suffix, rows)
 		if err != nil {
 			return err
 		}
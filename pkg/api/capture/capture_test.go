@@ -0,0 +1,103 @@
+package capture
+
+import (
+	"pod_profiler/pkg/api/defaults"
+	kubernetesClient "pod_profiler/pkg/api/kubernetes-client"
+	"pod_profiler/pkg/api/kubernetes-client/fake"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	v1Core "k8s.io/api/core/v1"
+	v1Meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+)
+
+// A collector with no samples, so the pods are polled without a metrics API
+type emptyCollector struct{}
+
+func (c *emptyCollector) collect(pod *v1Core.Pod) (*Record, error) {
+	return nil, nil
+}
+
+// Returns the names of the pods the capture is polling
+func polling(capture *Capture) []string {
+	capture.mutex.Lock()
+	defer capture.mutex.Unlock()
+
+	names := []string{}
+	for name := range capture.capturing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Waits for the capture to be polling the pods, or fails the test if it isn't in time
+func waitForPolling(t *testing.T, capture *Capture, expected []string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !reflect.DeepEqual(polling(capture), expected) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected to be polling %v, got %v", expected, polling(capture))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// When the shards change the running capture starts polling the pods its shard gained and stops polling those it lost
+func TestReshard(t *testing.T) {
+
+	objects := []runtime.Object{}
+	for _, name := range []string{"api-0", "api-1", "api-2", "api-3"} {
+		objects = append(objects, &v1Core.Pod{
+			ObjectMeta: v1Meta.ObjectMeta{Name: name, Namespace: "ns", Labels: map[string]string{defaults.KUBERNETES_NAME_LABEL: "api"}},
+			Status:     v1Core.PodStatus{Phase: v1Core.PodRunning},
+		})
+	}
+	client := fake.NewClientBuilder().WithClientsetRuntimeObjects(objects...).Build()
+
+	factory := informers.NewSharedInformerFactoryWithOptions(client.Clientset, 0, informers.WithNamespace("ns"))
+	client.Cache = &kubernetesClient.Cache{
+		Informers: &kubernetesClient.Informers{Pod: factory.Core().V1().Pods()},
+		Listers:   &kubernetesClient.Listers{},
+		Namespace: "ns",
+	}
+	client.Cache.Listers.Pod = client.Cache.Informers.Pod.Lister()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	factory.Start(stop)
+	factory.WaitForCacheSync(stop)
+
+	// The pods this shard owns, as the ring would decide them
+	owned := map[string]bool{"api-0": true, "api-1": true}
+	mutex := sync.Mutex{}
+	filter := func(pod *v1Core.Pod) bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return owned[pod.GetName()]
+	}
+
+	capture, err := NewWithFilter(client, t.TempDir(), "api", filter, CollectorType_MetricsServer, CaptureMode_Workload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	capture.collector = &emptyCollector{}
+	capture.interval = 10 * time.Millisecond
+	capture.PodsOnly = true
+
+	capture.StartCapture()
+	defer capture.StopCapture()
+
+	waitForPolling(t, capture, []string{"api-0", "api-1"})
+
+	mutex.Lock()
+	owned = map[string]bool{"api-1": true, "api-2": true, "api-3": true}
+	mutex.Unlock()
+	capture.Reshard(true)
+
+	waitForPolling(t, capture, []string{"api-1", "api-2", "api-3"})
+}
@@ -0,0 +1,414 @@
+package capture
+
+import (
+	"context"
+	"fmt"
+	"math"
+	kubernetesClient "pod_profiler/pkg/api/kubernetes-client"
+	"pod_profiler/pkg/api/prometheus"
+	"sync"
+	"time"
+
+	v1Core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1Meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CollectorType is the source that usage samples are read from
+type CollectorType string
+
+const (
+	CollectorType_MetricsServer CollectorType = "metrics-server"
+	CollectorType_Kubelet       CollectorType = "kubelet"
+	CollectorType_Cadvisor      CollectorType = "cadvisor"
+)
+
+// collector reads a single usage sample for a pod. A nil record with a nil error
+// indicates that there is no sample available for the pod yet
+type collector interface {
+	collect(pod *v1Core.Pod) (*Record, error)
+}
+
+// Creates the collector for the given collector type that is polled at the given interval
+func newCollector(client *kubernetesClient.Client, collectorType CollectorType, interval time.Duration) (collector, error) {
+	switch collectorType {
+	case CollectorType_MetricsServer, "":
+		return &metricsServerCollector{client: client}, nil
+	case CollectorType_Kubelet:
+		return &kubeletCollector{client: client}, nil
+	case CollectorType_Cadvisor:
+		return &cadvisorCollector{client: client, interval: interval, scrapes: map[string]*cadvisorScrape{}, previous: map[string]cadvisorCounters{}}, nil
+	default:
+		return nil, fmt.Errorf("unknown collector type: %s", collectorType)
+	}
+}
+
+// Collector samples the usage of pods the same way a capture does, for tools that show the usage rather than saving it
+type Collector struct {
+	collector collector
+}
+
+// Create a collector that reads from the given source every interval
+func NewCollector(client *kubernetesClient.Client, collectorType CollectorType, interval time.Duration) (*Collector, error) {
+	collector, err := newCollector(client, collectorType, interval)
+	if err != nil {
+		return nil, err
+	}
+	return &Collector{collector: collector}, nil
+}
+
+// Returns the current usage of the pod's containers, or nil if there is no sample available for the pod yet
+func (c *Collector) Collect(pod *v1Core.Pod) (*Record, error) {
+
+	record, err := c.collector.collect(pod)
+	if err != nil || record == nil {
+		return nil, err
+	}
+
+	annotateContainers(pod, record)
+	record.Pod.Revision = podRevision(pod)
+
+	return record, nil
+}
+
+// metricsServerCollector reads cpu and working set memory from the metrics.k8s.io API
+type metricsServerCollector struct {
+	client *kubernetesClient.Client
+}
+
+func (c *metricsServerCollector) collect(pod *v1Core.Pod) (*Record, error) {
+
+	data, err := c.client.Metrics.Pod().Get(context.Background(), pod.GetName(), v1Meta.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	record := &Record{
+		DateStamp: data.Timestamp.Unix(),
+		Pod: Pod{
+			Name: pod.GetName(),
+		},
+	}
+
+	for _, container := range data.Containers {
+		record.Pod.Containers = append(record.Pod.Containers, Container{
+			Name:   container.Name,
+			Cpu:    container.Usage.Cpu().MilliValue(),
+			Memory: container.Usage.Memory().Value(),
+		})
+	}
+
+	return record, nil
+}
+
+// kubeletCollector reads the kubelet Summary API of the pod's node through the API server node proxy
+type kubeletCollector struct {
+	client *kubernetesClient.Client
+}
+
+func (c *kubeletCollector) collect(pod *v1Core.Pod) (*Record, error) {
+
+	// The pod has not been scheduled yet, so there is no kubelet to ask
+	if pod.Spec.NodeName == "" {
+		return nil, nil
+	}
+
+	summary, err := c.client.Kubelet.Summary(context.Background(), pod.Spec.NodeName)
+	if err != nil {
+		return nil, err
+	}
+
+	return summaryRecord(pod, summary.Pod(pod.GetNamespace(), pod.GetName())), nil
+}
+
+// Returns the record for the pod's stats from the kubelet summary, or nil if there are no container stats
+func summaryRecord(pod *v1Core.Pod, stats *kubernetesClient.PodStats) *Record {
+
+	if stats == nil || len(stats.Containers) == 0 {
+		return nil
+	}
+
+	record := &Record{
+		Pod: Pod{
+			Name: pod.GetName(),
+		},
+	}
+
+	for _, container := range stats.Containers {
+		result := Container{
+			Name:  container.Name,
+			Stats: &ContainerStats{},
+		}
+
+		if container.CPU != nil {
+			result.Cpu = int64(value(container.CPU.UsageNanoCores) / 1000000)
+			if container.CPU.Time.Unix() > record.DateStamp {
+				record.DateStamp = container.CPU.Time.Unix()
+			}
+		}
+
+		if container.Memory != nil {
+			result.Memory = int64(value(container.Memory.WorkingSetBytes))
+			result.Stats.RSS = int64(value(container.Memory.RSSBytes))
+			result.Stats.PageFaults = int64(value(container.Memory.PageFaults))
+			result.Stats.MajorPageFaults = int64(value(container.Memory.MajorPageFaults))
+		}
+
+		if container.Rootfs != nil {
+			result.Stats.Rootfs = int64(value(container.Rootfs.UsedBytes))
+		}
+
+		if container.Logs != nil {
+			result.Stats.Logs = int64(value(container.Logs.UsedBytes))
+		}
+
+		record.Pod.Containers = append(record.Pod.Containers, result)
+	}
+
+	// Containers without cpu stats are stamped with the time of their memory stats, then the pod's cpu stats, so
+	// the sample isn't dropped for having no time
+	if record.DateStamp == 0 {
+		for _, container := range stats.Containers {
+			if container.Memory != nil && container.Memory.Time.Unix() > record.DateStamp {
+				record.DateStamp = container.Memory.Time.Unix()
+			}
+		}
+	}
+	if record.DateStamp <= 0 && stats.CPU != nil {
+		record.DateStamp = stats.CPU.Time.Unix()
+	}
+	if record.DateStamp <= 0 {
+		record.DateStamp = time.Now().Unix()
+	}
+
+	if stats.Network != nil {
+		record.Pod.Network = &Network{}
+		for _, iface := range stats.Network.Interfaces {
+			record.Pod.Network.Interfaces = append(record.Pod.Network.Interfaces, Interface{
+				Name:     iface.Name,
+				RxBytes:  int64(value(iface.RxBytes)),
+				RxErrors: int64(value(iface.RxErrors)),
+				TxBytes:  int64(value(iface.TxBytes)),
+				TxErrors: int64(value(iface.TxErrors)),
+			})
+		}
+	}
+
+	for _, volume := range stats.Volumes {
+		record.Pod.Volumes = append(record.Pod.Volumes, Volume{
+			Name:       volume.Name,
+			Used:       int64(value(volume.UsedBytes)),
+			Capacity:   int64(value(volume.CapacityBytes)),
+			InodesUsed: int64(value(volume.InodesUsed)),
+		})
+	}
+
+	return record
+}
+
+// cadvisorCollector scrapes the kubelet cAdvisor endpoint of the pod's node through the API server node proxy.
+// Cpu usage and throttling are derived from the counters of consecutive scrapes, so the first scrape of each
+// container does not produce a sample. Each node is scraped once per poll and the scrape is shared by its pods
+type cadvisorCollector struct {
+	client   *kubernetesClient.Client
+	interval time.Duration
+
+	// The latest scrape of each node keyed by node name
+	scrapes map[string]*cadvisorScrape
+
+	// The counters from the previous scrape keyed by namespace/pod/container
+	previous map[string]cadvisorCounters
+	mutex    sync.Mutex
+}
+
+// The latest scrape of a node, the mutex is held while the node is scraped so the pods polled at the
+// same time wait for the one scrape
+type cadvisorScrape struct {
+	taken   time.Time
+	samples []prometheus.Sample
+	mutex   sync.Mutex
+}
+
+// The cumulative cAdvisor counters for a single container
+type cadvisorCounters struct {
+	node             string
+	timestamp        int64
+	cpuSeconds       float64
+	memory           float64
+	periods          float64
+	throttledPeriods float64
+}
+
+func (c *cadvisorCollector) collect(pod *v1Core.Pod) (*Record, error) {
+
+	// The pod has not been scheduled yet, so there is no kubelet to ask
+	if pod.Spec.NodeName == "" {
+		return nil, nil
+	}
+
+	samples, err := c.scrape(pod.Spec.NodeName)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.record(pod, samples), nil
+}
+
+// Returns the samples of the node's latest scrape, scraping the node again if that scrape is from an earlier poll.
+// Consecutive polls of a pod are at least an interval apart, so each of them sees a new scrape
+func (c *cadvisorCollector) scrape(nodeName string) ([]prometheus.Sample, error) {
+
+	c.mutex.Lock()
+	scrape, exists := c.scrapes[nodeName]
+	if !exists {
+		scrape = &cadvisorScrape{}
+		c.scrapes[nodeName] = scrape
+	}
+	c.mutex.Unlock()
+
+	scrape.mutex.Lock()
+	defer scrape.mutex.Unlock()
+
+	if time.Since(scrape.taken) < c.interval {
+		return scrape.samples, nil
+	}
+
+	samples, err := c.client.Kubelet.Cadvisor(context.Background(), nodeName)
+	if err != nil {
+		return nil, err
+	}
+	scrape.taken = time.Now()
+	scrape.samples = samples
+
+	c.sweep(nodeName, samples)
+
+	return samples, nil
+}
+
+// Drops the counters of the node's containers that are no longer in its scrape, since their pods have been deleted
+// or moved to another node
+func (c *cadvisorCollector) sweep(nodeName string, samples []prometheus.Sample) {
+
+	current := map[string]bool{}
+	for _, sample := range samples {
+		current[sample.Labels["namespace"]+"/"+sample.Labels["pod"]+"/"+sample.Labels["container"]] = true
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for key, counters := range c.previous {
+		if counters.node == nodeName && !current[key] {
+			delete(c.previous, key)
+		}
+	}
+}
+
+// Returns the record of the pod from a scrape of its node, or nil if this is the first scrape of its containers
+func (c *cadvisorCollector) record(pod *v1Core.Pod, samples []prometheus.Sample) *Record {
+
+	// Filter the samples down to the containers of the profiled pod. The pod level cgroup has an empty
+	// container label and the sandbox container is labelled "POD", both of which we ignore
+	current := map[string]*cadvisorCounters{}
+	containers := []string{}
+	for _, sample := range samples {
+		if sample.Labels["namespace"] != pod.GetNamespace() || sample.Labels["pod"] != pod.GetName() {
+			continue
+		}
+
+		name := sample.Labels["container"]
+		if name == "" || name == "POD" {
+			continue
+		}
+
+		counters, exists := current[name]
+		if !exists {
+			counters = &cadvisorCounters{node: pod.Spec.NodeName}
+			current[name] = counters
+			containers = append(containers, name)
+		}
+
+		if sample.Timestamp/1000 > counters.timestamp {
+			counters.timestamp = sample.Timestamp / 1000
+		}
+
+		switch sample.Name {
+		case "container_cpu_usage_seconds_total":
+			counters.cpuSeconds = sample.Value
+		case "container_memory_working_set_bytes":
+			counters.memory = sample.Value
+		case "container_cpu_cfs_periods_total":
+			counters.periods = sample.Value
+		case "container_cpu_cfs_throttled_periods_total":
+			counters.throttledPeriods = sample.Value
+		}
+	}
+
+	if len(containers) == 0 {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	record := &Record{
+		Pod: Pod{
+			Name: pod.GetName(),
+		},
+	}
+
+	for _, name := range containers {
+		counters := current[name]
+
+		// Not every cAdvisor version includes timestamps, so fall back to the time of the scrape
+		if counters.timestamp == 0 {
+			counters.timestamp = time.Now().Unix()
+		}
+
+		key := pod.GetNamespace() + "/" + pod.GetName() + "/" + name
+		previous, exists := c.previous[key]
+		c.previous[key] = *counters
+
+		// We need two scrapes to calculate a rate, and a counter that went backwards means the container restarted
+		elapsed := counters.timestamp - previous.timestamp
+		if !exists || elapsed <= 0 || counters.cpuSeconds < previous.cpuSeconds || counters.periods < previous.periods {
+			continue
+		}
+
+		throttling := &Throttling{
+			Periods:          int64(counters.periods - previous.periods),
+			ThrottledPeriods: int64(counters.throttledPeriods - previous.throttledPeriods),
+		}
+		if throttling.Periods > 0 {
+			throttling.Ratio = float64(throttling.ThrottledPeriods) / float64(throttling.Periods)
+		}
+
+		record.Pod.Containers = append(record.Pod.Containers, Container{
+			Name:       name,
+			Cpu:        int64(math.Round((counters.cpuSeconds - previous.cpuSeconds) * 1000 / float64(elapsed))),
+			Memory:     int64(counters.memory),
+			Throttling: throttling,
+		})
+
+		if counters.timestamp > record.DateStamp {
+			record.DateStamp = counters.timestamp
+		}
+	}
+
+	if len(record.Pod.Containers) == 0 {
+		return nil
+	}
+
+	return record
+}
+
+// Returns the value of an optional kubelet stat, or zero if it was not reported
+func value(v *uint64) uint64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
@@ -0,0 +1,221 @@
+package capture
+
+import (
+	"encoding/json"
+	"os"
+	kubernetesClient "pod_profiler/pkg/api/kubernetes-client"
+	"pod_profiler/pkg/api/prometheus"
+	"reflect"
+	"testing"
+	"time"
+
+	v1Core "k8s.io/api/core/v1"
+	v1Meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Parses a saved scrape of the kubelet cAdvisor endpoint, the scrapes are shared with the parser tests
+func readScrape(t *testing.T, filename string) []prometheus.Sample {
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	samples, err := prometheus.Parse(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return samples
+}
+
+func TestCadvisorRecord(t *testing.T) {
+
+	// The fixture also has a pod of the same name in another namespace, the pod level cgroup, the sandbox
+	// container and a system cgroup, none of which should be counted
+	pod := &v1Core.Pod{
+		ObjectMeta: v1Meta.ObjectMeta{Name: "sps-api-7d9f8-abcde", Namespace: "sps"},
+		Spec:       v1Core.PodSpec{NodeName: "node-1"},
+	}
+
+	collector := &cadvisorCollector{previous: map[string]cadvisorCounters{}}
+
+	if record := collector.record(pod, readScrape(t, "../prometheus/testdata/cadvisor-1.txt")); record != nil {
+		t.Fatalf("expected no record from the first scrape, got %+v", record)
+	}
+
+	record := collector.record(pod, readScrape(t, "../prometheus/testdata/cadvisor-2.txt"))
+	if record == nil {
+		t.Fatal("expected a record from the second scrape")
+	}
+
+	expected := &Record{
+		DateStamp: 1700000010,
+		Pod: Pod{
+			Name: "sps-api-7d9f8-abcde",
+			Containers: []Container{
+				{
+					// 1.5 cpu seconds over 10 seconds, with 25 of the 100 periods throttled
+					Name:       "api",
+					Cpu:        150,
+					Memory:     110100480,
+					Throttling: &Throttling{Periods: 100, ThrottledPeriods: 25, Ratio: 0.25},
+				},
+				{
+					Name:       "proxy",
+					Cpu:        20,
+					Memory:     31457280,
+					Throttling: &Throttling{Periods: 100, ThrottledPeriods: 0, Ratio: 0},
+				},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(record, expected) {
+		t.Errorf("expected %+v, got %+v", expected, record)
+		for i, container := range record.Pod.Containers {
+			t.Logf("container %d: %+v throttling %+v", i, container, container.Throttling)
+		}
+	}
+}
+
+func TestCadvisorRecordOtherPod(t *testing.T) {
+
+	pod := &v1Core.Pod{
+		ObjectMeta: v1Meta.ObjectMeta{Name: "sps-coturn-5c4b3-fghij", Namespace: "sps"},
+		Spec:       v1Core.PodSpec{NodeName: "node-1"},
+	}
+
+	collector := &cadvisorCollector{previous: map[string]cadvisorCounters{}}
+	collector.record(pod, readScrape(t, "../prometheus/testdata/cadvisor-1.txt"))
+
+	if record := collector.record(pod, readScrape(t, "../prometheus/testdata/cadvisor-2.txt")); record != nil {
+		t.Errorf("expected no record for a pod that isn't in the scrape, got %+v", record)
+	}
+	if len(collector.previous) != 0 {
+		t.Errorf("expected no counters to be kept, got %v", collector.previous)
+	}
+}
+
+func TestCadvisorRecordRestart(t *testing.T) {
+
+	pod := &v1Core.Pod{
+		ObjectMeta: v1Meta.ObjectMeta{Name: "sps-api-7d9f8-abcde", Namespace: "sps"},
+		Spec:       v1Core.PodSpec{NodeName: "node-1"},
+	}
+
+	// Scraping the counters in reverse looks like the containers restarted, which must not produce negative usage
+	collector := &cadvisorCollector{previous: map[string]cadvisorCounters{}}
+	collector.record(pod, readScrape(t, "../prometheus/testdata/cadvisor-2.txt"))
+
+	second := readScrape(t, "../prometheus/testdata/cadvisor-1.txt")
+	for i := range second {
+		second[i].Timestamp += 20000
+	}
+
+	if record := collector.record(pod, second); record != nil {
+		t.Errorf("expected no record after the counters were reset, got %+v", record)
+	}
+}
+
+func TestCadvisorScrapeShared(t *testing.T) {
+
+	samples := readScrape(t, "../prometheus/testdata/cadvisor-1.txt")
+
+	// The collector has no kubelet, so scraping the node again would panic
+	collector := &cadvisorCollector{
+		interval: time.Minute,
+		scrapes:  map[string]*cadvisorScrape{"node-1": {taken: time.Now(), samples: samples}},
+		previous: map[string]cadvisorCounters{},
+	}
+
+	for _, name := range []string{"sps-api-7d9f8-abcde", "sps-coturn-5c4b3-fghij"} {
+		scraped, err := collector.scrape("node-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(scraped) != len(samples) {
+			t.Errorf("%s: expected the node's scrape of %d samples, got %d", name, len(samples), len(scraped))
+		}
+	}
+}
+
+func TestCadvisorSweep(t *testing.T) {
+
+	pod := &v1Core.Pod{
+		ObjectMeta: v1Meta.ObjectMeta{Name: "sps-api-7d9f8-abcde", Namespace: "sps"},
+		Spec:       v1Core.PodSpec{NodeName: "node-1"},
+	}
+
+	collector := &cadvisorCollector{previous: map[string]cadvisorCounters{}}
+	samples := readScrape(t, "../prometheus/testdata/cadvisor-1.txt")
+	collector.record(pod, samples)
+
+	// A pod that has been deleted from the node and a pod on another node
+	collector.previous["sps/sps-api-7d9f8-zyxwv/api"] = cadvisorCounters{node: "node-1"}
+	collector.previous["sps/sps-api-7d9f8-vwxyz/api"] = cadvisorCounters{node: "node-2"}
+
+	collector.sweep("node-1", samples)
+
+	expected := []string{"sps/sps-api-7d9f8-abcde/api", "sps/sps-api-7d9f8-abcde/proxy", "sps/sps-api-7d9f8-vwxyz/api"}
+	if len(collector.previous) != len(expected) {
+		t.Errorf("expected %d counters, got %v", len(expected), collector.previous)
+	}
+	for _, key := range expected {
+		if _, exists := collector.previous[key]; !exists {
+			t.Errorf("expected the counters of %s to be kept", key)
+		}
+	}
+}
+
+// Reads a saved kubelet summary
+func readSummary(t *testing.T, filename string) *kubernetesClient.Summary {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summary := &kubernetesClient.Summary{}
+	if err := json.Unmarshal(data, summary); err != nil {
+		t.Fatal(err)
+	}
+	return summary
+}
+
+func TestSummaryRecordWithoutCpu(t *testing.T) {
+
+	summary := readSummary(t, "testdata/summary-no-cpu.json")
+
+	tests := []struct {
+		name      string
+		pod       string
+		dateStamp int64
+	}{
+		// The latest time of the containers' memory stats
+		{name: "memory", pod: "sps-api-7d9f8-abcde", dateStamp: 1700000001},
+		// The time of the pod's cpu stats when the containers have no stats at all
+		{name: "pod cpu", pod: "sps-worker-5c6d7-fghij", dateStamp: 1700000005},
+	}
+
+	for _, test := range tests {
+		pod := &v1Core.Pod{ObjectMeta: v1Meta.ObjectMeta{Name: test.pod, Namespace: "sps"}}
+
+		record := summaryRecord(pod, summary.Pod("sps", test.pod))
+		if record == nil {
+			t.Errorf("%s: expected a record", test.name)
+			continue
+		}
+		if record.DateStamp != test.dateStamp {
+			t.Errorf("%s: expected datestamp %d, got %d", test.name, test.dateStamp, record.DateStamp)
+		}
+	}
+
+	// Without any stats with a time the sample is stamped with the current time
+	stats := summary.Pod("sps", "sps-worker-5c6d7-fghij")
+	stats.CPU = nil
+
+	before := time.Now().Unix()
+	record := summaryRecord(&v1Core.Pod{ObjectMeta: v1Meta.ObjectMeta{Name: "sps-worker-5c6d7-fghij", Namespace: "sps"}}, stats)
+	if record == nil || record.DateStamp < before || record.DateStamp > time.Now().Unix() {
+		t.Errorf("expected the current time, got %+v", record)
+	}
+}
@@ -0,0 +1,121 @@
+package capture
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	v1Core "k8s.io/api/core/v1"
+)
+
+// ContainerRole is the part a container plays in its pod
+type ContainerRole string
+
+const (
+	ContainerRole_Container ContainerRole = "container"
+	ContainerRole_Init      ContainerRole = "init"
+	ContainerRole_Sidecar   ContainerRole = "sidecar"
+)
+
+// Returns the pod's init containers followed by its regular containers, without modifying the pod which may be shared by the cache
+func podContainers(pod *v1Core.Pod) []v1Core.Container {
+	return append(append([]v1Core.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+}
+
+// Returns the statuses of the pod's init containers followed by its regular containers, without modifying the pod
+func podStatuses(pod *v1Core.Pod) []v1Core.ContainerStatus {
+	return append(append([]v1Core.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+}
+
+// Returns the role of each of the pod's containers, keyed by container name
+func containerRoles(pod *v1Core.Pod) map[string]ContainerRole {
+
+	roles := map[string]ContainerRole{}
+
+	for _, container := range pod.Spec.InitContainers {
+
+		// Native sidecars are init containers that keep running alongside the regular containers
+		roles[container.Name] = ContainerRole_Init
+		if container.RestartPolicy != nil && *container.RestartPolicy == v1Core.ContainerRestartPolicyAlways {
+			roles[container.Name] = ContainerRole_Sidecar
+		}
+	}
+
+	for _, container := range pod.Spec.Containers {
+		roles[container.Name] = ContainerRole_Container
+	}
+
+	return roles
+}
+
+// Sets the role, image and image digest of each container in the record from the pod's spec and status
+func annotateContainers(pod *v1Core.Pod, record *Record) {
+
+	roles := containerRoles(pod)
+	images := map[string]string{}
+	digests := map[string]string{}
+
+	for _, container := range podContainers(pod) {
+		images[container.Name] = container.Image
+	}
+
+	for _, status := range podStatuses(pod) {
+		digests[status.Name] = imageDigest(status.ImageID)
+	}
+
+	for i := range record.Pod.Containers {
+		container := &record.Pod.Containers[i]
+		container.Role = roles[container.Name]
+		container.Image = images[container.Name]
+		container.ImageDigest = digests[container.Name]
+	}
+}
+
+// Returns the digest from a container status image ID such as docker-pullable://registry/image@sha256:...
+func imageDigest(imageID string) string {
+	if index := strings.LastIndex(imageID, "@"); index >= 0 {
+		return imageID[index+1:]
+	}
+
+	// Some runtimes report the bare digest rather than a pullable reference
+	if strings.HasPrefix(imageID, "sha256:") {
+		return imageID
+	}
+
+	return ""
+}
+
+// Returns a row of the pods file for each of the pod's containers, with the cpu in millicores and the memory in bytes.
+// Requests and limits that aren't set are left blank
+func podRows(pod *v1Core.Pod) [][]string {
+
+	roles := containerRoles(pod)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	rows := [][]string{}
+	for _, container := range podContainers(pod) {
+		rows = append(rows, []string{
+			timestamp,
+			pod.GetName(),
+			container.Name,
+			string(roles[container.Name]),
+			resourceValue(container.Resources.Requests, v1Core.ResourceCPU),
+			resourceValue(container.Resources.Limits, v1Core.ResourceCPU),
+			resourceValue(container.Resources.Requests, v1Core.ResourceMemory),
+			resourceValue(container.Resources.Limits, v1Core.ResourceMemory),
+		})
+	}
+
+	return rows
+}
+
+func resourceValue(resources v1Core.ResourceList, name v1Core.ResourceName) string {
+	value, exists := resources[name]
+	if !exists {
+		return ""
+	}
+	if name == v1Core.ResourceCPU {
+		return strconv.FormatInt(value.MilliValue(), 10)
+	}
+	return strconv.FormatInt(value.Value(), 10)
+}
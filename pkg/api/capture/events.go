@@ -0,0 +1,274 @@
+package capture
+
+import (
+	"strconv"
+	"time"
+
+	v1Core "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// EventType is the kind of lifecycle event recorded for a pod
+type EventType string
+
+const (
+	EventType_Phase        EventType = "phase"
+	EventType_Restart      EventType = "restart"
+	EventType_Terminated   EventType = "terminated"
+	EventType_Eviction     EventType = "eviction"
+	EventType_ProbeFailure EventType = "probe-failure"
+	EventType_Alert        EventType = "alert"
+)
+
+// Event is a lifecycle event of a profiled pod. The datestamp uses the same unix seconds as the usage records
+type Event struct {
+	DateStamp int64     `json:"datestamp"`
+	Pod       string    `json:"pod"`
+	Container string    `json:"container,omitempty"`
+	Type      EventType `json:"type"`
+	Reason    string    `json:"reason"`
+	ExitCode  *int32    `json:"exitCode,omitempty"`
+	Message   string    `json:"message,omitempty"`
+}
+
+// Registers the event handlers on the pod and event informers so that lifecycle events for
+// the profiled pods are sent to OnEvent until the capture is stopped
+func (capture *Capture) watchEvents() error {
+
+	selector, err := capture.selector()
+	if err != nil {
+		return err
+	}
+
+	started := time.Now()
+
+	podRegistration, err := capture.client.Cache.Informers.Pod.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldPod, oldOk := oldObj.(*v1Core.Pod)
+			newPod, newOk := newObj.(*v1Core.Pod)
+			if !oldOk || !newOk || !capture.matches(newPod, selector) {
+				return
+			}
+
+			for _, event := range podEvents(oldPod, newPod) {
+				capture.sendEvent(event)
+			}
+		},
+	})
+	if err != nil {
+		return err
+	}
+	capture.registrations = append(capture.registrations, registration{capture.client.Cache.Informers.Pod.Informer(), podRegistration})
+
+	// The events informer is optional, only watch it if it was included in the cache
+	if capture.client.Cache.Informers.Event == nil {
+		return nil
+	}
+
+	onEvent := func(obj interface{}) {
+		k8sEvent, ok := obj.(*v1Core.Event)
+		if !ok || k8sEvent.InvolvedObject.Kind != "Pod" {
+			return
+		}
+
+		// Skip the events that already existed before we started capturing
+		timestamp := eventTime(k8sEvent)
+		if timestamp.Before(started) {
+			return
+		}
+
+		pod, err := capture.client.Cache.Pod().Get(k8sEvent.InvolvedObject.Name)
+		if err != nil || !capture.matches(pod, selector) {
+			return
+		}
+
+		event := Event{
+			DateStamp: timestamp.Unix(),
+			Pod:       pod.GetName(),
+			Container: containerFromFieldPath(k8sEvent.InvolvedObject.FieldPath),
+			Reason:    k8sEvent.Reason,
+			Message:   k8sEvent.Message,
+		}
+
+		switch k8sEvent.Reason {
+		case "Unhealthy":
+			event.Type = EventType_ProbeFailure
+		case "Evicted":
+			event.Type = EventType_Eviction
+		default:
+			return
+		}
+
+		capture.sendEvent(event)
+	}
+
+	eventRegistration, err := capture.client.Cache.Informers.Event.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: onEvent,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldEvent, oldOk := oldObj.(*v1Core.Event)
+			newEvent, newOk := newObj.(*v1Core.Event)
+
+			// Repeated events are folded into a single object with an increasing count
+			if oldOk && newOk && oldEvent.Count != newEvent.Count {
+				onEvent(newObj)
+			}
+		},
+	})
+	if err != nil {
+		return err
+	}
+	capture.registrations = append(capture.registrations, registration{capture.client.Cache.Informers.Event.Informer(), eventRegistration})
+
+	return nil
+}
+
+// Removes the event handlers registered by watchEvents
+func (capture *Capture) unwatchEvents() {
+	for _, r := range capture.registrations {
+		r.informer.RemoveEventHandler(r.handle)
+	}
+	capture.registrations = nil
+	capture.unwatchWorkloads()
+}
+
+// Removes the event handlers on the target's rollouts and autoscaling
+func (capture *Capture) unwatchWorkloads() {
+	for _, r := range capture.workloadRegistrations {
+		r.informer.RemoveEventHandler(r.handle)
+	}
+	capture.workloadRegistrations = nil
+}
+
+// Sends the event to the capture unless it has been stopped
+func (capture *Capture) sendEvent(event Event) {
+	select {
+	case capture.OnEvent <- event:
+	case <-capture.stopped:
+	}
+}
+
+// Compares two versions of a pod and returns the lifecycle events between them
+func podEvents(oldPod, newPod *v1Core.Pod) []Event {
+
+	events := []Event{}
+	now := time.Now().Unix()
+
+	if oldPod.Status.Phase != newPod.Status.Phase {
+		event := Event{
+			DateStamp: now,
+			Pod:       newPod.GetName(),
+			Type:      EventType_Phase,
+			Reason:    string(newPod.Status.Phase),
+			Message:   newPod.Status.Message,
+		}
+
+		// The kubelet marks evicted pods as failed with an evicted reason
+		if newPod.Status.Reason == "Evicted" {
+			event.Type = EventType_Eviction
+			event.Reason = newPod.Status.Reason
+		}
+
+		events = append(events, event)
+	}
+
+	previous := map[string]v1Core.ContainerStatus{}
+	for _, status := range podStatuses(oldPod) {
+		previous[status.Name] = status
+	}
+
+	for _, status := range podStatuses(newPod) {
+		old, exists := previous[status.Name]
+		if !exists {
+			continue
+		}
+
+		// A restart reports the reason the previous instance of the container terminated
+		if status.RestartCount > old.RestartCount {
+			event := Event{
+				DateStamp: now,
+				Pod:       newPod.GetName(),
+				Container: status.Name,
+				Type:      EventType_Restart,
+				Reason:    "Restarted",
+			}
+
+			if terminated := status.LastTerminationState.Terminated; terminated != nil {
+				event.DateStamp = terminatedAt(terminated, now)
+				event.Reason = terminated.Reason
+				event.ExitCode = &terminated.ExitCode
+				event.Message = terminated.Message
+			}
+
+			events = append(events, event)
+			continue
+		}
+
+		// A container that terminated without being restarted, which is how job containers and failed pods end
+		if terminated := status.State.Terminated; terminated != nil && old.State.Terminated == nil {
+			events = append(events, Event{
+				DateStamp: terminatedAt(terminated, now),
+				Pod:       newPod.GetName(),
+				Container: status.Name,
+				Type:      EventType_Terminated,
+				Reason:    terminated.Reason,
+				ExitCode:  &terminated.ExitCode,
+				Message:   terminated.Message,
+			})
+		}
+	}
+
+	return events
+}
+
+// Returns the time the container terminated, or the fallback if it was not reported
+func terminatedAt(terminated *v1Core.ContainerStateTerminated, fallback int64) int64 {
+	if terminated.FinishedAt.IsZero() {
+		return fallback
+	}
+	return terminated.FinishedAt.Unix()
+}
+
+// Returns the most recent time the kubernetes event occurred
+func eventTime(event *v1Core.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	default:
+		return event.CreationTimestamp.Time
+	}
+}
+
+// Returns the container name from an involved object field path such as spec.containers{name}
+func containerFromFieldPath(fieldPath string) string {
+	for i := 0; i < len(fieldPath); i++ {
+		if fieldPath[i] == '{' && fieldPath[len(fieldPath)-1] == '}' {
+			return fieldPath[i+1 : len(fieldPath)-1]
+		}
+	}
+	return ""
+}
+
+// Returns the event as a row of the events results file
+func (event Event) row() []string {
+	exitCode := ""
+	if event.ExitCode != nil {
+		exitCode = strconv.FormatInt(int64(*event.ExitCode), 10)
+	}
+
+	return []string{
+		strconv.FormatInt(event.DateStamp, 10),
+		event.Container,
+		string(event.Type),
+		event.Reason,
+		exitCode,
+		event.Message,
+	}
+}
+
+// An event handler registered with an informer
+type registration struct {
+	informer cache.SharedIndexInformer
+	handle   cache.ResourceEventHandlerRegistration
+}
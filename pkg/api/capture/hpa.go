@@ -0,0 +1,234 @@
+package capture
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	v2Autoscaling "k8s.io/api/autoscaling/v2"
+	v2beta2Autoscaling "k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// HPASample is the state of a horizontal pod autoscaler of a profiled target at a point in time
+type HPASample struct {
+	DateStamp       int64       `json:"datestamp"`
+	Name            string      `json:"name"`
+	CurrentReplicas int32       `json:"currentReplicas"`
+	DesiredReplicas int32       `json:"desiredReplicas"`
+	Metrics         []HPAMetric `json:"metrics"`
+}
+
+// HPAMetric is the current value and target of one of the metrics an autoscaler scales on
+type HPAMetric struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Current string `json:"current"`
+	Target  string `json:"target"`
+}
+
+// Registers the event handler on the HPA informer so that the status of the target's autoscalers
+// is sent to onHPA when the capture starts and each time it is updated, until the capture is stopped
+func (capture *Capture) watchHPAs() error {
+
+	selector, err := capture.selector()
+	if err != nil {
+		return err
+	}
+
+	onHPA := func(hpa *v2Autoscaling.HorizontalPodAutoscaler) {
+
+		// An autoscaler belongs to the target if it scales a workload named after it or carries its label
+		if hpa.Spec.ScaleTargetRef.Name != capture.Deployment && !selector.Matches(labels.Set(hpa.GetLabels())) {
+			return
+		}
+
+		select {
+		case capture.onHPA <- hpaSample(hpa):
+		case <-capture.stopped:
+		}
+	}
+
+	// The autoscaling API version in use depends on the platform, so watch whichever informer the cache built
+	var informer cache.SharedIndexInformer
+	var handler cache.ResourceEventHandlerFuncs
+
+	switch {
+	case capture.client.Cache.Informers.HorizontalPodAutoscalerV2 != nil:
+		informer = capture.client.Cache.Informers.HorizontalPodAutoscalerV2.Informer()
+		onObject := func(obj interface{}) {
+			if hpa, ok := obj.(*v2Autoscaling.HorizontalPodAutoscaler); ok {
+				onHPA(hpa)
+			}
+		}
+		handler = cache.ResourceEventHandlerFuncs{
+			AddFunc: onObject,
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				onObject(newObj)
+			},
+		}
+
+	case capture.client.Cache.Informers.HorizontalPodAutoscalerV2beta2 != nil:
+		informer = capture.client.Cache.Informers.HorizontalPodAutoscalerV2beta2.Informer()
+		onObject := func(obj interface{}) {
+			if hpa, ok := obj.(*v2beta2Autoscaling.HorizontalPodAutoscaler); ok {
+				converted, err := convertHPA(hpa)
+				if err != nil {
+					capture.sendError(err)
+					return
+				}
+				onHPA(converted)
+			}
+		}
+		handler = cache.ResourceEventHandlerFuncs{
+			AddFunc: onObject,
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				onObject(newObj)
+			},
+		}
+
+	default:
+		return nil
+	}
+
+	handle, err := informer.AddEventHandler(handler)
+	if err != nil {
+		return err
+	}
+	capture.workloadRegistrations = append(capture.workloadRegistrations, registration{informer, handle})
+
+	return nil
+}
+
+// Converts a v2beta2 autoscaler to v2. The two versions share the same schema, so we convert through JSON
+func convertHPA(hpa *v2beta2Autoscaling.HorizontalPodAutoscaler) (*v2Autoscaling.HorizontalPodAutoscaler, error) {
+
+	bytes, err := json.Marshal(hpa)
+	if err != nil {
+		return nil, err
+	}
+
+	converted := &v2Autoscaling.HorizontalPodAutoscaler{}
+	if err := json.Unmarshal(bytes, converted); err != nil {
+		return nil, err
+	}
+
+	return converted, nil
+}
+
+// Returns the sample of the autoscaler's current state
+func hpaSample(hpa *v2Autoscaling.HorizontalPodAutoscaler) HPASample {
+
+	sample := HPASample{
+		DateStamp:       time.Now().Unix(),
+		Name:            hpa.GetName(),
+		CurrentReplicas: hpa.Status.CurrentReplicas,
+		DesiredReplicas: hpa.Status.DesiredReplicas,
+	}
+
+	// Index the current values by metric, the status lists them in the same form as the spec
+	current := map[string]v2Autoscaling.MetricValueStatus{}
+	for _, status := range hpa.Status.CurrentMetrics {
+		name, value := metricStatus(status)
+		current[name] = value
+	}
+
+	for _, spec := range hpa.Spec.Metrics {
+		name, target := metricTarget(spec)
+		value := current[name]
+
+		metric := HPAMetric{
+			Name: name,
+			Type: string(target.Type),
+		}
+
+		switch target.Type {
+		case v2Autoscaling.UtilizationMetricType:
+			metric.Target = utilization(target.AverageUtilization)
+			metric.Current = utilization(value.AverageUtilization)
+		case v2Autoscaling.AverageValueMetricType:
+			metric.Target = quantity(target.AverageValue)
+			metric.Current = quantity(value.AverageValue)
+		case v2Autoscaling.ValueMetricType:
+			metric.Target = quantity(target.Value)
+			metric.Current = quantity(value.Value)
+		}
+
+		sample.Metrics = append(sample.Metrics, metric)
+	}
+
+	return sample
+}
+
+// Returns the name and target of a metric from the autoscaler's spec
+func metricTarget(spec v2Autoscaling.MetricSpec) (string, v2Autoscaling.MetricTarget) {
+	switch spec.Type {
+	case v2Autoscaling.ResourceMetricSourceType:
+		return spec.Resource.Name.String(), spec.Resource.Target
+	case v2Autoscaling.ContainerResourceMetricSourceType:
+		return spec.ContainerResource.Container + "/" + spec.ContainerResource.Name.String(), spec.ContainerResource.Target
+	case v2Autoscaling.PodsMetricSourceType:
+		return spec.Pods.Metric.Name, spec.Pods.Target
+	case v2Autoscaling.ObjectMetricSourceType:
+		return spec.Object.Metric.Name, spec.Object.Target
+	case v2Autoscaling.ExternalMetricSourceType:
+		return spec.External.Metric.Name, spec.External.Target
+	}
+	return string(spec.Type), v2Autoscaling.MetricTarget{}
+}
+
+// Returns the name and current value of a metric from the autoscaler's status
+func metricStatus(status v2Autoscaling.MetricStatus) (string, v2Autoscaling.MetricValueStatus) {
+	switch status.Type {
+	case v2Autoscaling.ResourceMetricSourceType:
+		return status.Resource.Name.String(), status.Resource.Current
+	case v2Autoscaling.ContainerResourceMetricSourceType:
+		return status.ContainerResource.Container + "/" + status.ContainerResource.Name.String(), status.ContainerResource.Current
+	case v2Autoscaling.PodsMetricSourceType:
+		return status.Pods.Metric.Name, status.Pods.Current
+	case v2Autoscaling.ObjectMetricSourceType:
+		return status.Object.Metric.Name, status.Object.Current
+	case v2Autoscaling.ExternalMetricSourceType:
+		return status.External.Metric.Name, status.External.Current
+	}
+	return string(status.Type), v2Autoscaling.MetricValueStatus{}
+}
+
+func utilization(value *int32) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatInt(int64(*value), 10)
+}
+
+func quantity(value *resource.Quantity) string {
+	if value == nil {
+		return ""
+	}
+	return value.String()
+}
+
+// Returns the sample as rows of the HPA results file, with one row per metric
+func (sample HPASample) rows() [][]string {
+
+	prefix := []string{
+		strconv.FormatInt(sample.DateStamp, 10),
+		sample.Name,
+		strconv.FormatInt(int64(sample.CurrentReplicas), 10),
+		strconv.FormatInt(int64(sample.DesiredReplicas), 10),
+	}
+
+	if len(sample.Metrics) == 0 {
+		return [][]string{append(prefix, "", "", "", "")}
+	}
+
+	rows := [][]string{}
+	for _, metric := range sample.Metrics {
+		row := append([]string{}, prefix...)
+		rows = append(rows, append(row, metric.Name, metric.Type, metric.Current, metric.Target))
+	}
+
+	return rows
+}
@@ -0,0 +1,251 @@
+package capture
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"pod_profiler/pkg/api/logging"
+	"sort"
+	"time"
+
+	v1Batch "k8s.io/api/batch/v1"
+	v1Core "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// CaptureMode is the kind of workload a capture profiles
+type CaptureMode string
+
+const (
+	CaptureMode_Workload CaptureMode = "workload"
+	CaptureMode_Job      CaptureMode = "job"
+)
+
+// JobSummary is written once a profiled job has finished
+type JobSummary struct {
+	Name           string          `json:"name"`
+	Status         string          `json:"status"`
+	StartTime      int64           `json:"starttime"`
+	CompletionTime int64           `json:"completiontime"`
+	Duration       int64           `json:"duration"`
+	Containers     []ContainerPeak `json:"containers"`
+
+	// The number of samples the peaks were taken from. Pods that finish before they are seen running are not
+	// sampled, so a job shorter than the poll interval can have none
+	Samples int             `json:"samples"`
+	Pods    []JobPodSummary `json:"pods"`
+}
+
+// The peak usage of a container across every pod of a job
+type ContainerPeak struct {
+	Name   string `json:"name"`
+	Cpu    int64  `json:"cpu"`
+	Memory int64  `json:"memory"`
+}
+
+// The exit status of each container of a job pod
+type JobPodSummary struct {
+	Name       string               `json:"name"`
+	Phase      string               `json:"phase"`
+	Containers []JobContainerStatus `json:"containers"`
+}
+
+type JobContainerStatus struct {
+	Name     string `json:"name"`
+	Reason   string `json:"reason"`
+	ExitCode int32  `json:"exitcode"`
+}
+
+// Registers the event handler on the job informer so that finished jobs are sent to onJob until the capture is stopped
+func (capture *Capture) watchJobs() error {
+
+	// The jobs informer is optional, only watch it if it was included in the cache
+	if capture.client.Cache.Informers.Job == nil {
+		return fmt.Errorf("job capture of %s requires the job informer", capture.Deployment)
+	}
+
+	selector, err := capture.selector()
+	if err != nil {
+		return err
+	}
+
+	started := time.Now()
+
+	onJob := func(obj interface{}) {
+		job, ok := obj.(*v1Batch.Job)
+		if !ok || !capture.owns(job, selector) {
+			return
+		}
+
+		// Skip the jobs that finished before we started capturing
+		status, finishedAt := jobFinished(job)
+		if status == "" || finishedAt.Before(started) {
+			return
+		}
+
+		select {
+		case capture.onJob <- job:
+		case <-capture.stopped:
+		}
+	}
+
+	informer := capture.client.Cache.Informers.Job.Informer()
+	handle, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: onJob,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldJob, oldOk := oldObj.(*v1Batch.Job)
+			if !oldOk {
+				return
+			}
+
+			// Only report the transition into a finished state
+			if status, _ := jobFinished(oldJob); status == "" {
+				onJob(newObj)
+			}
+		},
+	})
+	if err != nil {
+		return err
+	}
+	capture.registrations = append(capture.registrations, registration{informer, handle})
+
+	return nil
+}
+
+// Returns the final status of the job and when it finished, or an empty status if it is still running
+func jobFinished(job *v1Batch.Job) (string, time.Time) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != v1Core.ConditionTrue {
+			continue
+		}
+
+		switch condition.Type {
+		case v1Batch.JobComplete, v1Batch.JobFailed:
+			return string(condition.Type), condition.LastTransitionTime.Time
+		}
+	}
+	return "", time.Time{}
+}
+
+// Returns the name of the job that owns the pod, or an empty string if it is not a job pod
+func jobName(pod *v1Core.Pod) string {
+	for _, owner := range pod.GetOwnerReferences() {
+		if owner.Kind == "Job" {
+			return owner.Name
+		}
+	}
+	return ""
+}
+
+// Updates the peak usage of the job that owns the pod the record belongs to
+func (capture *Capture) trackJobPeaks(record Record) {
+
+	job, exists := capture.podJobs[record.Pod.Name]
+	if !exists {
+		return
+	}
+
+	if capture.jobPeaks[job] == nil {
+		capture.jobPeaks[job] = map[string]*ContainerPeak{}
+	}
+	capture.jobSamples[job]++
+
+	for _, container := range record.Pod.Containers {
+		peak, exists := capture.jobPeaks[job][container.Name]
+		if !exists {
+			peak = &ContainerPeak{Name: container.Name}
+			capture.jobPeaks[job][container.Name] = peak
+		}
+
+		peak.Cpu = max(peak.Cpu, container.Cpu)
+		peak.Memory = max(peak.Memory, container.Memory)
+	}
+}
+
+// Writes the summaries of the finished jobs whose pods are no longer being polled, so the final sample of each pod is
+// included in its job's peaks. When all is set every finished job is summarised, such as when the capture stops
+func (capture *Capture) saveFinishedJobs(all bool) {
+
+	polling := map[string]bool{}
+	for _, job := range capture.podJobs {
+		polling[job] = true
+	}
+
+	for name, job := range capture.finishedJobs {
+		if polling[name] && !all {
+			continue
+		}
+		delete(capture.finishedJobs, name)
+
+		if err := capture.saveJobSummary(job); err != nil {
+			logging.Error().Printf("error: %s\n", err.Error())
+		}
+	}
+}
+
+// Writes the summary of a finished job to the results path
+func (capture *Capture) saveJobSummary(job *v1Batch.Job) error {
+
+	status, finishedAt := jobFinished(job)
+
+	summary := JobSummary{
+		Name:           job.GetName(),
+		Status:         status,
+		CompletionTime: finishedAt.Unix(),
+		Containers:     []ContainerPeak{},
+		Pods:           []JobPodSummary{},
+	}
+
+	if job.Status.StartTime != nil {
+		summary.StartTime = job.Status.StartTime.Unix()
+		summary.Duration = summary.CompletionTime - summary.StartTime
+	}
+
+	for _, peak := range capture.jobPeaks[job.GetName()] {
+		summary.Containers = append(summary.Containers, *peak)
+	}
+	sort.Slice(summary.Containers, func(i, j int) bool {
+		return summary.Containers[i].Name < summary.Containers[j].Name
+	})
+	delete(capture.jobPeaks, job.GetName())
+
+	summary.Samples = capture.jobSamples[job.GetName()]
+	delete(capture.jobSamples, job.GetName())
+
+	pods, err := capture.GetPods()
+	if err != nil {
+		return err
+	}
+
+	for _, pod := range pods {
+		if jobName(pod) != job.GetName() {
+			continue
+		}
+
+		podSummary := JobPodSummary{
+			Name:       pod.GetName(),
+			Phase:      string(pod.Status.Phase),
+			Containers: []JobContainerStatus{},
+		}
+
+		for _, status := range pod.Status.ContainerStatuses {
+			if terminated := status.State.Terminated; terminated != nil {
+				podSummary.Containers = append(podSummary.Containers, JobContainerStatus{
+					Name:     status.Name,
+					Reason:   terminated.Reason,
+					ExitCode: terminated.ExitCode,
+				})
+			}
+		}
+
+		summary.Pods = append(summary.Pods, podSummary)
+	}
+
+	bytes, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path.Join(capture.resultsPath, job.GetName()+FileSuffix_Job), bytes, 0777)
+}
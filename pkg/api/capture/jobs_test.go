@@ -0,0 +1,142 @@
+package capture
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"pod_profiler/pkg/api/defaults"
+	kubernetesClient "pod_profiler/pkg/api/kubernetes-client"
+	"pod_profiler/pkg/api/kubernetes-client/fake"
+	"reflect"
+	"testing"
+	"time"
+
+	v1Batch "k8s.io/api/batch/v1"
+	v1Core "k8s.io/api/core/v1"
+	v1Meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+)
+
+// Returns a job capture of the "batch" jobs that reads its pods from a cache of the given pods
+func jobCapture(t *testing.T, pods ...*v1Core.Pod) *Capture {
+
+	objects := []runtime.Object{}
+	for _, pod := range pods {
+		objects = append(objects, pod)
+	}
+	client := fake.NewClientBuilder().WithClientsetRuntimeObjects(objects...).Build()
+
+	factory := informers.NewSharedInformerFactoryWithOptions(client.Clientset, 0, informers.WithNamespace("ns"))
+	client.Cache = &kubernetesClient.Cache{
+		Informers: &kubernetesClient.Informers{Pod: factory.Core().V1().Pods()},
+		Listers:   &kubernetesClient.Listers{},
+		Namespace: "ns",
+	}
+	client.Cache.Listers.Pod = client.Cache.Informers.Pod.Lister()
+
+	stop := make(chan struct{})
+	t.Cleanup(func() { close(stop) })
+	factory.Start(stop)
+	factory.WaitForCacheSync(stop)
+
+	return &Capture{
+		client:      client,
+		Deployment:  "batch",
+		Mode:        CaptureMode_Job,
+		resultsPath: t.TempDir(),
+		podSelector: labels.SelectorFromSet(labels.Set{defaults.KUBERNETES_NAME_LABEL: "batch"}),
+		jobPeaks:    map[string]map[string]*ContainerPeak{},
+		jobSamples:  map[string]int{},
+		podJobs:     map[string]string{},
+	}
+}
+
+func jobPod(name, job string, phase v1Core.PodPhase) *v1Core.Pod {
+	return &v1Core.Pod{
+		ObjectMeta: v1Meta.ObjectMeta{
+			Name:            name,
+			Namespace:       "ns",
+			Labels:          map[string]string{defaults.KUBERNETES_NAME_LABEL: "batch"},
+			OwnerReferences: []v1Meta.OwnerReference{{Kind: "Job", Name: job}},
+		},
+		Status: v1Core.PodStatus{Phase: phase},
+	}
+}
+
+func finishedJob(name string) *v1Batch.Job {
+	started := v1Meta.NewTime(time.Unix(1700000000, 0))
+	return &v1Batch.Job{
+		ObjectMeta: v1Meta.ObjectMeta{Name: name, Namespace: "ns"},
+		Status: v1Batch.JobStatus{
+			StartTime: &started,
+			Conditions: []v1Batch.JobCondition{{
+				Type:               v1Batch.JobComplete,
+				Status:             v1Core.ConditionTrue,
+				LastTransitionTime: v1Meta.NewTime(time.Unix(1700000001, 0)),
+			}},
+		},
+	}
+}
+
+// Reads the summary the capture wrote for the job
+func readJobSummary(t *testing.T, capture *Capture, job string) JobSummary {
+	data, err := os.ReadFile(path.Join(capture.resultsPath, job+FileSuffix_Job))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summary := JobSummary{}
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatal(err)
+	}
+	return summary
+}
+
+func TestJobSummary(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		records    []Record
+		containers []ContainerPeak
+	}{
+		{
+			// The job finished before its pod was seen running
+			name:       "no samples",
+			containers: []ContainerPeak{},
+		},
+		{
+			name: "peaks",
+			records: []Record{
+				{Pod: Pod{Name: "job-abcde", Containers: []Container{{Name: "worker", Cpu: 200, Memory: 100}, {Name: "agent", Cpu: 10, Memory: 50}}}},
+				{Pod: Pod{Name: "job-abcde", Containers: []Container{{Name: "worker", Cpu: 100, Memory: 300}, {Name: "agent", Cpu: 20, Memory: 40}}}},
+			},
+			containers: []ContainerPeak{{Name: "agent", Cpu: 20, Memory: 50}, {Name: "worker", Cpu: 200, Memory: 300}},
+		},
+	}
+
+	for _, test := range tests {
+		capture := jobCapture(t, jobPod("job-abcde", "job", v1Core.PodSucceeded))
+		capture.podJobs["job-abcde"] = "job"
+
+		for _, record := range test.records {
+			capture.trackJobPeaks(record)
+		}
+
+		if err := capture.saveJobSummary(finishedJob("job")); err != nil {
+			t.Fatalf("%s: %s", test.name, err.Error())
+		}
+		summary := readJobSummary(t, capture, "job")
+
+		if summary.Samples != len(test.records) {
+			t.Errorf("%s: expected %d samples, got %d", test.name, len(test.records), summary.Samples)
+		}
+		if !reflect.DeepEqual(summary.Containers, test.containers) {
+			t.Errorf("%s: expected containers %+v, got %+v", test.name, test.containers, summary.Containers)
+		}
+		if summary.Duration != 1 || len(summary.Pods) != 1 || summary.Pods[0].Phase != string(v1Core.PodSucceeded) {
+			t.Errorf("%s: unexpected summary %+v", test.name, summary)
+		}
+	}
+}
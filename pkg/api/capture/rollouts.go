@@ -0,0 +1,318 @@
+package capture
+
+import (
+	"fmt"
+	"pod_profiler/pkg/api/logging"
+	"strconv"
+	"time"
+
+	v1Apps "k8s.io/api/apps/v1"
+	v1Core "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	v1Meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// AnnotationType is the kind of change recorded on a target's timeline
+type AnnotationType string
+
+const (
+	AnnotationType_Revision AnnotationType = "revision"
+	AnnotationType_Image    AnnotationType = "image"
+	AnnotationType_Replicas AnnotationType = "replicas"
+)
+
+// Annotation marks a change to a profiled workload, so a capture can be split into before and after each release
+type Annotation struct {
+	DateStamp int64          `json:"datestamp"`
+	Workload  string         `json:"workload"`
+	Type      AnnotationType `json:"type"`
+	Revision  string         `json:"revision"`
+	Message   string         `json:"message"`
+}
+
+// The labels the workload controllers add to pods to identify the revision they were created from
+var revisionLabels = []string{
+	v1Apps.DefaultDeploymentUniqueLabelKey,
+	v1Apps.ControllerRevisionHashLabelKey,
+}
+
+// A change to a deployment's spec waiting for the deployment controller to create or pick the replica set for it
+type pendingRollout struct {
+	template v1Core.PodTemplateSpec
+	replicas *int32
+
+	// The pod template hash of the replica set before the change
+	hash string
+}
+
+// Returns the revision hash of the pod, or an empty string if the pod does not belong to a workload revision
+func podRevision(pod *v1Core.Pod) string {
+	for _, label := range revisionLabels {
+		if revision, exists := pod.GetLabels()[label]; exists {
+			return revision
+		}
+	}
+	return ""
+}
+
+// Registers the event handlers on the deployment and stateful set informers so that changes
+// to the profiled workloads are sent to onAnnotation until the capture is stopped
+func (capture *Capture) watchRollouts() error {
+
+	selector, err := capture.selector()
+	if err != nil {
+		return err
+	}
+
+	send := func(annotations []Annotation) {
+		for _, annotation := range annotations {
+			select {
+			case capture.onAnnotation <- annotation:
+			case <-capture.stopped:
+				return
+			}
+		}
+	}
+
+	// The workload informers are optional, only watch the ones that were included in the cache
+	if capture.client.Cache.Informers.Deployment != nil {
+		informer := capture.client.Cache.Informers.Deployment.Informer()
+		handle, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				oldDeployment, oldOk := oldObj.(*v1Apps.Deployment)
+				newDeployment, newOk := newObj.(*v1Apps.Deployment)
+				if !oldOk || !newOk || !capture.owns(newDeployment, selector) {
+					return
+				}
+
+				if !apiequality.Semantic.DeepEqual(oldDeployment.Spec.Template, newDeployment.Spec.Template) ||
+					replicas(oldDeployment.Spec.Replicas) != replicas(newDeployment.Spec.Replicas) {
+					capture.holdRollout(oldDeployment)
+				}
+				send(capture.releaseRollout(newDeployment))
+			},
+			DeleteFunc: func(obj interface{}) {
+				if deployment, ok := obj.(*v1Apps.Deployment); ok {
+					capture.mutex.Lock()
+					delete(capture.pendingRollouts, deployment.GetName())
+					capture.mutex.Unlock()
+				}
+			},
+		})
+		if err != nil {
+			return err
+		}
+		capture.workloadRegistrations = append(capture.workloadRegistrations, registration{informer, handle})
+
+		// The replica set for a change can reach the cache after the deployment has observed it
+		if capture.client.Cache.Informers.ReplicaSet != nil {
+			onReplicaSet := func(obj interface{}) {
+				replicaSet, ok := obj.(*v1Apps.ReplicaSet)
+				if !ok {
+					return
+				}
+				owner := v1Meta.GetControllerOf(replicaSet)
+				if owner == nil || owner.Kind != "Deployment" {
+					return
+				}
+				deployment, err := capture.client.Cache.Deployment().Get(owner.Name)
+				if err != nil || !capture.owns(deployment, selector) {
+					return
+				}
+				send(capture.releaseRollout(deployment))
+			}
+
+			informer := capture.client.Cache.Informers.ReplicaSet.Informer()
+			handle, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+				AddFunc: onReplicaSet,
+				UpdateFunc: func(oldObj, newObj interface{}) {
+					onReplicaSet(newObj)
+				},
+			})
+			if err != nil {
+				return err
+			}
+			capture.workloadRegistrations = append(capture.workloadRegistrations, registration{informer, handle})
+		}
+	}
+
+	if capture.client.Cache.Informers.StatefulSet != nil {
+		informer := capture.client.Cache.Informers.StatefulSet.Informer()
+		handle, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				oldStatefulSet, oldOk := oldObj.(*v1Apps.StatefulSet)
+				newStatefulSet, newOk := newObj.(*v1Apps.StatefulSet)
+				if !oldOk || !newOk || !capture.owns(newStatefulSet, selector) {
+					return
+				}
+
+				send(workloadAnnotations(
+					"StatefulSet/"+newStatefulSet.GetName(),
+					oldStatefulSet.Status.UpdateRevision,
+					newStatefulSet.Status.UpdateRevision,
+					&oldStatefulSet.Spec.Template, &newStatefulSet.Spec.Template,
+					oldStatefulSet.Spec.Replicas, newStatefulSet.Spec.Replicas,
+				))
+			},
+		})
+		if err != nil {
+			return err
+		}
+		capture.workloadRegistrations = append(capture.workloadRegistrations, registration{informer, handle})
+	}
+
+	return nil
+}
+
+// Remembers the deployment's spec before a change, unless an earlier change is still waiting for its replica set
+func (capture *Capture) holdRollout(deployment *v1Apps.Deployment) {
+
+	capture.mutex.Lock()
+	defer capture.mutex.Unlock()
+
+	if _, exists := capture.pendingRollouts[deployment.GetName()]; exists {
+		return
+	}
+
+	pending := &pendingRollout{
+		template: *deployment.Spec.Template.DeepCopy(),
+		hash:     capture.replicaSetHash(deployment),
+	}
+	if deployment.Spec.Replicas != nil {
+		count := *deployment.Spec.Replicas
+		pending.replicas = &count
+	}
+	capture.pendingRollouts[deployment.GetName()] = pending
+}
+
+// Returns the annotations for the deployment's change once the deployment controller has observed it and the replica
+// set for the new template is in the cache, so they carry that replica set's pod template hash. Returns nothing until then
+func (capture *Capture) releaseRollout(deployment *v1Apps.Deployment) []Annotation {
+
+	capture.mutex.Lock()
+	defer capture.mutex.Unlock()
+
+	pending, exists := capture.pendingRollouts[deployment.GetName()]
+	if !exists || deployment.Status.ObservedGeneration < deployment.GetGeneration() {
+		return nil
+	}
+
+	// Without the replica sets in the cache the change is annotated without its hash
+	hash := capture.replicaSetHash(deployment)
+	if hash == "" && capture.client.Cache.Informers.ReplicaSet != nil {
+		return nil
+	}
+	delete(capture.pendingRollouts, deployment.GetName())
+
+	return workloadAnnotations(
+		"Deployment/"+deployment.GetName(),
+		pending.hash,
+		hash,
+		&pending.template, &deployment.Spec.Template,
+		pending.replicas, deployment.Spec.Replicas,
+	)
+}
+
+// Returns the pod template hash of the deployment's replica set for its current template, or an empty string if that
+// replica set isn't in the cache
+func (capture *Capture) replicaSetHash(deployment *v1Apps.Deployment) string {
+
+	if capture.client.Cache.Informers.ReplicaSet == nil {
+		return ""
+	}
+
+	replicaSets, err := capture.client.Cache.ReplicaSet().List(labels.Everything())
+	if err != nil {
+		logging.Error().Printf("error: %s\n", err.Error())
+		return ""
+	}
+
+	for _, replicaSet := range replicaSets {
+		if v1Meta.IsControlledBy(replicaSet, deployment) && templateMatches(&replicaSet.Spec.Template, &deployment.Spec.Template) {
+			return replicaSet.GetLabels()[v1Apps.DefaultDeploymentUniqueLabelKey]
+		}
+	}
+
+	return ""
+}
+
+// Returns true if the replica set's template is the deployment's, the controller adds the pod template hash label to it
+func templateMatches(replicaSetTemplate, template *v1Core.PodTemplateSpec) bool {
+	a, b := replicaSetTemplate.DeepCopy(), template.DeepCopy()
+	delete(a.Labels, v1Apps.DefaultDeploymentUniqueLabelKey)
+	delete(b.Labels, v1Apps.DefaultDeploymentUniqueLabelKey)
+	return apiequality.Semantic.DeepEqual(a, b)
+}
+
+// Compares two versions of a workload and returns the annotations for the changes between them
+func workloadAnnotations(workload, oldRevision, newRevision string, oldTemplate, newTemplate *v1Core.PodTemplateSpec, oldReplicas, newReplicas *int32) []Annotation {
+
+	annotations := []Annotation{}
+	now := time.Now().Unix()
+
+	if oldRevision != newRevision && newRevision != "" {
+		annotations = append(annotations, Annotation{
+			DateStamp: now,
+			Workload:  workload,
+			Type:      AnnotationType_Revision,
+			Revision:  newRevision,
+			Message:   fmt.Sprintf("revision %s -> %s", oldRevision, newRevision),
+		})
+	}
+
+	oldImages := map[string]string{}
+	for _, container := range templateContainers(oldTemplate) {
+		oldImages[container.Name] = container.Image
+	}
+
+	for _, container := range templateContainers(newTemplate) {
+		if oldImage := oldImages[container.Name]; oldImage != container.Image {
+			annotations = append(annotations, Annotation{
+				DateStamp: now,
+				Workload:  workload,
+				Type:      AnnotationType_Image,
+				Revision:  newRevision,
+				Message:   fmt.Sprintf("%s image %s -> %s", container.Name, oldImage, container.Image),
+			})
+		}
+	}
+
+	if replicas(oldReplicas) != replicas(newReplicas) {
+		annotations = append(annotations, Annotation{
+			DateStamp: now,
+			Workload:  workload,
+			Type:      AnnotationType_Replicas,
+			Revision:  newRevision,
+			Message:   fmt.Sprintf("replicas %d -> %d", replicas(oldReplicas), replicas(newReplicas)),
+		})
+	}
+
+	return annotations
+}
+
+// Returns the template's init containers followed by its regular containers, without modifying the workload which
+// may be shared by the cache
+func templateContainers(template *v1Core.PodTemplateSpec) []v1Core.Container {
+	return append(append([]v1Core.Container{}, template.Spec.InitContainers...), template.Spec.Containers...)
+}
+
+// Returns the replica count of a workload spec, which defaults to one when it is not set
+func replicas(count *int32) int32 {
+	if count == nil {
+		return 1
+	}
+	return *count
+}
+
+// Returns the annotation as a row of the annotations results file
+func (annotation Annotation) row() []string {
+	return []string{
+		strconv.FormatInt(annotation.DateStamp, 10),
+		annotation.Workload,
+		string(annotation.Type),
+		annotation.Revision,
+		annotation.Message,
+	}
+}
@@ -0,0 +1,129 @@
+package capture
+
+import (
+	"context"
+	"pod_profiler/pkg/api/defaults"
+	kubernetesClient "pod_profiler/pkg/api/kubernetes-client"
+	"pod_profiler/pkg/api/kubernetes-client/fake"
+	"testing"
+	"time"
+
+	v1Apps "k8s.io/api/apps/v1"
+	v1Core "k8s.io/api/core/v1"
+	v1Meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+)
+
+func podTemplate(image string) v1Core.PodTemplateSpec {
+	return v1Core.PodTemplateSpec{
+		ObjectMeta: v1Meta.ObjectMeta{Labels: map[string]string{defaults.KUBERNETES_NAME_LABEL: "api"}},
+		Spec:       v1Core.PodSpec{Containers: []v1Core.Container{{Name: "api", Image: image}}},
+	}
+}
+
+func replicaSet(deployment *v1Apps.Deployment, hash, image string) *v1Apps.ReplicaSet {
+	template := podTemplate(image)
+	template.Labels[v1Apps.DefaultDeploymentUniqueLabelKey] = hash
+	return &v1Apps.ReplicaSet{
+		ObjectMeta: v1Meta.ObjectMeta{
+			Name:            deployment.GetName() + "-" + hash,
+			Namespace:       deployment.GetNamespace(),
+			Labels:          template.Labels,
+			OwnerReferences: []v1Meta.OwnerReference{*v1Meta.NewControllerRef(deployment, v1Apps.SchemeGroupVersion.WithKind("Deployment"))},
+		},
+		Spec: v1Apps.ReplicaSetSpec{Template: template},
+	}
+}
+
+// Returns the next annotation sent by the capture, or fails the test if none is sent in time
+func nextAnnotation(t *testing.T, capture *Capture) Annotation {
+	select {
+	case annotation := <-capture.onAnnotation:
+		return annotation
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected an annotation")
+	}
+	return Annotation{}
+}
+
+// The deployment controller bumps the deployment's template before it creates the replica set for it, the change is
+// annotated with the new replica set's hash once the deployment has observed it and that replica set is cached
+func TestDeploymentRollout(t *testing.T) {
+
+	deployment := &v1Apps.Deployment{
+		ObjectMeta: v1Meta.ObjectMeta{Name: "api", Namespace: "ns", UID: "api-uid", Generation: 1},
+		Spec:       v1Apps.DeploymentSpec{Template: podTemplate("api:1")},
+		Status:     v1Apps.DeploymentStatus{ObservedGeneration: 1},
+	}
+	client := fake.NewClientBuilder().WithClientsetRuntimeObjects(deployment, replicaSet(deployment, "h1", "api:1")).Build()
+
+	factory := informers.NewSharedInformerFactoryWithOptions(client.Clientset, 0, informers.WithNamespace("ns"))
+	client.Cache = &kubernetesClient.Cache{
+		Informers: &kubernetesClient.Informers{
+			Deployment: factory.Apps().V1().Deployments(),
+			ReplicaSet: factory.Apps().V1().ReplicaSets(),
+		},
+		Listers:   &kubernetesClient.Listers{},
+		Namespace: "ns",
+	}
+	client.Cache.Listers.Deployment = client.Cache.Informers.Deployment.Lister()
+	client.Cache.Listers.ReplicaSet = client.Cache.Informers.ReplicaSet.Lister()
+
+	capture := &Capture{
+		client:          client,
+		Deployment:      "api",
+		podSelector:     labels.SelectorFromSet(labels.Set{defaults.KUBERNETES_NAME_LABEL: "api"}),
+		onAnnotation:    make(chan Annotation),
+		stopped:         make(chan struct{}),
+		pendingRollouts: map[string]*pendingRollout{},
+	}
+	defer close(capture.stopped)
+
+	if err := capture.watchRollouts(); err != nil {
+		t.Fatal(err)
+	}
+	defer capture.unwatchWorkloads()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	factory.Start(stop)
+	factory.WaitForCacheSync(stop)
+
+	ctx := context.Background()
+
+	// The image changes before the deployment controller has seen it
+	updated := deployment.DeepCopy()
+	updated.Generation = 2
+	updated.Spec.Template = podTemplate("api:2")
+	if _, err := client.Clientset.AppsV1().Deployments("ns").Update(ctx, updated, v1Meta.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The controller observes the change and creates the new replica set
+	updated.Status.ObservedGeneration = 2
+	if _, err := client.Clientset.AppsV1().Deployments("ns").UpdateStatus(ctx, updated, v1Meta.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Clientset.AppsV1().ReplicaSets("ns").Create(ctx, replicaSet(updated, "h2", "api:2"), v1Meta.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Annotation{
+		{Workload: "Deployment/api", Type: AnnotationType_Revision, Revision: "h2", Message: "revision h1 -> h2"},
+		{Workload: "Deployment/api", Type: AnnotationType_Image, Revision: "h2", Message: "api image api:1 -> api:2"},
+	}
+	for _, want := range expected {
+		annotation := nextAnnotation(t, capture)
+		annotation.DateStamp = 0
+		if annotation != want {
+			t.Errorf("expected %+v, got %+v", want, annotation)
+		}
+	}
+
+	capture.mutex.Lock()
+	defer capture.mutex.Unlock()
+	if len(capture.pendingRollouts) != 0 {
+		t.Errorf("expected no pending rollouts, got %d", len(capture.pendingRollouts))
+	}
+}
@@ -0,0 +1,73 @@
+package capture
+
+import (
+	"fmt"
+	"pod_profiler/pkg/api/logging"
+	"pod_profiler/pkg/api/rollup"
+	"time"
+)
+
+// Adds the record's samples to the pod's rollups and writes the buckets that have closed
+func (capture *Capture) rollupRecord(record Record) {
+
+	aggregators, exists := capture.rollups[record.Pod.Name]
+	if !exists {
+		for _, tier := range rollup.Tiers {
+			aggregators = append(aggregators, rollup.NewAggregator(tier))
+		}
+		capture.rollups[record.Pod.Name] = aggregators
+	}
+
+	for i, tier := range rollup.Tiers {
+		rows := [][]string{}
+		for _, container := range record.Pod.Containers {
+			for _, bucket := range aggregators[i].Add(record.DateStamp, container.Name, container.Cpu, container.Memory) {
+				rows = append(rows, bucket.Row())
+			}
+		}
+
+		if err := capture.writeRows(record.Pod.Name, tier.Suffix, rows); err != nil {
+			logging.Error().Printf("error: %s\n", err.Error())
+		}
+	}
+}
+
+// Writes the pod's open buckets, which are partial if the pod is still running
+func (capture *Capture) flushRollups(podName string) {
+
+	aggregators, exists := capture.rollups[podName]
+	if !exists {
+		return
+	}
+
+	for i, tier := range rollup.Tiers {
+		rows := [][]string{}
+		for _, bucket := range aggregators[i].Flush() {
+			rows = append(rows, bucket.Row())
+		}
+
+		if err := capture.writeRows(podName, tier.Suffix, rows); err != nil {
+			logging.Error().Printf("error: %s\n", err.Error())
+		}
+	}
+}
+
+// Removes the raw samples that are older than the retention from the usage files of the pods that have been rolled up
+func (capture *Capture) pruneRawSamples() {
+
+	cutoff := time.Now().Add(-capture.RawRetention).Unix()
+
+	for podName := range capture.rollups {
+		filename := fmt.Sprintf("%s/%s%s", capture.resultsPath, podName, FileSuffix_Usage)
+
+		// The file is replaced by the prune, so close our handle and let the next sample reopen it
+		if file, exists := capture.files[filename]; exists {
+			file.Close()
+			delete(capture.files, filename)
+		}
+
+		if err := rollup.Prune(filename, cutoff); err != nil {
+			logging.Error().Printf("error: %s\n", err.Error())
+		}
+	}
+}
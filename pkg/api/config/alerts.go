@@ -0,0 +1,92 @@
+package config
+
+import (
+	"pod_profiler/pkg/api/defaults"
+	"time"
+)
+
+// Alerts configures the detection of anomalies and breached thresholds in the usage as it is captured,
+// and who is notified when one is found
+type Alerts struct {
+	Anomaly   Anomaly         `json:"anomaly"`
+	Rules     []AlertRule     `json:"rules"`
+	Notifiers []AlertNotifier `json:"notifiers"`
+}
+
+// Anomaly configures the detection of spikes against a moving average of each container's usage
+type Anomaly struct {
+	Enabled bool `json:"enabled"`
+
+	// How many standard deviations above the moving average a sample must be to be a spike
+	Threshold float64 `json:"threshold"`
+
+	// The weight of each new sample in the moving average, between 0 and 1
+	Alpha float64 `json:"alpha"`
+
+	// How many samples of a container are taken before it can spike, so the average has settled
+	Warmup int `json:"warmup"`
+
+	// The smallest rise above the moving average, as a fraction of it, that counts as a spike
+	MinChange float64 `json:"minchange"`
+}
+
+// AlertRule fires when a container's usage stays above a threshold for a while, such as CPU above 90% of its limit for 2m
+type AlertRule struct {
+	Name string `json:"name"`
+
+	// The usage the rule checks, one of "cpu" or "memory"
+	Metric string `json:"metric"`
+
+	// When set to "request" or "limit" the threshold is a percentage of the container's request or limit,
+	// otherwise it is in millicores or bytes
+	Of string `json:"of"`
+
+	Above float64 `json:"above"`
+
+	// How long the usage must stay above the threshold before the rule fires, such as "2m"
+	For string `json:"for"`
+}
+
+// AlertNotifier is told about each alert, either by posting it to a webhook or by running a local command
+type AlertNotifier struct {
+
+	// One of "webhook" or "command"
+	Type string `json:"type"`
+
+	// The URL the alert is posted to as JSON by a webhook
+	URL string `json:"url"`
+
+	// The command and its arguments, which is given the alert as JSON on stdin
+	Command []string `json:"command"`
+
+	// How long the notifier has to deliver the alert, such as "10s"
+	Timeout string `json:"timeout"`
+}
+
+// The alert rule metrics and what their thresholds can be relative to
+var validAlertMetrics = []string{"cpu", "memory"}
+var validAlertRelations = []string{"", "request", "limit"}
+var validNotifierTypes = []string{"webhook", "command"}
+
+// Returns how long the usage must stay above the threshold, an invalid duration is rejected by Validate so it is treated as zero here
+func (rule AlertRule) ForDuration() time.Duration {
+	duration, err := time.ParseDuration(rule.For)
+	if err != nil {
+		return 0
+	}
+	return duration
+}
+
+// Returns how long the notifier has to deliver an alert, or the default when it isn't set
+func (notifier AlertNotifier) TimeoutDuration() time.Duration {
+	timeout, err := time.ParseDuration(notifier.Timeout)
+	if err != nil || timeout <= 0 {
+		return defaults.ALERT_NOTIFY_TIMEOUT
+	}
+	return timeout
+}
+
+// Returns true if the config raises any alerts
+func (alerts Alerts) Enabled() bool {
+	return alerts.Anomaly.Enabled || len(alerts.Rules) > 0
+}
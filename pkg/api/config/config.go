@@ -1,12 +1,19 @@
 package config
 
 import (
-	"log"
+	"bytes"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"pod_profiler/pkg/api/config/env"
 	"pod_profiler/pkg/api/defaults"
+	"pod_profiler/pkg/api/logging"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
+	"sigs.k8s.io/yaml"
 )
 
 // This holds the config for the entire application
@@ -17,53 +24,249 @@ type Config struct {
 
 	PodLabels []string `json:"podlabels"`
 
+	// The app.kubernetes.io/name labels of the jobs (including those created by cron jobs) to profile
+	Jobs []string `json:"jobs"`
+
 	ResultsPath string `json:"resultspath"`
 
+	// The source usage samples are collected from, one of "metrics-server", "kubelet" or "cadvisor"
+	Collector string `json:"collector"`
+
+	// How long raw samples are kept before only their 1m, 5m and 1h rollups remain, such as "24h". Zero, the default,
+	// keeps them forever. Only the usage API reads the rollups, so the reports, recommendations, costs, leaks and
+	// summaries only cover the retention once it is set
+	RawRetention string `json:"rawretention"`
+
+	// The name of a config map in the namespace to watch for config changes instead of the config file
+	ConfigMap string `json:"configmap"`
+
+	// The key of the config map that holds the config, the extension of the key decides whether it is JSON or YAML
+	ConfigMapKey string `json:"configmapkey"`
+
+	// The fields from the platform to the lease name are only read when the profiler starts, reloading the config
+	// doesn't change them
+
+	// The platform the cluster runs on, one of "aws", "azure", "gcp", "coreweave" or "local". When blank it is detected
+	// from the cluster's nodes
+	Platform string `json:"platform"`
+
+	// The version of the autoscaling API HPAs are read with, either "v2" or "v2beta2". When blank the newest version
+	// the API server serves is used
+	HPAVersion string `json:"hpaversion"`
+
+	// When set, gatherer replicas campaign for the lease and only the one holding it captures, so replicas sharing
+	// the results volume never write the same files
+	LeaderElection bool `json:"leaderelection"`
+
+	// When set, the pods of each target are spread across the gatherer replicas by their UID and each replica writes
+	// to its own directory under the results path. Replicas join by holding a lease named after the lease name, and
+	// this can't be combined with leader election
+	Sharding bool `json:"sharding"`
+
+	// The name of the lease the replicas campaign for, or that the leases of the shards are named after
+	LeaseName string `json:"leasename"`
+
+	// The anomalies and thresholds alerts are raised for as the usage is captured, and who is notified of them
+	Alerts Alerts `json:"alerts"`
+
 	*viper.Viper `json:"-"`
 }
 
-func Load(watchConfig bool) (*Config, error) {
+// The environment variables each config field can be set from. Where a field has more than one, the first that is set is used.
+// The alert rules and notifiers are lists of objects, so they can only be set from a config file
+var envBindings = map[string][]string{
+	"namespace":      {"PROFILER_NAMESPACE", "NAMESPACE"},
+	"podlabels":      {"PROFILER_PODLABELS"},
+	"jobs":           {"PROFILER_JOBS"},
+	"resultspath":    {"PROFILER_RESULTSPATH"},
+	"collector":      {"PROFILER_COLLECTOR"},
+	"rawretention":   {"PROFILER_RAWRETENTION"},
+	"configmap":      {"PROFILER_CONFIGMAP"},
+	"configmapkey":   {"PROFILER_CONFIGMAPKEY", "PROFILER_CONFIGMAP_KEY"},
+	"platform":       {"PROFILER_PLATFORM"},
+	"hpaversion":     {"PROFILER_HPAVERSION"},
+	"leaderelection": {"PROFILER_LEADERELECTION"},
+	"sharding":       {"PROFILER_SHARDING"},
+	"leasename":      {"PROFILER_LEASENAME"},
+
+	"alerts.anomaly.enabled":   {"PROFILER_ALERTS_ANOMALY_ENABLED"},
+	"alerts.anomaly.threshold": {"PROFILER_ALERTS_ANOMALY_THRESHOLD"},
+	"alerts.anomaly.alpha":     {"PROFILER_ALERTS_ANOMALY_ALPHA"},
+	"alerts.anomaly.warmup":    {"PROFILER_ALERTS_ANOMALY_WARMUP"},
+	"alerts.anomaly.minchange": {"PROFILER_ALERTS_ANOMALY_MINCHANGE"},
+}
+
+// The path of the config file to load. When empty the config directories are searched for
+// PROFILER_CONFIG_FILENAME, or a file named config with a .json, .yaml or .yml extension
+var ConfigFile string = os.Getenv("PROFILER_CONFIG_FILE")
+
+// Values that take precedence over every other source, such as those set by command line flags, keyed by config field
+var Overrides = map[string]interface{}{}
+
+// Creates an empty config with the defaults, environment variable bindings and overrides applied.
+// The values are used in order of precedence: overrides > environment variables > config file > defaults
+func newConfig() *Config {
 	// Initialise an empty config
 	config := &Config{}
 
+	configName, configNameExists := os.LookupEnv("PROFILER_CONFIG_FILENAME")
+	if !configNameExists {
+		configName = "config.json"
+	}
+
 	config.Viper = viper.New()
 	config.Viper.SetDefault("podlabels", []string{})
+	config.Viper.SetDefault("jobs", []string{})
 	config.Viper.SetDefault("namespace", defaults.NAMESPACE)
 	config.Viper.SetDefault("resultspath", defaults.RESULTS_PATH)
+	config.Viper.SetDefault("collector", defaults.COLLECTOR)
+	config.Viper.SetDefault("rawretention", defaults.RAW_RETENTION)
+	config.Viper.SetDefault("configmap", "")
+	config.Viper.SetDefault("configmapkey", configName)
+	config.Viper.SetDefault("platform", "")
+	config.Viper.SetDefault("hpaversion", "")
+	config.Viper.SetDefault("leaderelection", false)
+	config.Viper.SetDefault("sharding", false)
+	config.Viper.SetDefault("leasename", defaults.LEASE_NAME)
+	config.Viper.SetDefault("alerts.anomaly.enabled", false)
+	config.Viper.SetDefault("alerts.anomaly.threshold", defaults.ALERT_ANOMALY_THRESHOLD)
+	config.Viper.SetDefault("alerts.anomaly.alpha", defaults.ALERT_ANOMALY_ALPHA)
+	config.Viper.SetDefault("alerts.anomaly.warmup", defaults.ALERT_ANOMALY_WARMUP)
+	config.Viper.SetDefault("alerts.anomaly.minchange", defaults.ALERT_ANOMALY_MIN_CHANGE)
 
-	config.Viper.BindEnv("namespace", "NAMESPACE")
+	// List values can be set from the environment as comma separated values, e.g. PROFILER_PODLABELS=sps-api,sps-coturn
+	for key, names := range envBindings {
+		config.Viper.BindEnv(append([]string{key}, names...)...)
+	}
 
-	configName, configNameExists := os.LookupEnv("PROFILER_CONFIG_FILENAME")
+	for key, value := range Overrides {
+		config.Viper.Set(key, value)
+	}
 
-	configDirs, err := env.GetConfigDirectories("pod-profiler-gatherer", false)
-	if err != nil {
+	return config
+}
+
+// TypeFromFilename returns the config type for the file extension of the given filename, or an empty string if it is not supported
+func TypeFromFilename(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	}
+	return ""
+}
+
+// Parses the config from the contents of a JSON or YAML config file, such as the data of a config map.
+// The config type is either "json" or "yaml"
+func Parse(data []byte, configType string) (*Config, error) {
+
+	// The schema is written against JSON, so check YAML documents in their JSON form
+	document := data
+	if configType == "yaml" {
+		var err error
+		document, err = yaml.YAMLToJSON(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ValidateSchema(document); err != nil {
+		return nil, err
+	}
+
+	config := newConfig()
+	config.Viper.SetConfigType(configType)
+
+	if err := config.Viper.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the config
+	if err := config.Viper.Unmarshal(config); err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
 		return nil, err
 	}
-	// Add the configuration directories to our search list
-	for _, dir := range configDirs {
-		config.Viper.AddConfigPath(dir)
+
+	return config, nil
+}
+
+// Checks the config file at the given path without loading it, a path of "-" reads the config from stdin
+func ValidateFile(filename string) error {
+
+	var data []byte
+	var err error
+
+	if filename == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(filename)
+	}
+	if err != nil {
+		return err
 	}
 
-	// Attempt to parse our JSON configuration file if it exists in one of the directories
-	if configNameExists {
-		config.Viper.SetConfigName(configName)
+	// Use the extension to pick the format, and for stdin assume anything that isn't a JSON object is YAML
+	format := TypeFromFilename(filename)
+	if format == "" {
+		format = "yaml"
+		if strings.HasPrefix(strings.TrimSpace(string(data)), "{") {
+			format = "json"
+		}
+	}
+
+	_, err = Parse(data, format)
+	return err
+}
+
+func Load(watchConfig bool) (*Config, error) {
+
+	config := newConfig()
+
+	configName, configNameExists := os.LookupEnv("PROFILER_CONFIG_FILENAME")
+
+	if ConfigFile != "" {
+
+		// Use the config file we were given rather than searching for one
+		config.Viper.SetConfigFile(ConfigFile)
+		config.Viper.SetConfigType(TypeFromFilename(ConfigFile))
 	} else {
-		config.Viper.SetConfigName("config")
+
+		configDirs, err := env.GetConfigDirectories("pod-profiler-gatherer", false)
+		if err != nil {
+			return nil, err
+		}
+		// Add the configuration directories to our search list
+		for _, dir := range configDirs {
+			config.Viper.AddConfigPath(dir)
+		}
+
+		// Attempt to parse our configuration file if it exists in one of the directories. When the name has no
+		// extension viper tries each of the extensions it supports
+		if configNameExists {
+			config.Viper.SetConfigName(configName)
+			config.Viper.SetConfigType(TypeFromFilename(configName))
+		} else {
+			config.Viper.SetConfigName("config")
+		}
 	}
 
-	config.Viper.SetConfigType("json")
 	useConfigFile := true
 	if err := config.Viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			log.Default().Println("Configuration file not found, using configuration values from environment variables")
+			logging.Info().Println("Configuration file not found, using configuration values from environment variables")
 			useConfigFile = false
 		} else {
 			return nil, err
 		}
 	}
 
-	// Watch the config file (if we found one) and ensure that we update our config when a change is detected
-	if useConfigFile {
+	// Watch the config file (if we found one) and ensure that we update our config when a change is detected.
+	// When a config map is being watched instead, the profiler picks up changes through the informer
+	if useConfigFile && config.Viper.GetString("configmap") == "" {
 
 		// Check the watch config is true
 		if watchConfig {
@@ -73,29 +276,97 @@ func Load(watchConfig bool) (*Config, error) {
 		}
 	}
 
+	// Check the file against the schema, so that mistakes viper would quietly accept are reported
+	if useConfigFile {
+		filename := config.Viper.ConfigFileUsed()
+
+		format := TypeFromFilename(filename)
+		if format == "" {
+			return nil, fmt.Errorf("unsupported config file %s, the config must be a .json, .yaml or .yml file", filename)
+		}
+
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		if format == "yaml" {
+			data, err = yaml.YAMLToJSON(data)
+			if err != nil {
+				return nil, fmt.Errorf("invalid config file %s: %s", filename, err.Error())
+			}
+		}
+
+		if err := ValidateSchema(data); err != nil {
+			return nil, fmt.Errorf("invalid config file %s:\n%s", filename, err.Error())
+		}
+	}
+
 	// Unmarshal the config
 	if err := config.Viper.Unmarshal(config); err != nil {
 		return nil, err
 	}
 
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
 	return config, nil
 }
 
+// Returns the raw retention as a duration, an invalid retention is rejected by Validate so it is treated as zero here
+func (config *Config) RawRetentionDuration() time.Duration {
+	retention, err := time.ParseDuration(config.RawRetention)
+	if err != nil {
+		return 0
+	}
+	return retention
+}
+
 func (config *Config) VarDump() {
 
 	// Print our configuration values
-	log.Default().Println("")
-	log.Default().Println("-------------------------------")
-	log.Default().Println("")
-	log.Default().Printf("namespace:  %s\n", config.Namespace)
-	log.Default().Printf("results dir:  %s\n", config.ResultsPath)
-	log.Default().Printf("Pod Labels:\n")
+	logging.Info().Println("")
+	logging.Info().Println("-------------------------------")
+	logging.Info().Println("")
+	logging.Info().Printf("namespace:  %s\n", config.Namespace)
+	logging.Info().Printf("results dir:  %s\n", config.ResultsPath)
+	logging.Info().Printf("collector:  %s\n", config.Collector)
+	logging.Info().Printf("raw retention:  %s\n", config.RawRetention)
+
+	if config.Platform != "" {
+		logging.Info().Printf("platform:  %s\n", config.Platform)
+	}
+	if config.HPAVersion != "" {
+		logging.Info().Printf("hpa version:  %s\n", config.HPAVersion)
+	}
+
+	if config.LeaderElection {
+		logging.Info().Printf("leader election:  %s\n", config.LeaseName)
+	}
+	if config.Sharding {
+		logging.Info().Printf("sharding:  %s\n", config.LeaseName)
+	}
+
+	if config.ConfigMap != "" {
+		logging.Info().Printf("config map:  %s/%s\n", config.ConfigMap, config.ConfigMapKey)
+	}
+	if config.Alerts.Enabled() {
+		logging.Info().Printf("alerts:  anomaly detection %t, %d rules, %d notifiers\n", config.Alerts.Anomaly.Enabled, len(config.Alerts.Rules), len(config.Alerts.Notifiers))
+	}
+	logging.Info().Printf("Pod Labels:\n")
 
 	for _, deployment := range config.PodLabels {
-		log.Default().Printf("\t%s\n", deployment)
+		logging.Info().Printf("\t%s\n", deployment)
+	}
+
+	logging.Info().Printf("Jobs:\n")
+
+	for _, job := range config.Jobs {
+		logging.Info().Printf("\t%s\n", job)
 	}
 
-	log.Default().Println("")
-	log.Default().Println("-------------------------------")
-	log.Default().Println("")
+	logging.Info().Println("")
+	logging.Info().Println("-------------------------------")
+	logging.Info().Println("")
 }
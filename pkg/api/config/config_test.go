@@ -0,0 +1,159 @@
+package config
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Writes the config file to a temporary directory and returns its path
+func writeConfig(t *testing.T, filename, contents string) string {
+	filename = path.Join(t.TempDir(), filename)
+	if err := os.WriteFile(filename, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestValidateFile(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		filename string
+		contents string
+		err      string
+	}{
+		{"json", "config.json", `{"namespace": "sps", "podlabels": ["sps-api"]}`, ""},
+		{"yaml", "config.yaml", "namespace: sps\npodlabels:\n  - sps-api\n", ""},
+		{"yml", "config.yml", "collector: kubelet\n", ""},
+
+		// Without a known extension a JSON object is read as JSON and anything else as YAML
+		{"json without extension", "config", `{"collector": "kubelet"}`, ""},
+		{"yaml without extension", "config", "collector: kubelet\n", ""},
+
+		{"unknown key", "config.yaml", "namespace: sps\npodlabel:\n  - sps-api\n", "podlabel: is not a known config field"},
+		{"wrong type", "config.json", `{"sharding": "yes"}`, "sharding: must be of type boolean, got string"},
+		{"enum", "config.yaml", "collector: prometheus\n", `collector: must be one of "metrics-server", "kubelet", "cadvisor", got "prometheus"`},
+
+		// Mistakes the schema can't see are caught by Validate
+		{"validate", "config.json", `{"leaderelection": true, "sharding": true}`, "sharding: can't be combined with leaderelection"},
+		{"invalid yaml", "config.yaml", "namespace: [sps\n", "yaml: line 1"},
+	}
+
+	for _, test := range tests {
+		err := ValidateFile(writeConfig(t, test.filename, test.contents))
+
+		if test.err == "" && err != nil {
+			t.Errorf("%s: expected no error, got %s", test.name, err.Error())
+		}
+		if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%s: expected %s, got %v", test.name, test.err, err)
+		}
+	}
+
+	if err := ValidateFile(path.Join(t.TempDir(), "missing.yaml")); !os.IsNotExist(err) {
+		t.Errorf("expected a missing file error, got %v", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+
+	file := `{
+		"namespace": "sps",
+		"podlabels": ["sps-api"],
+		"collector": "cadvisor",
+		"alerts": {"anomaly": {"enabled": true, "threshold": 3}}
+	}`
+
+	tests := []struct {
+		name  string
+		file  string
+		env   map[string]string
+		check func(config *Config) bool
+		err   string
+	}{
+		{
+			name: "file",
+			file: file,
+			check: func(config *Config) bool {
+				return config.Namespace == "sps" && config.Collector == "cadvisor" && reflect.DeepEqual(config.PodLabels, []string{"sps-api"}) &&
+					config.Alerts.Anomaly.Threshold == 3 && config.Alerts.Anomaly.Alpha == 0.1
+			},
+		},
+		{
+			// The environment variables take precedence over the file
+			name: "environment",
+			file: file,
+			env: map[string]string{
+				"PROFILER_COLLECTOR":                "kubelet",
+				"PROFILER_PODLABELS":                "sps-api,sps-coturn",
+				"PROFILER_ALERTS_ANOMALY_THRESHOLD": "5",
+				"PROFILER_ALERTS_ANOMALY_WARMUP":    "10",
+			},
+			check: func(config *Config) bool {
+				return config.Namespace == "sps" && config.Collector == "kubelet" && reflect.DeepEqual(config.PodLabels, []string{"sps-api", "sps-coturn"}) &&
+					config.Alerts.Anomaly.Threshold == 5 && config.Alerts.Anomaly.Warmup == 10
+			},
+		},
+		{
+			// A field can be set from any of its variables
+			name: "second variable",
+			file: file,
+			env:  map[string]string{"NAMESPACE": "other"},
+			check: func(config *Config) bool {
+				return config.Namespace == "other"
+			},
+		},
+		{
+			// The schema only checks the file, so the values from the environment are checked by Validate
+			name: "invalid environment",
+			file: file,
+			env:  map[string]string{"PROFILER_COLLECTOR": "prometheus"},
+			err:  `collector: must be one of metrics-server, kubelet, cadvisor, got "prometheus"`,
+		},
+		{
+			name: "invalid anomaly from the environment",
+			file: file,
+			env:  map[string]string{"PROFILER_ALERTS_ANOMALY_ALPHA": "2"},
+			err:  "alerts.anomaly.alpha: must be greater than 0 and at most 1",
+		},
+		{
+			name: "invalid file",
+			file: `{"namespace": "sps", "colector": "kubelet"}`,
+			err:  "colector: is not a known config field",
+		},
+	}
+
+	configFile := ConfigFile
+	defer func() { ConfigFile = configFile }()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, name := range []string{"PROFILER_NAMESPACE", "NAMESPACE", "PROFILER_COLLECTOR", "PROFILER_PODLABELS"} {
+				t.Setenv(name, "")
+				os.Unsetenv(name)
+			}
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+			ConfigFile = writeConfig(t, "config.json", test.file)
+
+			config, err := Load(false)
+
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("expected %s, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !test.check(config) {
+				t.Errorf("unexpected config %+v", config)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package config
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// The JSON Schema for the config file
+//
+//go:embed schema.json
+var Schema []byte
+
+// The subset of JSON Schema keywords used by our config schema
+type schema struct {
+	Type                 string             `json:"type"`
+	Properties           map[string]*schema `json:"properties"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Required             []string           `json:"required"`
+	Items                *schema            `json:"items"`
+	Enum                 []interface{}      `json:"enum"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Pattern              string             `json:"pattern"`
+	UniqueItems          bool               `json:"uniqueItems"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+}
+
+// SchemaError describes a value that does not match the schema, along with the path of the field it belongs to
+type SchemaError struct {
+	Field   string
+	Message string
+}
+
+func (err *SchemaError) Error() string {
+	if err.Field == "" {
+		return err.Message
+	}
+	return fmt.Sprintf("%s: %s", err.Field, err.Message)
+}
+
+// SchemaErrors is every mismatch found in a document
+type SchemaErrors []*SchemaError
+
+func (errs SchemaErrors) Error() string {
+	messages := []string{}
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// ValidateSchema checks the JSON config document against the config schema
+func ValidateSchema(data []byte) error {
+
+	root := &schema{}
+	if err := json.Unmarshal(Schema, root); err != nil {
+		return fmt.Errorf("invalid config schema: %s", err.Error())
+	}
+
+	var document interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return &SchemaError{Message: fmt.Sprintf("invalid JSON: %s", err.Error())}
+	}
+
+	errs := SchemaErrors{}
+	root.validate("", document, &errs)
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Appends an error to errs for each way the value does not match the schema
+func (s *schema) validate(field string, value interface{}, errs *SchemaErrors) {
+
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, &SchemaError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.Type != "" && jsonType(value) != s.Type && !(s.Type == "number" && jsonType(value) == "integer") {
+		fail("must be of type %s, got %s", s.Type, jsonType(value))
+		return
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		options := []string{}
+		for _, option := range s.Enum {
+			if fmt.Sprint(option) == fmt.Sprint(value) {
+				found = true
+			}
+			options = append(options, fmt.Sprintf("%q", fmt.Sprint(option)))
+		}
+		if !found {
+			fail("must be one of %s, got %q", strings.Join(options, ", "), fmt.Sprint(value))
+		}
+	}
+
+	switch typed := value.(type) {
+
+	case string:
+		length := utf8.RuneCountInString(typed)
+		if s.MinLength != nil && length < *s.MinLength {
+			if *s.MinLength == 1 {
+				fail("must not be empty")
+			} else {
+				fail("must be at least %d characters", *s.MinLength)
+			}
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			fail("must be at most %d characters", *s.MaxLength)
+		}
+		if s.Pattern != "" && length > 0 {
+			pattern, err := regexp.Compile(s.Pattern)
+			if err != nil {
+				fail("invalid schema pattern %q", s.Pattern)
+			} else if !pattern.MatchString(typed) {
+				fail("%q does not match the pattern %s", typed, s.Pattern)
+			}
+		}
+
+	case float64:
+		if s.Minimum != nil && typed < *s.Minimum {
+			fail("must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && typed > *s.Maximum {
+			fail("must be at most %v", *s.Maximum)
+		}
+
+	case []interface{}:
+		seen := map[string]int{}
+		for i, item := range typed {
+			itemField := fmt.Sprintf("%s[%d]", field, i)
+			if s.Items != nil {
+				s.Items.validate(itemField, item, errs)
+			}
+			if s.UniqueItems {
+				key := fmt.Sprint(item)
+				if first, exists := seen[key]; exists {
+					*errs = append(*errs, &SchemaError{Field: itemField, Message: fmt.Sprintf("duplicates %s[%d]", field, first)})
+				} else {
+					seen[key] = i
+				}
+			}
+		}
+
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, exists := typed[name]; !exists {
+				*errs = append(*errs, &SchemaError{Field: join(field, name), Message: "is required"})
+			}
+		}
+
+		// Sort the keys so the errors are reported in a stable order
+		names := []string{}
+		for name := range typed {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			property, known := s.Properties[name]
+			if !known {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					*errs = append(*errs, &SchemaError{Field: join(field, name), Message: "is not a known config field"})
+				}
+				continue
+			}
+			property.validate(join(field, name), typed[name], errs)
+		}
+	}
+}
+
+// Returns the JSON Schema type name of a decoded JSON value
+func jsonType(value interface{}) string {
+	switch typed := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if typed == float64(int64(typed)) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "unknown"
+}
+
+// Returns the path of a property of an object
+func join(field, name string) string {
+	if field == "" {
+		return name
+	}
+	return field + "." + name
+}
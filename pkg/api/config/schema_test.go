@@ -0,0 +1,129 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateSchema(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		document string
+		errors   []string
+	}{
+		{"empty", `{}`, nil},
+		{
+			name: "valid",
+			document: `{
+				"namespace": "sps", "podlabels": ["sps-api", "sps_coturn.v2"], "collector": "cadvisor", "rawretention": "1h30m",
+				"platform": "", "hpaversion": "v2", "leasename": "profiler.sps",
+				"alerts": {
+					"anomaly": {"enabled": true, "threshold": 3, "alpha": 0.5, "warmup": 10},
+					"rules": [{"name": "cpu", "metric": "cpu", "of": "limit", "above": 90, "for": "2m"}],
+					"notifiers": [{"type": "webhook", "url": "https://example.com/alerts", "timeout": "5s"}]
+				}
+			}`,
+			errors: nil,
+		},
+		{"invalid JSON", `{"namespace": }`, []string{"invalid JSON: invalid character '}' looking for beginning of value"}},
+		{"not an object", `[]`, []string{"must be of type object, got array"}},
+
+		// Unknown keys, which viper would otherwise ignore
+		{"unknown key", `{"colector": "kubelet"}`, []string{"colector: is not a known config field"}},
+		{"unknown nested key", `{"alerts": {"anomaly": {"enable": true}}}`, []string{"alerts.anomaly.enable: is not a known config field"}},
+		{"unknown rule key", `{"alerts": {"rules": [{"name": "cpu", "metric": "cpu", "above": 1, "below": 2}]}}`, []string{"alerts.rules[0].below: is not a known config field"}},
+
+		// Types
+		{"string as number", `{"namespace": 5}`, []string{"namespace: must be of type string, got integer"}},
+		{"boolean as string", `{"sharding": "true"}`, []string{"sharding: must be of type boolean, got string"}},
+		{"list as string", `{"podlabels": "sps-api"}`, []string{"podlabels: must be of type array, got string"}},
+		{"integer as number", `{"alerts": {"anomaly": {"threshold": 3}}}`, nil},
+		{"number as integer", `{"alerts": {"anomaly": {"warmup": 1.5}}}`, []string{"alerts.anomaly.warmup: must be of type integer, got number"}},
+		{"null", `{"collector": null}`, []string{"collector: must be of type string, got null"}},
+
+		// Enums
+		{"collector", `{"collector": "prometheus"}`, []string{`collector: must be one of "metrics-server", "kubelet", "cadvisor", got "prometheus"`}},
+		{"empty platform", `{"platform": ""}`, nil},
+		{"platform", `{"platform": "AWS"}`, []string{`platform: must be one of "", "aws", "azure", "gcp", "coreweave", "local", got "AWS"`}},
+		{"rule metric", `{"alerts": {"rules": [{"name": "disk", "metric": "disk", "above": 1}]}}`, []string{`alerts.rules[0].metric: must be one of "cpu", "memory", got "disk"`}},
+
+		// Strings
+		{"empty namespace", `{"namespace": ""}`, []string{"namespace: must not be empty"}},
+		{"long namespace", `{"namespace": "` + strings.Repeat("a", 64) + `"}`, []string{"namespace: must be at most 63 characters"}},
+		{"pattern", `{"namespace": "Sps"}`, []string{`namespace: "Sps" does not match the pattern ^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`}},
+		{"duration", `{"rawretention": "1 day"}`, []string{`rawretention: "1 day" does not match the pattern ^(0|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)$`}},
+
+		// Numbers
+		{"minimum", `{"alerts": {"anomaly": {"warmup": 0}}}`, []string{"alerts.anomaly.warmup: must be at least 1"}},
+		{"maximum", `{"alerts": {"anomaly": {"alpha": 1.5}}}`, []string{"alerts.anomaly.alpha: must be at most 1"}},
+
+		// Arrays and objects
+		{"duplicate", `{"jobs": ["backup", "report", "backup"]}`, []string{"jobs[2]: duplicates jobs[0]"}},
+		{"item", `{"podlabels": ["sps-api", ""]}`, []string{"podlabels[1]: must not be empty"}},
+		{"required", `{"alerts": {"notifiers": [{"url": "https://example.com"}]}}`, []string{"alerts.notifiers[0].type: is required"}},
+
+		// Every error is reported in the order of the sorted keys
+		{
+			name:     "several",
+			document: `{"sharding": 1, "collector": "prometheus", "alerts": {"rules": [{"name": "cpu"}]}}`,
+			errors: []string{
+				"alerts.rules[0].metric: is required",
+				"alerts.rules[0].above: is required",
+				`collector: must be one of "metrics-server", "kubelet", "cadvisor", got "prometheus"`,
+				"sharding: must be of type boolean, got integer",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		err := ValidateSchema([]byte(test.document))
+
+		var messages []string
+		switch typed := err.(type) {
+		case nil:
+		case SchemaErrors:
+			for _, err := range typed {
+				messages = append(messages, err.Error())
+			}
+		default:
+			messages = []string{err.Error()}
+		}
+
+		if !reflect.DeepEqual(messages, test.errors) {
+			t.Errorf("%s: expected %q, got %q", test.name, test.errors, messages)
+		}
+	}
+}
+
+func TestSchemaMatchesConfig(t *testing.T) {
+
+	// Every config field can be set in the file, so the schema doesn't reject a field the profiler reads
+	fields := map[string]interface{}{
+		"namespace": "sps", "podlabels": []string{}, "jobs": []string{}, "resultspath": "/results", "collector": "kubelet",
+		"rawretention": "0", "configmap": "profiler", "configmapkey": "config.yaml", "platform": "gcp", "hpaversion": "v2beta2",
+		"leaderelection": false, "sharding": true, "leasename": "profiler", "alerts": map[string]interface{}{},
+	}
+
+	configType := reflect.TypeOf(Config{})
+	for i := 0; i < configType.NumField(); i++ {
+		tag := configType.Field(i).Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		if _, exists := fields[tag]; !exists {
+			t.Errorf("expected the test to cover the %s field", tag)
+		}
+	}
+
+	document, err := json.Marshal(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ValidateSchema(document); err != nil {
+		t.Errorf("expected every config field to be accepted, got %s", err.Error())
+	}
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"pod_profiler/pkg/api/defaults/cloud"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// The collectors a config can select
+var validCollectors = []string{"metrics-server", "kubelet", "cadvisor"}
+
+// The autoscaling API versions a config can select, empty uses the newest the API server serves
+var validHPAVersions = []string{"", "v2", "v2beta2"}
+
+// Validate checks the config values that viper can unmarshal but the profiler can't use
+func (config *Config) Validate() error {
+
+	if config.Namespace == "" {
+		return fmt.Errorf("namespace: must not be empty")
+	}
+
+	if config.ResultsPath == "" {
+		return fmt.Errorf("resultspath: must not be empty")
+	}
+
+	if !contains(validCollectors, config.Collector) {
+		return fmt.Errorf("collector: must be one of %s, got %q", strings.Join(validCollectors, ", "), config.Collector)
+	}
+
+	if retention, err := time.ParseDuration(config.RawRetention); err != nil {
+		return fmt.Errorf("rawretention: %q is not a duration such as 24h", config.RawRetention)
+	} else if retention < 0 {
+		return fmt.Errorf("rawretention: must not be negative")
+	}
+
+	if config.Platform != "" {
+		if _, err := cloud.ParsePlatform(config.Platform); err != nil {
+			return fmt.Errorf("platform: %s", err.Error())
+		}
+	}
+
+	if !contains(validHPAVersions, config.HPAVersion) {
+		return fmt.Errorf("hpaversion: must be v2, v2beta2 or empty, got %q", config.HPAVersion)
+	}
+
+	if config.LeaderElection && config.Sharding {
+		return fmt.Errorf("sharding: can't be combined with leaderelection")
+	}
+
+	if config.LeaderElection || config.Sharding {
+		if errs := validation.IsDNS1123Subdomain(config.LeaseName); len(errs) > 0 {
+			return fmt.Errorf("leasename: %q is not a valid lease name", config.LeaseName)
+		}
+	}
+
+	for field, names := range map[string][]string{"podlabels": config.PodLabels, "jobs": config.Jobs} {
+		seen := map[string]bool{}
+		for i, name := range names {
+			if errs := validation.IsValidLabelValue(name); name == "" || len(errs) > 0 {
+				return fmt.Errorf("%s[%d]: %q is not a valid label value", field, i, name)
+			}
+			if seen[name] {
+				return fmt.Errorf("%s[%d]: %q is listed more than once", field, i, name)
+			}
+			seen[name] = true
+		}
+	}
+
+	return config.Alerts.validate()
+}
+
+// Checks the alert rules and notifiers can be used
+func (alerts Alerts) validate() error {
+
+	if alerts.Anomaly.Enabled {
+		if alerts.Anomaly.Threshold <= 0 {
+			return fmt.Errorf("alerts.anomaly.threshold: must be greater than 0")
+		}
+		if alerts.Anomaly.Alpha <= 0 || alerts.Anomaly.Alpha > 1 {
+			return fmt.Errorf("alerts.anomaly.alpha: must be greater than 0 and at most 1")
+		}
+		if alerts.Anomaly.Warmup < 1 {
+			return fmt.Errorf("alerts.anomaly.warmup: must be at least 1")
+		}
+		if alerts.Anomaly.MinChange < 0 {
+			return fmt.Errorf("alerts.anomaly.minchange: must not be negative")
+		}
+	}
+
+	names := map[string]bool{}
+	for i, rule := range alerts.Rules {
+		if rule.Name == "" {
+			return fmt.Errorf("alerts.rules[%d].name: must not be empty", i)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("alerts.rules[%d].name: %q is listed more than once", i, rule.Name)
+		}
+		names[rule.Name] = true
+
+		if !contains(validAlertMetrics, rule.Metric) {
+			return fmt.Errorf("alerts.rules[%d].metric: must be one of %s, got %q", i, strings.Join(validAlertMetrics, ", "), rule.Metric)
+		}
+		if !contains(validAlertRelations, rule.Of) {
+			return fmt.Errorf("alerts.rules[%d].of: must be request, limit or empty, got %q", i, rule.Of)
+		}
+		if rule.Above <= 0 {
+			return fmt.Errorf("alerts.rules[%d].above: must be greater than 0", i)
+		}
+		if rule.For != "" {
+			if duration, err := time.ParseDuration(rule.For); err != nil {
+				return fmt.Errorf("alerts.rules[%d].for: %q is not a duration such as 2m", i, rule.For)
+			} else if duration < 0 {
+				return fmt.Errorf("alerts.rules[%d].for: must not be negative", i)
+			}
+		}
+	}
+
+	for i, notifier := range alerts.Notifiers {
+		switch notifier.Type {
+		case "webhook":
+			if parsed, err := url.Parse(notifier.URL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				return fmt.Errorf("alerts.notifiers[%d].url: %q is not an http or https URL", i, notifier.URL)
+			}
+		case "command":
+			if len(notifier.Command) == 0 || notifier.Command[0] == "" {
+				return fmt.Errorf("alerts.notifiers[%d].command: must not be empty", i)
+			}
+		default:
+			return fmt.Errorf("alerts.notifiers[%d].type: must be one of %s, got %q", i, strings.Join(validNotifierTypes, ", "), notifier.Type)
+		}
+
+		if notifier.Timeout != "" {
+			if _, err := time.ParseDuration(notifier.Timeout); err != nil {
+				return fmt.Errorf("alerts.notifiers[%d].timeout: %q is not a duration such as 10s", i, notifier.Timeout)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns true if the value is one of the options
+func contains(options []string, value string) bool {
+	for _, option := range options {
+		if option == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"testing"
+)
+
+// Returns a config that passes validation, as loaded from an empty config file
+func validConfig() *Config {
+	return &Config{
+		Namespace:    "sps",
+		ResultsPath:  "./results",
+		Collector:    "metrics-server",
+		RawRetention: "0",
+		LeaseName:    "pod-profiler-gatherer",
+		Alerts: Alerts{
+			Anomaly: Anomaly{Threshold: 4, Alpha: 0.1, Warmup: 30, MinChange: 0.2},
+		},
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		change func(config *Config)
+		err    string
+	}{
+		{"valid", func(config *Config) {}, ""},
+		{"namespace", func(config *Config) { config.Namespace = "" }, "namespace: must not be empty"},
+		{"results path", func(config *Config) { config.ResultsPath = "" }, "resultspath: must not be empty"},
+		{"collector", func(config *Config) { config.Collector = "prometheus" }, `collector: must be one of metrics-server, kubelet, cadvisor, got "prometheus"`},
+		{"retention", func(config *Config) { config.RawRetention = "24h" }, ""},
+		{"retention duration", func(config *Config) { config.RawRetention = "1d" }, `rawretention: "1d" is not a duration such as 24h`},
+		{"negative retention", func(config *Config) { config.RawRetention = "-1h" }, "rawretention: must not be negative"},
+		{"platform", func(config *Config) { config.Platform = "GCP" }, ""},
+		{"unknown platform", func(config *Config) { config.Platform = "openstack" }, `platform: unknown platform "openstack", must be one of [aws coreweave azure gcp local]`},
+		{"hpa version", func(config *Config) { config.HPAVersion = "v1" }, `hpaversion: must be v2, v2beta2 or empty, got "v1"`},
+		{"leader election and sharding", func(config *Config) { config.LeaderElection, config.Sharding = true, true }, "sharding: can't be combined with leaderelection"},
+		{"lease name", func(config *Config) { config.Sharding, config.LeaseName = true, "Profiler" }, `leasename: "Profiler" is not a valid lease name`},
+		{"unused lease name", func(config *Config) { config.LeaseName = "Profiler" }, ""},
+		{"pod label", func(config *Config) { config.PodLabels = []string{"sps-api", "sps api"} }, `podlabels[1]: "sps api" is not a valid label value`},
+		{"duplicate job", func(config *Config) { config.Jobs = []string{"backup", "backup"} }, `jobs[1]: "backup" is listed more than once`},
+
+		// Anomaly detection is only checked once it is enabled
+		{"disabled anomaly", func(config *Config) { config.Alerts.Anomaly.Threshold = 0 }, ""},
+		{"anomaly threshold", func(config *Config) { config.Alerts.Anomaly.Enabled, config.Alerts.Anomaly.Threshold = true, 0 }, "alerts.anomaly.threshold: must be greater than 0"},
+		{"anomaly alpha", func(config *Config) { config.Alerts.Anomaly.Enabled, config.Alerts.Anomaly.Alpha = true, 1.5 }, "alerts.anomaly.alpha: must be greater than 0 and at most 1"},
+		{"anomaly warmup", func(config *Config) { config.Alerts.Anomaly.Enabled, config.Alerts.Anomaly.Warmup = true, 0 }, "alerts.anomaly.warmup: must be at least 1"},
+		{"anomaly change", func(config *Config) { config.Alerts.Anomaly.Enabled, config.Alerts.Anomaly.MinChange = true, -1 }, "alerts.anomaly.minchange: must not be negative"},
+
+		{"rule", func(config *Config) {
+			config.Alerts.Rules = []AlertRule{{Name: "cpu", Metric: "cpu", Of: "limit", Above: 90, For: "2m"}}
+		}, ""},
+		{"rule name", func(config *Config) {
+			config.Alerts.Rules = []AlertRule{{Metric: "cpu", Above: 90}}
+		}, "alerts.rules[0].name: must not be empty"},
+		{"duplicate rule", func(config *Config) {
+			config.Alerts.Rules = []AlertRule{{Name: "cpu", Metric: "cpu", Above: 90}, {Name: "cpu", Metric: "memory", Above: 90}}
+		}, `alerts.rules[1].name: "cpu" is listed more than once`},
+		{"rule metric", func(config *Config) {
+			config.Alerts.Rules = []AlertRule{{Name: "disk", Metric: "disk", Above: 90}}
+		}, `alerts.rules[0].metric: must be one of cpu, memory, got "disk"`},
+		{"rule relation", func(config *Config) {
+			config.Alerts.Rules = []AlertRule{{Name: "cpu", Metric: "cpu", Of: "usage", Above: 90}}
+		}, `alerts.rules[0].of: must be request, limit or empty, got "usage"`},
+		{"rule threshold", func(config *Config) {
+			config.Alerts.Rules = []AlertRule{{Name: "cpu", Metric: "cpu"}}
+		}, "alerts.rules[0].above: must be greater than 0"},
+		{"rule duration", func(config *Config) {
+			config.Alerts.Rules = []AlertRule{{Name: "cpu", Metric: "cpu", Above: 90, For: "2 minutes"}}
+		}, `alerts.rules[0].for: "2 minutes" is not a duration such as 2m`},
+
+		{"notifiers", func(config *Config) {
+			config.Alerts.Notifiers = []AlertNotifier{{Type: "webhook", URL: "https://example.com/alerts", Timeout: "5s"}, {Type: "command", Command: []string{"notify"}}}
+		}, ""},
+		{"webhook url", func(config *Config) {
+			config.Alerts.Notifiers = []AlertNotifier{{Type: "webhook", URL: "https://"}}
+		}, `alerts.notifiers[0].url: "https://" is not an http or https URL`},
+		{"command", func(config *Config) {
+			config.Alerts.Notifiers = []AlertNotifier{{Type: "command", Command: []string{""}}}
+		}, "alerts.notifiers[0].command: must not be empty"},
+		{"notifier type", func(config *Config) {
+			config.Alerts.Notifiers = []AlertNotifier{{Type: "email"}}
+		}, `alerts.notifiers[0].type: must be one of webhook, command, got "email"`},
+		{"notifier timeout", func(config *Config) {
+			config.Alerts.Notifiers = []AlertNotifier{{Type: "command", Command: []string{"notify"}, Timeout: "soon"}}
+		}, `alerts.notifiers[0].timeout: "soon" is not a duration such as 10s`},
+	}
+
+	for _, test := range tests {
+		config := validConfig()
+		test.change(config)
+
+		err := config.Validate()
+		if test.err == "" && err != nil {
+			t.Errorf("%s: expected no error, got %s", test.name, err.Error())
+		}
+		if test.err != "" && (err == nil || err.Error() != test.err) {
+			t.Errorf("%s: expected %s, got %v", test.name, test.err, err)
+		}
+	}
+}
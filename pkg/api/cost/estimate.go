@@ -0,0 +1,182 @@
+package cost
+
+import (
+	"pod_profiler/pkg/api/capture"
+	"pod_profiler/pkg/api/defaults/cloud"
+	"pod_profiler/pkg/api/results"
+	"time"
+)
+
+// The bytes in a GB as the cloud platforms bill memory
+const bytesPerGB float64 = 1024 * 1024 * 1024
+
+// Cost is the price of a container or workload over the period, split by resource
+type Cost struct {
+	Cpu    float64 `json:"cpu"`
+	Memory float64 `json:"memory"`
+	Total  float64 `json:"total"`
+}
+
+// Returns the cost of running the replicas with the given millicores and bytes for the hours, a resource that isn't set costs nothing
+func newCost(rates Rates, hours, replicas float64, millicores, bytes *int64) Cost {
+	cost := Cost{}
+	if millicores != nil {
+		cost.Cpu = float64(*millicores) / 1000 * rates.VCPUHour * hours * replicas
+	}
+	if bytes != nil {
+		cost.Memory = float64(*bytes) / bytesPerGB * rates.GBHour * hours * replicas
+	}
+	cost.Total = cost.Cpu + cost.Memory
+	return cost
+}
+
+func (cost Cost) add(other Cost) Cost {
+	return Cost{
+		Cpu:    cost.Cpu + other.Cpu,
+		Memory: cost.Memory + other.Memory,
+		Total:  cost.Total + other.Total,
+	}
+}
+
+// ContainerEstimate is the cost of a container's requests, of its mean usage and of its recommended requests.
+// The savings are what applying the recommendation saves, which is negative when the container needs more than it requests
+type ContainerEstimate struct {
+	Container   string                `json:"container"`
+	Role        capture.ContainerRole `json:"role"`
+	Replicas    float64               `json:"replicas"`
+	Requested   Cost                  `json:"requested"`
+	Used        Cost                  `json:"used"`
+	Recommended Cost                  `json:"recommended"`
+	Savings     float64               `json:"savings"`
+}
+
+// WorkloadEstimate is the cost of each container of a workload and their totals
+type WorkloadEstimate struct {
+	Target      string              `json:"target"`
+	Containers  []ContainerEstimate `json:"containers"`
+	Requested   Cost                `json:"requested"`
+	Used        Cost                `json:"used"`
+	Recommended Cost                `json:"recommended"`
+	Savings     float64             `json:"savings"`
+}
+
+// Report is the cost of every workload over the period at the rates of a platform
+type Report struct {
+	Currency    string                  `json:"currency"`
+	Platform    cloud.CloudPlatformType `json:"platform"`
+	CPUType     string                  `json:"cpuType,omitempty"`
+	Rates       Rates                   `json:"rates"`
+	PeriodHours float64                 `json:"periodHours"`
+	Workloads   []WorkloadEstimate      `json:"workloads"`
+	Requested   Cost                    `json:"requested"`
+	Used        Cost                    `json:"used"`
+	Recommended Cost                    `json:"recommended"`
+	Savings     float64                 `json:"savings"`
+}
+
+// Estimates what each recommended container costs over the period with its current requests, its mean usage and its
+// recommended requests. Each container is costed at the average number of pods it ran in at once while it was profiled
+func Estimate(read *results.Results, recommendations []results.Recommendation, pricing *Pricing, platform cloud.CloudPlatformType, cpuType string, period time.Duration) (*Report, error) {
+
+	rates, err := pricing.Rates(platform, cpuType)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		Currency:    pricing.Currency,
+		Platform:    platform,
+		CPUType:     cpuType,
+		Rates:       rates,
+		PeriodHours: period.Hours(),
+		Workloads:   []WorkloadEstimate{},
+	}
+
+	targets := map[string]*results.Target{}
+	for _, target := range read.Targets {
+		targets[target.Name] = target
+	}
+
+	workloads := map[string]*WorkloadEstimate{}
+	order := []string{}
+
+	for _, recommendation := range recommendations {
+
+		replicas := averageReplicas(targets[recommendation.Target], recommendation.Container)
+		meanCpu, meanMemory := recommendation.Cpu.Mean, recommendation.Memory.Mean
+
+		estimate := ContainerEstimate{
+			Container:   recommendation.Container,
+			Role:        recommendation.Role,
+			Replicas:    replicas,
+			Requested:   newCost(rates, report.PeriodHours, replicas, recommendation.Requests.Cpu, recommendation.Requests.Memory),
+			Used:        newCost(rates, report.PeriodHours, replicas, &meanCpu, &meanMemory),
+			Recommended: newCost(rates, report.PeriodHours, replicas, recommendation.RecommendedRequests.Cpu, recommendation.RecommendedRequests.Memory),
+		}
+		estimate.Savings = estimate.Requested.Total - estimate.Recommended.Total
+
+		workload, exists := workloads[recommendation.Target]
+		if !exists {
+			workload = &WorkloadEstimate{Target: recommendation.Target}
+			workloads[recommendation.Target] = workload
+			order = append(order, recommendation.Target)
+		}
+
+		workload.Containers = append(workload.Containers, estimate)
+		workload.Requested = workload.Requested.add(estimate.Requested)
+		workload.Used = workload.Used.add(estimate.Used)
+		workload.Recommended = workload.Recommended.add(estimate.Recommended)
+		workload.Savings += estimate.Savings
+	}
+
+	for _, name := range order {
+		workload := workloads[name]
+		report.Workloads = append(report.Workloads, *workload)
+		report.Requested = report.Requested.add(workload.Requested)
+		report.Used = report.Used.add(workload.Used)
+		report.Recommended = report.Recommended.add(workload.Recommended)
+		report.Savings += workload.Savings
+	}
+
+	return report, nil
+}
+
+// Returns the average number of pods the container ran in at once, which is the time each pod was sampled for over the
+// time the target was sampled for. When there is no span to divide by, each pod counts as one replica
+func averageReplicas(target *results.Target, containerName string) float64 {
+
+	var first, last, sampled int64
+	pods := 0
+
+	if target == nil {
+		return 0
+	}
+
+	for _, pod := range target.Pods {
+		for _, container := range pod.Containers {
+			if container.Name != containerName || len(container.Samples) == 0 {
+				continue
+			}
+
+			start, end := container.Samples[0].DateStamp, container.Samples[0].DateStamp
+			for _, sample := range container.Samples {
+				start = min(start, sample.DateStamp)
+				end = max(end, sample.DateStamp)
+			}
+
+			if pods == 0 || start < first {
+				first = start
+			}
+			if pods == 0 || end > last {
+				last = end
+			}
+			sampled += end - start
+			pods++
+		}
+	}
+
+	if last <= first || sampled == 0 {
+		return float64(pods)
+	}
+	return float64(sampled) / float64(last-first)
+}
@@ -0,0 +1,185 @@
+package cost
+
+import (
+	"math"
+	"pod_profiler/pkg/api/capture"
+	"pod_profiler/pkg/api/defaults/cloud"
+	"pod_profiler/pkg/api/results"
+	"testing"
+	"time"
+)
+
+const gib int64 = 1024 * 1024 * 1024
+
+func int64Pointer(value int64) *int64 {
+	return &value
+}
+
+// Returns true if the costs are equal to within rounding
+func costEqual(a, b Cost) bool {
+	return math.Abs(a.Cpu-b.Cpu) < 1e-9 && math.Abs(a.Memory-b.Memory) < 1e-9 && math.Abs(a.Total-b.Total) < 1e-9
+}
+
+// Returns a pod whose container was sampled from start to end
+func sampledPod(name, container string, start, end int64) *results.Pod {
+	return &results.Pod{
+		Name: name,
+		Containers: []*results.Container{{
+			Name:    container,
+			Samples: []results.Sample{{DateStamp: start}, {DateStamp: (start + end) / 2}, {DateStamp: end}},
+		}},
+	}
+}
+
+func TestNewCost(t *testing.T) {
+
+	rates := Rates{VCPUHour: 0.04, GBHour: 0.005}
+
+	tests := []struct {
+		name       string
+		hours      float64
+		replicas   float64
+		millicores *int64
+		bytes      *int64
+		expected   Cost
+	}{
+		{"one vcpu and GB", 1, 1, int64Pointer(1000), int64Pointer(gib), Cost{Cpu: 0.04, Memory: 0.005, Total: 0.045}},
+		{"hours and replicas", 10, 2, int64Pointer(500), int64Pointer(gib / 2), Cost{Cpu: 0.4, Memory: 0.05, Total: 0.45}},
+		{"fractional replicas", 1, 1.5, int64Pointer(1000), nil, Cost{Cpu: 0.06, Total: 0.06}},
+
+		// A resource that isn't requested costs nothing
+		{"no cpu", 1, 1, nil, int64Pointer(2 * gib), Cost{Memory: 0.01, Total: 0.01}},
+		{"nothing", 1, 1, nil, nil, Cost{}},
+	}
+
+	for _, test := range tests {
+		cost := newCost(rates, test.hours, test.replicas, test.millicores, test.bytes)
+		if !costEqual(cost, test.expected) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.expected, cost)
+		}
+	}
+}
+
+func TestAverageReplicas(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		target   *results.Target
+		expected float64
+	}{
+		{"no target", nil, 0},
+		{"no pods", &results.Target{Name: "api"}, 0},
+		{"one pod", &results.Target{Name: "api", Pods: []*results.Pod{sampledPod("api-0", "api", 0, 3600)}}, 1},
+		{"two pods", &results.Target{Name: "api", Pods: []*results.Pod{sampledPod("api-0", "api", 0, 3600), sampledPod("api-1", "api", 0, 3600)}}, 2},
+
+		// A pod that only ran for half of the time counts for half a replica
+		{"scaled up", &results.Target{Name: "api", Pods: []*results.Pod{sampledPod("api-0", "api", 0, 3600), sampledPod("api-1", "api", 1800, 3600)}}, 1.5},
+		{"replaced", &results.Target{Name: "api", Pods: []*results.Pod{sampledPod("api-0", "api", 0, 1800), sampledPod("api-1", "api", 1800, 3600)}}, 1},
+
+		{"other container", &results.Target{Name: "api", Pods: []*results.Pod{sampledPod("api-0", "api", 0, 3600), sampledPod("api-1", "proxy", 0, 3600)}}, 1},
+
+		// Without a span to divide by each pod counts as one replica
+		{"single samples", &results.Target{Name: "api", Pods: []*results.Pod{sampledPod("api-0", "api", 60, 60), sampledPod("api-1", "api", 60, 60)}}, 2},
+	}
+
+	for _, test := range tests {
+		if replicas := averageReplicas(test.target, "api"); math.Abs(replicas-test.expected) > 1e-9 {
+			t.Errorf("%s: expected %g, got %g", test.name, test.expected, replicas)
+		}
+	}
+}
+
+func TestEstimate(t *testing.T) {
+
+	pricing := &Pricing{
+		Currency: "USD",
+		Profiles: map[cloud.CloudPlatformType]Profile{
+			cloud.CloudPlatformType_AWS: {Rates: Rates{VCPUHour: 0.04, GBHour: 0.005}},
+		},
+	}
+
+	read := &results.Results{Targets: []*results.Target{
+		{Name: "api", Pods: []*results.Pod{
+			{Name: "api-0", Containers: []*results.Container{{Name: "api", Samples: []results.Sample{{DateStamp: 0}, {DateStamp: 3600}}}, {Name: "proxy", Samples: []results.Sample{{DateStamp: 0}, {DateStamp: 3600}}}}},
+			{Name: "api-1", Containers: []*results.Container{{Name: "api", Samples: []results.Sample{{DateStamp: 0}, {DateStamp: 3600}}}, {Name: "proxy", Samples: []results.Sample{{DateStamp: 0}, {DateStamp: 3600}}}}},
+		}},
+	}}
+
+	recommendations := []results.Recommendation{
+		{
+			// Requests more than it uses, so the recommendation saves money
+			ContainerSummary: results.ContainerSummary{
+				Target:    "api",
+				Container: "api",
+				Role:      capture.ContainerRole_Container,
+				Cpu:       results.Usage{Mean: 250},
+				Memory:    results.Usage{Mean: gib / 2},
+				Requests:  results.Resources{Cpu: int64Pointer(500), Memory: int64Pointer(gib)},
+			},
+			RecommendedRequests: results.Resources{Cpu: int64Pointer(300), Memory: int64Pointer(gib * 3 / 4)},
+		},
+		{
+			// Requests nothing, so the recommendation costs money
+			ContainerSummary: results.ContainerSummary{
+				Target:    "api",
+				Container: "proxy",
+				Role:      capture.ContainerRole_Sidecar,
+				Cpu:       results.Usage{Mean: 50},
+				Memory:    results.Usage{Mean: gib / 4},
+			},
+			RecommendedRequests: results.Resources{Cpu: int64Pointer(100), Memory: int64Pointer(gib / 2)},
+		},
+	}
+
+	report, err := Estimate(read, recommendations, pricing, cloud.CloudPlatformType_AWS, "", 10*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each container runs in two replicas for ten hours
+	expected := []struct {
+		requested, used, recommended Cost
+		savings                      float64
+	}{
+		{Cost{Cpu: 0.4, Memory: 0.1, Total: 0.5}, Cost{Cpu: 0.2, Memory: 0.05, Total: 0.25}, Cost{Cpu: 0.24, Memory: 0.075, Total: 0.315}, 0.185},
+		{Cost{}, Cost{Cpu: 0.04, Memory: 0.025, Total: 0.065}, Cost{Cpu: 0.08, Memory: 0.05, Total: 0.13}, -0.13},
+	}
+
+	if report.Currency != "USD" || report.PeriodHours != 10 || report.Rates != (Rates{VCPUHour: 0.04, GBHour: 0.005}) {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if len(report.Workloads) != 1 || len(report.Workloads[0].Containers) != len(expected) {
+		t.Fatalf("expected one workload with %d containers, got %+v", len(expected), report.Workloads)
+	}
+
+	for i, container := range report.Workloads[0].Containers {
+		if container.Replicas != 2 {
+			t.Errorf("%s: expected 2 replicas, got %g", container.Container, container.Replicas)
+		}
+		if !costEqual(container.Requested, expected[i].requested) {
+			t.Errorf("%s: expected a requested cost of %+v, got %+v", container.Container, expected[i].requested, container.Requested)
+		}
+		if !costEqual(container.Used, expected[i].used) {
+			t.Errorf("%s: expected a used cost of %+v, got %+v", container.Container, expected[i].used, container.Used)
+		}
+		if !costEqual(container.Recommended, expected[i].recommended) {
+			t.Errorf("%s: expected a recommended cost of %+v, got %+v", container.Container, expected[i].recommended, container.Recommended)
+		}
+		if math.Abs(container.Savings-expected[i].savings) > 1e-9 {
+			t.Errorf("%s: expected savings of %g, got %g", container.Container, expected[i].savings, container.Savings)
+		}
+	}
+
+	// The workload and the report total their containers
+	total := Cost{Cpu: 0.4, Memory: 0.1, Total: 0.5}
+	if !costEqual(report.Workloads[0].Requested, total) || !costEqual(report.Requested, total) {
+		t.Errorf("expected a requested total of %+v, got %+v and %+v", total, report.Workloads[0].Requested, report.Requested)
+	}
+	if math.Abs(report.Savings-0.055) > 1e-9 || math.Abs(report.Workloads[0].Savings-0.055) > 1e-9 {
+		t.Errorf("expected savings of 0.055, got %g and %g", report.Workloads[0].Savings, report.Savings)
+	}
+
+	if _, err := Estimate(read, recommendations, pricing, cloud.CloudPlatformType_GCP, "", time.Hour); err == nil {
+		t.Errorf("expected an error for a platform without a profile")
+	}
+}
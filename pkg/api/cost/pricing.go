@@ -0,0 +1,144 @@
+package cost
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"pod_profiler/pkg/api/config"
+	"pod_profiler/pkg/api/defaults/cloud"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Rates are the prices of a vCPU and a GB of memory for an hour. A GB is 1024^3 bytes, which is how the cloud platforms bill memory
+type Rates struct {
+	VCPUHour float64 `json:"vcpuHour"`
+	GBHour   float64 `json:"gbHour"`
+}
+
+// Profile is the pricing of a platform. CPU types that are billed differently, such as those of CoreWeave, override the rates
+type Profile struct {
+	Rates
+	CPUTypes map[string]Rates `json:"cpuTypes,omitempty"`
+}
+
+// Pricing holds the pricing profile of each platform, keyed by platform
+type Pricing struct {
+	Currency string                              `json:"currency"`
+	Profiles map[cloud.CloudPlatformType]Profile `json:"profiles"`
+}
+
+// Loads the pricing profiles from a JSON or YAML file, for example:
+//
+//	currency: USD
+//	profiles:
+//	  aws:
+//	    vcpuHour: 0.0404
+//	    gbHour: 0.0044
+//	  coreweave:
+//	    vcpuHour: 0.01
+//	    gbHour: 0.005
+//	    cpuTypes:
+//	      amd-epyc-milan:
+//	        vcpuHour: 0.035
+func LoadPricing(filename string) (*Pricing, error) {
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	// YAML is a superset of JSON, but converting keeps the errors of JSON files in terms of JSON
+	if config.TypeFromFilename(filename) != "json" {
+		data, err = yaml.YAMLToJSON(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pricing file %s: %s", filename, err.Error())
+		}
+	}
+
+	pricing := &Pricing{}
+	if err := json.Unmarshal(data, pricing); err != nil {
+		return nil, fmt.Errorf("invalid pricing file %s: %s", filename, err.Error())
+	}
+
+	if err := pricing.validate(); err != nil {
+		return nil, fmt.Errorf("invalid pricing file %s: %s", filename, err.Error())
+	}
+
+	return pricing, nil
+}
+
+// Checks the profiles are for known platforms and CPU types and that their prices aren't negative
+func (pricing *Pricing) validate() error {
+
+	if len(pricing.Profiles) == 0 {
+		return fmt.Errorf("profiles: must not be empty")
+	}
+
+	for platform, profile := range pricing.Profiles {
+		if !knownPlatform(platform) {
+			return fmt.Errorf("profiles.%s: unknown platform, must be one of %v", platform, cloud.PLATFORMS)
+		}
+		if profile.VCPUHour < 0 || profile.GBHour < 0 {
+			return fmt.Errorf("profiles.%s: prices must not be negative", platform)
+		}
+
+		for cpuType, rates := range profile.CPUTypes {
+			if platform == cloud.CloudPlatformType_CoreWeave && !contains(cloud.COREWEAVE_CPU_TYPES, cpuType) {
+				return fmt.Errorf("profiles.%s.cpuTypes.%s: unknown CPU type, must be one of %v", platform, cpuType, cloud.COREWEAVE_CPU_TYPES)
+			}
+			if rates.VCPUHour < 0 || rates.GBHour < 0 {
+				return fmt.Errorf("profiles.%s.cpuTypes.%s: prices must not be negative", platform, cpuType)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns the rates of the platform, and of the CPU type when one is given. A CPU type only overrides the prices it sets
+func (pricing *Pricing) Rates(platform cloud.CloudPlatformType, cpuType string) (Rates, error) {
+
+	profile, exists := pricing.Profiles[platform]
+	if !exists {
+		return Rates{}, fmt.Errorf("there is no pricing profile for the %s platform", platform)
+	}
+
+	rates := profile.Rates
+	if cpuType == "" {
+		return rates, nil
+	}
+
+	cpuRates, exists := profile.CPUTypes[cpuType]
+	if !exists {
+		return Rates{}, fmt.Errorf("the %s pricing profile has no prices for the %s CPU type", platform, cpuType)
+	}
+	if cpuRates.VCPUHour != 0 {
+		rates.VCPUHour = cpuRates.VCPUHour
+	}
+	if cpuRates.GBHour != 0 {
+		rates.GBHour = cpuRates.GBHour
+	}
+
+	return rates, nil
+}
+
+// Returns true if the platform is one the cloud package knows
+func knownPlatform(platform cloud.CloudPlatformType) bool {
+	for _, known := range cloud.PLATFORMS {
+		if known == platform {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if the value is one of the options
+func contains(options []string, value string) bool {
+	for _, option := range options {
+		if option == value {
+			return true
+		}
+	}
+	return false
+}
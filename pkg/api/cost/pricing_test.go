@@ -0,0 +1,113 @@
+package cost
+
+import (
+	"os"
+	"path"
+	"pod_profiler/pkg/api/defaults/cloud"
+	"strings"
+	"testing"
+)
+
+// Writes the pricing file to a temporary directory and returns its path
+func writePricing(t *testing.T, filename, contents string) string {
+	filename = path.Join(t.TempDir(), filename)
+	if err := os.WriteFile(filename, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+const testPricing = `
+currency: USD
+profiles:
+  aws:
+    vcpuHour: 0.0404
+    gbHour: 0.0044
+  gcp:
+    vcpuHour: 0.0332
+    gbHour: 0.0045
+  coreweave:
+    vcpuHour: 0.01
+    gbHour: 0.005
+    cpuTypes:
+      amd-epyc-milan:
+        vcpuHour: 0.035
+      intel-xeon-v3:
+        gbHour: 0.004
+`
+
+func TestLoadPricing(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		filename string
+		contents string
+		err      string
+	}{
+		{"yaml", "pricing.yaml", testPricing, ""},
+		{"json", "pricing.json", `{"currency": "EUR", "profiles": {"azure": {"vcpuHour": 0.04, "gbHour": 0.005}}}`, ""},
+		{"empty", "pricing.yaml", "currency: USD\n", "profiles: must not be empty"},
+		{"unknown platform", "pricing.yaml", "profiles:\n  openstack:\n    vcpuHour: 0.04\n", "profiles.openstack: unknown platform, must be one of [aws coreweave azure gcp local]"},
+		{"negative price", "pricing.yaml", "profiles:\n  aws:\n    gbHour: -0.01\n", "profiles.aws: prices must not be negative"},
+		{"unknown cpu type", "pricing.yaml", "profiles:\n  coreweave:\n    cpuTypes:\n      arm:\n        vcpuHour: 0.02\n", "profiles.coreweave.cpuTypes.arm: unknown CPU type"},
+		{"negative cpu type price", "pricing.yaml", "profiles:\n  coreweave:\n    cpuTypes:\n      amd-epyc-rome:\n        vcpuHour: -1\n", "profiles.coreweave.cpuTypes.amd-epyc-rome: prices must not be negative"},
+		{"invalid json", "pricing.json", `{"profiles": {"aws": {"vcpuHour": "cheap"}}}`, "invalid pricing file"},
+		{"invalid yaml", "pricing.yaml", "profiles: [aws\n", "invalid pricing file"},
+	}
+
+	for _, test := range tests {
+		_, err := LoadPricing(writePricing(t, test.filename, test.contents))
+
+		if test.err == "" && err != nil {
+			t.Errorf("%s: expected no error, got %s", test.name, err.Error())
+		}
+		if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%s: expected %s, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestRates(t *testing.T) {
+
+	pricing, err := LoadPricing(writePricing(t, "pricing.yaml", testPricing))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		platform cloud.CloudPlatformType
+		cpuType  string
+		expected Rates
+		err      string
+	}{
+		{"aws", cloud.CloudPlatformType_AWS, "", Rates{VCPUHour: 0.0404, GBHour: 0.0044}, ""},
+		{"gcp", cloud.CloudPlatformType_GCP, "", Rates{VCPUHour: 0.0332, GBHour: 0.0045}, ""},
+		{"coreweave", cloud.CloudPlatformType_CoreWeave, "", Rates{VCPUHour: 0.01, GBHour: 0.005}, ""},
+
+		// A CPU type only overrides the prices it sets
+		{"cpu price", cloud.CloudPlatformType_CoreWeave, "amd-epyc-milan", Rates{VCPUHour: 0.035, GBHour: 0.005}, ""},
+		{"memory price", cloud.CloudPlatformType_CoreWeave, "intel-xeon-v3", Rates{VCPUHour: 0.01, GBHour: 0.004}, ""},
+
+		{"no cpu type prices", cloud.CloudPlatformType_CoreWeave, "amd-epyc-rome", Rates{}, "the coreweave pricing profile has no prices for the amd-epyc-rome CPU type"},
+		{"no profile", cloud.CloudPlatformType_Azure, "", Rates{}, "there is no pricing profile for the azure platform"},
+	}
+
+	for _, test := range tests {
+		rates, err := pricing.Rates(test.platform, test.cpuType)
+
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: expected %s, got %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: expected no error, got %s", test.name, err.Error())
+			continue
+		}
+		if rates != test.expected {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.expected, rates)
+		}
+	}
+}
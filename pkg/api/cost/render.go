@@ -0,0 +1,107 @@
+package cost
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Writes the cost of each workload and the totals as a table aligned for the terminal
+func WriteTable(writer io.Writer, report *Report) error {
+
+	fmt.Fprintf(writer, "%s over %s\n\n", report.describeRates(), describePeriod(report.PeriodHours))
+
+	table := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "WORKLOAD\tREQUESTED\tUSED\tIDLE\tRECOMMENDED\tSAVINGS")
+
+	for _, workload := range report.Workloads {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			workload.Target,
+			report.format(workload.Requested.Total),
+			report.format(workload.Used.Total),
+			report.format(workload.Requested.Total-workload.Used.Total),
+			report.format(workload.Recommended.Total),
+			report.format(workload.Savings),
+		)
+	}
+
+	fmt.Fprintf(table, "TOTAL\t%s\t%s\t%s\t%s\t%s\n",
+		report.format(report.Requested.Total),
+		report.format(report.Used.Total),
+		report.format(report.Requested.Total-report.Used.Total),
+		report.format(report.Recommended.Total),
+		report.format(report.Savings),
+	)
+
+	return table.Flush()
+}
+
+// Writes the cost of each workload and the totals as a Markdown table
+func WriteMarkdown(writer io.Writer, report *Report) error {
+
+	rows := []string{
+		fmt.Sprintf("%s over %s", report.describeRates(), describePeriod(report.PeriodHours)),
+		"",
+		"| Workload | Requested | Used | Idle | Recommended | Savings |",
+		"| --- | ---: | ---: | ---: | ---: | ---: |",
+	}
+
+	for _, workload := range report.Workloads {
+		rows = append(rows, fmt.Sprintf("| %s | %s | %s | %s | %s | %s |",
+			strings.NewReplacer("|", "\\|", "*", "\\*", "_", "\\_").Replace(workload.Target),
+			report.format(workload.Requested.Total),
+			report.format(workload.Used.Total),
+			report.format(workload.Requested.Total-workload.Used.Total),
+			report.format(workload.Recommended.Total),
+			report.format(workload.Savings),
+		))
+	}
+
+	rows = append(rows, fmt.Sprintf("| **Total** | **%s** | **%s** | **%s** | **%s** | **%s** |",
+		report.format(report.Requested.Total),
+		report.format(report.Used.Total),
+		report.format(report.Requested.Total-report.Used.Total),
+		report.format(report.Recommended.Total),
+		report.format(report.Savings),
+	))
+
+	_, err := io.WriteString(writer, strings.Join(rows, "\n")+"\n")
+	return err
+}
+
+// Writes the report, including the cost of each container, as JSON
+func WriteJSON(writer io.Writer, report *Report) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// Formats an amount in the report's currency
+func (report *Report) format(amount float64) string {
+	if report.Currency == "" {
+		return fmt.Sprintf("%.2f", amount)
+	}
+	return fmt.Sprintf("%.2f %s", amount, report.Currency)
+}
+
+// Describes the platform and rates the costs are estimated at
+func (report *Report) describeRates() string {
+	platform := string(report.Platform)
+	if report.CPUType != "" {
+		platform += " " + report.CPUType
+	}
+	return fmt.Sprintf("Estimated at %s prices of %s per vCPU-hour and %s per GB-hour", platform,
+		strings.TrimSpace(fmt.Sprintf("%g %s", report.Rates.VCPUHour, report.Currency)),
+		strings.TrimSpace(fmt.Sprintf("%g %s", report.Rates.GBHour, report.Currency)),
+	)
+}
+
+// Describes the period the costs are for, in days when it is a whole number of them
+func describePeriod(hours float64) string {
+	if hours >= 24 && hours == float64(int64(hours/24))*24 {
+		return fmt.Sprintf("%d days", int64(hours/24))
+	}
+	return fmt.Sprintf("%g hours", hours)
+}
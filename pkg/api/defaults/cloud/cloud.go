@@ -1,5 +1,10 @@
 package cloud
 
+import (
+	"fmt"
+	"strings"
+)
+
 type CloudPlatformType string
 
 const (
@@ -9,3 +14,27 @@ const (
 	CloudPlatformType_GCP       CloudPlatformType = "gcp"
 	CloudPlatformType_Local     CloudPlatformType = "local"
 )
+
+// Every platform the profiler can detect
+var PLATFORMS = []CloudPlatformType{
+	CloudPlatformType_AWS,
+	CloudPlatformType_CoreWeave,
+	CloudPlatformType_Azure,
+	CloudPlatformType_GCP,
+	CloudPlatformType_Local,
+}
+
+// The regions, CPU types and GPU types of CoreWeave nodes
+var COREWEAVE_REGIONS = []string{"ORD1", "LGA1", "LAS1"}
+var COREWEAVE_CPU_TYPES = []string{"intel-xeon-v3", "intel-xeon-v4", "intel-xeon-scalable", "amd-epyc-rome", "amd-epyc-milan"}
+var COREWEAVE_GPU_TYPES = []string{"A40", "RTX_A6000", "RTX_A5000", "RTX_A4000", "Quadro_RTX_5000", "Quadro_RTX_4000"}
+
+// Returns the platform with the given name
+func ParsePlatform(name string) (CloudPlatformType, error) {
+	for _, platform := range PLATFORMS {
+		if string(platform) == strings.ToLower(name) {
+			return platform, nil
+		}
+	}
+	return "", fmt.Errorf("unknown platform %q, must be one of %v", name, PLATFORMS)
+}
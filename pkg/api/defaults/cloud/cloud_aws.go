@@ -1,6 +0,0 @@
-//go:build aws
-// +build aws
-
-package cloud
-
-const PLATFORM CloudPlatformType = CloudPlatformType_AWS
@@ -1,6 +0,0 @@
-//go:build azure
-// +build azure
-
-package cloud
-
-const PLATFORM CloudPlatformType = CloudPlatformType_Azure
@@ -1,6 +0,0 @@
-//go:build gcp
-// +build gcp
-
-package cloud
-
-const PLATFORM CloudPlatformType = CloudPlatformType_GCP
@@ -0,0 +1,66 @@
+package cloud
+
+import "strings"
+
+// Node is what the platform is detected from on each node
+type Node struct {
+	ProviderID string
+	Labels     map[string]string
+}
+
+// The prefixes of the provider IDs the cloud controller managers give their nodes
+var providerIDPrefixes = map[string]CloudPlatformType{
+	"aws://":   CloudPlatformType_AWS,
+	"azure://": CloudPlatformType_Azure,
+	"gce://":   CloudPlatformType_GCP,
+}
+
+// The prefixes of the node labels each platform's managed node pools add
+var labelPrefixes = map[string]CloudPlatformType{
+	"node.coreweave.cloud/": CloudPlatformType_CoreWeave,
+	"eks.amazonaws.com/":    CloudPlatformType_AWS,
+	"kubernetes.azure.com/": CloudPlatformType_Azure,
+	"cloud.google.com/gke-": CloudPlatformType_GCP,
+}
+
+// The markers the managed platforms add to the server's git version, such as v1.27.4-eks-2d98532 or v1.27.3-gke.100
+var versionMarkers = map[string]CloudPlatformType{
+	"-eks-": CloudPlatformType_AWS,
+	"-gke.": CloudPlatformType_GCP,
+}
+
+// Returns the platform the cluster is running on from its nodes' provider IDs and labels, falling back to the server
+// version when the nodes can't be read. Clusters that don't match a cloud platform, such as kind or minikube, are local
+func DetectPlatform(nodes []Node, serverVersion string) CloudPlatformType {
+
+	for _, node := range nodes {
+		for prefix, platform := range providerIDPrefixes {
+			if strings.HasPrefix(node.ProviderID, prefix) {
+				return platform
+			}
+		}
+
+		for label := range node.Labels {
+			for prefix, platform := range labelPrefixes {
+				if strings.HasPrefix(label, prefix) {
+					return platform
+				}
+			}
+		}
+
+		// CoreWeave nodes don't have a provider ID, but their regions are their own
+		for _, region := range COREWEAVE_REGIONS {
+			if node.Labels["topology.kubernetes.io/region"] == region {
+				return CloudPlatformType_CoreWeave
+			}
+		}
+	}
+
+	for marker, platform := range versionMarkers {
+		if strings.Contains(serverVersion, marker) {
+			return platform
+		}
+	}
+
+	return CloudPlatformType_Local
+}
@@ -1,8 +1,52 @@
 package defaults
 
+import "time"
+
 const (
 	NAMESPACE             string = "default"
 	KUBERNETES_NAME_LABEL string = "app.kubernetes.io/name"
 	HTTP_PORT             int    = 8000
 	RESULTS_PATH          string = "./results"
+	COLLECTOR             string = "metrics-server"
+
+	POLL_INTERVAL     time.Duration = 10 * time.Second
+	JOB_POLL_INTERVAL time.Duration = 2 * time.Second
+
+	// Raw samples older than the retention are removed once rolled up, the prune interval is how often that is checked.
+	// They are kept forever by default, since the reports and summaries are only worked out from the raw samples
+	RAW_RETENTION  string        = "0"
+	PRUNE_INTERVAL time.Duration = 10 * time.Minute
+
+	// The most points per container the usage API returns before it switches to a coarser rollup tier
+	API_MAX_POINTS int = 1000
+
+	// A sample spikes when it is this many standard deviations above the moving average of its container, once the
+	// average has seen the warmup samples and the rise is at least the minimum change
+	ALERT_ANOMALY_THRESHOLD  float64       = 4
+	ALERT_ANOMALY_ALPHA      float64       = 0.1
+	ALERT_ANOMALY_WARMUP     int           = 30
+	ALERT_ANOMALY_MIN_CHANGE float64       = 0.2
+	ALERT_NOTIFY_TIMEOUT     time.Duration = 10 * time.Second
+
+	// How many of the most recent alerts the API returns, and how long the alert state of a container that is no longer
+	// captured is kept
+	ALERT_HISTORY      int           = 100
+	ALERT_STATE_EXPIRY time.Duration = time.Hour
+
+	// The lease standby gatherers campaign for, a standby takes over within the lease duration if the leader stops
+	// renewing it and within the retry period if the leader releases it when it shuts down
+	LEASE_NAME           string        = "pod-profiler-gatherer"
+	LEASE_DURATION       time.Duration = 15 * time.Second
+	LEASE_RENEW_DEADLINE time.Duration = 10 * time.Second
+	LEASE_RETRY_PERIOD   time.Duration = 2 * time.Second
+
+	// Sharded gatherers write to their own directory under the results path and label their membership leases with the
+	// lease name. Each gatherer is placed on the hash ring this many times so the pods are spread evenly
+	SHARDS_DIRECTORY    string = "shards"
+	SHARD_GROUP_LABEL   string = "pod-profiler.io/shard-group"
+	SHARD_VIRTUAL_NODES int    = 256
+
+	// Each pod is polled separately, so allow more requests than the client-go defaults of 5 and 10
+	KUBERNETES_QPS   float32 = 20
+	KUBERNETES_BURST int     = 40
 )
@@ -21,6 +21,11 @@ func (cache *Cache) Deployment() v1apps.DeploymentNamespaceLister {
 	return cache.Listers.Deployment.Deployments(cache.Namespace)
 }
 
+// Convenience function to return the lister for replica sets on the cached namespace
+func (cache *Cache) ReplicaSet() v1apps.ReplicaSetNamespaceLister {
+	return cache.Listers.ReplicaSet.ReplicaSets(cache.Namespace)
+}
+
 // Convenience function to return the lister for config maps on the cached namespace
 func (cache *Cache) ConfigMap() v1core.ConfigMapNamespaceLister {
 	return cache.Listers.ConfigMap.ConfigMaps(cache.Namespace)
@@ -60,3 +65,8 @@ func (cache *Cache) Secret() v1core.SecretNamespaceLister {
 func (cache *Cache) PersistentVolumeClaim() v1core.PersistentVolumeClaimNamespaceLister {
 	return cache.Listers.PersistentVolumeClaim.PersistentVolumeClaims(cache.Namespace)
 }
+
+// Convenience function to return the lister for events on the cached namespace
+func (cache *Cache) Event() v1core.EventNamespaceLister {
+	return cache.Listers.Event.Events(cache.Namespace)
+}
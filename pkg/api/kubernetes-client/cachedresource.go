@@ -11,12 +11,14 @@ var CachedResource_ConfigMap CachedResource = "ConfigMap"
 var CachedResource_Ingress CachedResource = "Ingress"
 var CachedResource_Deployment CachedResource = "Deployment"
 var CachedResource_StatefulSet CachedResource = "StatefulSet"
+var CachedResource_ReplicaSet CachedResource = "ReplicaSet"
 var CachedResource_DaemonSet CachedResource = "DaemonSet"
 var CachedResource_Job CachedResource = "Job"
 var CachedResource_Service CachedResource = "Service"
 var CachedResource_Secret CachedResource = "Secret"
 var CachedResource_HPA CachedResource = "HorizontalPodAutoscaler"
 var CachedResource_PVC CachedResource = "PersistentVolumeClaim"
+var CachedResource_Event CachedResource = "Event"
 
 // returns true if the given cached resource is in an array of cached resources or false otherwise
 func (cr CachedResource) In(cachedResources []CachedResource) bool {
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"pod_profiler/pkg/api/defaults"
 	"pod_profiler/pkg/api/defaults/cloud"
+	"pod_profiler/pkg/api/version"
 	"reflect"
 	"time"
 
@@ -30,8 +32,23 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/scheme"
 )
 
-// The path to look for the config if we're not on cluster
-var ConfigPath string = "/home/dan/.kube/config"
+// The path to look for the config if we're not on cluster. When this is blank the standard loading rules are used,
+// which merge the files listed in $KUBECONFIG or fall back to ~/.kube/config
+var ConfigPath string
+
+// The context to use from the config if we're not on cluster, the config's current context is used if this is blank
+var Context string
+
+// The rate limits of the requests made to the API server
+var QPS float32 = defaults.KUBERNETES_QPS
+var Burst int = defaults.KUBERNETES_BURST
+
+// The timeout of each request to the API server. This also applies to the watches of the informers, so it should
+// be left at zero (no timeout) unless the informers are not used
+var Timeout time.Duration
+
+// The user agent sent with each request, the client-go default is used if this is blank
+var UserAgent string = "pod-profiler/" + version.Version
 
 // flag to say whether or not we wish to override using on-cluster config
 var UseInClusterConfig bool = true
@@ -55,6 +72,12 @@ type Client struct {
 
 	// The kubernetes metrics
 	Metrics *Metrics
+
+	// The kubelet APIs accessed through the node proxy
+	Kubelet *Kubelet
+
+	// The platform detected by Platform
+	platform cloud.CloudPlatformType
 }
 
 // Create a new client wrapper around client.Client
@@ -75,7 +98,7 @@ func NewClient(...informers.SharedInformerOption) (*Client, error) {
 
 	// If not inside a k8s cluster, or we've explicitliy set to not use cluster config, look for our k8s config externally
 	if !inK8s || !UseInClusterConfig {
-		config, err = clientcmd.BuildConfigFromFlags("", ConfigPath)
+		config, err = clientConfig().ClientConfig()
 		if err != nil {
 			return nil, err
 		}
@@ -87,6 +110,13 @@ func NewClient(...informers.SharedInformerOption) (*Client, error) {
 		}
 	}
 
+	config.QPS = QPS
+	config.Burst = Burst
+	config.Timeout = Timeout
+	if UserAgent != "" {
+		config.UserAgent = UserAgent
+	}
+
 	client, err := client.New(config, client.Options{Scheme: s})
 	if err != nil {
 		return nil, err
@@ -107,6 +137,21 @@ func NewClient(...informers.SharedInformerOption) (*Client, error) {
 	return spsclient, nil
 }
 
+// Returns the config loaded with the standard loading rules and the ConfigPath and Context overrides
+func clientConfig() clientcmd.ClientConfig {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = ConfigPath
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: Context}
+
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides)
+}
+
+// Returns the namespace of the kubeconfig context, which is the default namespace when we're not on cluster
+func Namespace() (string, error) {
+	namespace, _, err := clientConfig().Namespace()
+	return namespace, err
+}
+
 // Creates and syncs a new cache for the namespace provideded. It can take optional CachedResources that you can choose
 // only build and sync for this instance of the client. If you choose only specific cached resources, it's important that you do not attempt to access
 // any informers or listers for resources that have not been built and synced otherwise it will panic
@@ -170,6 +215,12 @@ func (c *Client) BuildAndSyncNamedspacedCache(namespace string, cachedResources
 			statefulsetInformer := c.Cache.Informers.StatefulSet.Informer()
 			toSync = append(toSync, statefulsetInformer.HasSynced)
 
+		case CachedResource_ReplicaSet:
+			c.Cache.Informers.ReplicaSet = c.SharedInformerFactory.Apps().V1().ReplicaSets()
+			c.Cache.Listers.ReplicaSet = c.Cache.Informers.ReplicaSet.Lister()
+			replicaSetInformer := c.Cache.Informers.ReplicaSet.Informer()
+			toSync = append(toSync, replicaSetInformer.HasSynced)
+
 		case CachedResource_DaemonSet:
 			c.Cache.Informers.DaemonSet = c.SharedInformerFactory.Apps().V1().DaemonSets()
 			c.Cache.Listers.DaemonSet = c.Cache.Informers.DaemonSet.Lister()
@@ -196,18 +247,23 @@ func (c *Client) BuildAndSyncNamedspacedCache(namespace string, cachedResources
 
 		case CachedResource_HPA:
 
-			// if we're deploying on CoreWeave, we need to use v2beta2 of the HPA resource
-			// as they are using an old version of kubernetes (1.20 at the time of writing this)
-			if cloud.PLATFORM == cloud.CloudPlatformType_CoreWeave {
-				c.Cache.Informers.HorizontalPodAutoscalerV2beta2 = c.SharedInformerFactory.Autoscaling().V2beta2().HorizontalPodAutoscalers()
-				c.Cache.Listers.HorizontalPodAutoscalerV2beta2 = c.Cache.Informers.HorizontalPodAutoscalerV2beta2.Lister()
-				hpaInformer := c.Cache.Informers.HorizontalPodAutoscalerV2beta2.Informer()
-				toSync = append(toSync, hpaInformer.HasSynced)
-			} else {
+			// Older clusters, such as those CoreWeave ran on kubernetes 1.20, only serve v2beta2 of the HPA resource
+			hpaVersion, err := c.HPAVersion()
+			if err != nil {
+				return err
+			}
+
+			switch hpaVersion {
+			case HPAVersion_V2:
 				c.Cache.Informers.HorizontalPodAutoscalerV2 = c.SharedInformerFactory.Autoscaling().V2().HorizontalPodAutoscalers()
 				c.Cache.Listers.HorizontalPodAutoscalerV2 = c.Cache.Informers.HorizontalPodAutoscalerV2.Lister()
 				hpaInformer := c.Cache.Informers.HorizontalPodAutoscalerV2.Informer()
 				toSync = append(toSync, hpaInformer.HasSynced)
+			case HPAVersion_V2beta2:
+				c.Cache.Informers.HorizontalPodAutoscalerV2beta2 = c.SharedInformerFactory.Autoscaling().V2beta2().HorizontalPodAutoscalers()
+				c.Cache.Listers.HorizontalPodAutoscalerV2beta2 = c.Cache.Informers.HorizontalPodAutoscalerV2beta2.Lister()
+				hpaInformer := c.Cache.Informers.HorizontalPodAutoscalerV2beta2.Informer()
+				toSync = append(toSync, hpaInformer.HasSynced)
 			}
 
 		case CachedResource_PVC:
@@ -215,6 +271,12 @@ func (c *Client) BuildAndSyncNamedspacedCache(namespace string, cachedResources
 			c.Cache.Listers.PersistentVolumeClaim = c.Cache.Informers.PersistentVolumeClaim.Lister()
 			pvcInformer := c.Cache.Informers.PersistentVolumeClaim.Informer()
 			toSync = append(toSync, pvcInformer.HasSynced)
+
+		case CachedResource_Event:
+			c.Cache.Informers.Event = c.SharedInformerFactory.Core().V1().Events()
+			c.Cache.Listers.Event = c.Cache.Informers.Event.Lister()
+			eventInformer := c.Cache.Informers.Event.Informer()
+			toSync = append(toSync, eventInformer.HasSynced)
 		}
 	}
 
@@ -347,6 +409,11 @@ func (c *Client) initialiseMetrics() error {
 		namespace: c.Cache.Namespace,
 	}
 
+	// Store the kubelet accessor as part of the client
+	c.Kubelet = &Kubelet{
+		clientset: c.Clientset,
+	}
+
 	// Return nil to indicate a success
 	return nil
 }
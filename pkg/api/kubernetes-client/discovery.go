@@ -0,0 +1,80 @@
+package kubernetesclient
+
+import (
+	"context"
+	"fmt"
+	"pod_profiler/pkg/api/defaults/cloud"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The versions of the autoscaling API that HPAs can be read with
+const (
+	HPAVersion_V2      string = "v2"
+	HPAVersion_V2beta2 string = "v2beta2"
+)
+
+// The platform the cluster runs on. When blank it is detected from the cluster's nodes and server version
+var Platform cloud.CloudPlatformType
+
+// The version of the autoscaling API HPAs are read with. When blank the newest version the API server serves is used
+var HPAVersion string
+
+// The number of nodes the platform is detected from, a managed cluster labels every node so a few are enough
+const platformNodes int64 = 10
+
+// Returns the platform the cluster runs on, detecting it the first time unless Platform was set. Detection never fails,
+// nodes that can't be listed are skipped and a cluster that doesn't match a cloud platform is local
+func (c *Client) Platform() cloud.CloudPlatformType {
+
+	if Platform != "" {
+		return Platform
+	}
+	if c.platform != "" {
+		return c.platform
+	}
+
+	nodes := []cloud.Node{}
+	list, err := c.Clientset.CoreV1().Nodes().List(context.Background(), v1meta.ListOptions{Limit: platformNodes})
+	if err == nil {
+		for _, node := range list.Items {
+			nodes = append(nodes, cloud.Node{ProviderID: node.Spec.ProviderID, Labels: node.GetLabels()})
+		}
+	}
+
+	serverVersion := ""
+	if info, err := c.Clientset.Discovery().ServerVersion(); err == nil {
+		serverVersion = info.GitVersion
+	}
+
+	c.platform = cloud.DetectPlatform(nodes, serverVersion)
+	return c.platform
+}
+
+// Returns the newest version of the autoscaling API the server serves HPAs with, unless HPAVersion was set.
+// An empty version is returned if the server serves neither
+func (c *Client) HPAVersion() (string, error) {
+
+	if HPAVersion != "" {
+		return HPAVersion, nil
+	}
+
+	for _, version := range []string{HPAVersion_V2, HPAVersion_V2beta2} {
+		resources, err := c.Clientset.Discovery().ServerResourcesForGroupVersion("autoscaling/" + version)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("unable to discover the autoscaling API versions: %s", err.Error())
+		}
+
+		for _, resource := range resources.APIResources {
+			if resource.Name == "horizontalpodautoscalers" {
+				return version, nil
+			}
+		}
+	}
+
+	return "", nil
+}
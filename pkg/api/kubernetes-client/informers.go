@@ -17,6 +17,7 @@ type Informers struct {
 	Ingress                        v1.IngressInformer
 	Deployment                     informersappsv1.DeploymentInformer
 	StatefulSet                    informersappsv1.StatefulSetInformer
+	ReplicaSet                     informersappsv1.ReplicaSetInformer
 	DaemonSet                      informersappsv1.DaemonSetInformer
 	Job                            informersbatchv1.JobInformer
 	Service                        informersv1.ServiceInformer
@@ -24,4 +25,5 @@ type Informers struct {
 	HorizontalPodAutoscalerV2beta2 informersautoscalingv2beta2.HorizontalPodAutoscalerInformer
 	HorizontalPodAutoscalerV2      informersautoscalingv2.HorizontalPodAutoscalerInformer
 	PersistentVolumeClaim          informersv1.PersistentVolumeClaimInformer
+	Event                          informersv1.EventInformer
 }
@@ -0,0 +1,149 @@
+package kubernetesclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"pod_profiler/pkg/api/prometheus"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// Kubelet provides access to the kubelet APIs of each node through the API server node proxy
+type Kubelet struct {
+	clientset kubernetes.Interface
+}
+
+// Summary is the subset of the kubelet Summary API (/stats/summary) that we consume
+type Summary struct {
+	Node NodeStats  `json:"node"`
+	Pods []PodStats `json:"pods"`
+}
+
+// NodeStats holds the stats for the node the summary was retrieved from
+type NodeStats struct {
+	NodeName string `json:"nodeName"`
+}
+
+// PodReference identifies the pod a set of stats belongs to
+type PodReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	UID       string `json:"uid"`
+}
+
+// PodStats holds the stats for a single pod
+type PodStats struct {
+	PodRef     PodReference     `json:"podRef"`
+	CPU        *CPUStats        `json:"cpu,omitempty"`
+	Containers []ContainerStats `json:"containers"`
+	Network    *NetworkStats    `json:"network,omitempty"`
+	Volumes    []VolumeStats    `json:"volume,omitempty"`
+}
+
+// ContainerStats holds the stats for a single container
+type ContainerStats struct {
+	Name   string       `json:"name"`
+	CPU    *CPUStats    `json:"cpu,omitempty"`
+	Memory *MemoryStats `json:"memory,omitempty"`
+	Rootfs *FsStats     `json:"rootfs,omitempty"`
+	Logs   *FsStats     `json:"logs,omitempty"`
+}
+
+// CPUStats holds the cpu usage of a container
+type CPUStats struct {
+	Time                 time.Time `json:"time"`
+	UsageNanoCores       *uint64   `json:"usageNanoCores,omitempty"`
+	UsageCoreNanoSeconds *uint64   `json:"usageCoreNanoSeconds,omitempty"`
+}
+
+// MemoryStats holds the memory usage of a container
+type MemoryStats struct {
+	Time            time.Time `json:"time"`
+	UsageBytes      *uint64   `json:"usageBytes,omitempty"`
+	WorkingSetBytes *uint64   `json:"workingSetBytes,omitempty"`
+	RSSBytes        *uint64   `json:"rssBytes,omitempty"`
+	PageFaults      *uint64   `json:"pageFaults,omitempty"`
+	MajorPageFaults *uint64   `json:"majorPageFaults,omitempty"`
+}
+
+// NetworkStats holds the network usage of a pod, the totals are for the default interface
+type NetworkStats struct {
+	Time       time.Time        `json:"time"`
+	Interfaces []InterfaceStats `json:"interfaces,omitempty"`
+	InterfaceStats
+}
+
+// InterfaceStats holds the network usage of a single interface
+type InterfaceStats struct {
+	Name     string  `json:"name"`
+	RxBytes  *uint64 `json:"rxBytes,omitempty"`
+	RxErrors *uint64 `json:"rxErrors,omitempty"`
+	TxBytes  *uint64 `json:"txBytes,omitempty"`
+	TxErrors *uint64 `json:"txErrors,omitempty"`
+}
+
+// FsStats holds the usage of a filesystem
+type FsStats struct {
+	Time           time.Time `json:"time"`
+	AvailableBytes *uint64   `json:"availableBytes,omitempty"`
+	CapacityBytes  *uint64   `json:"capacityBytes,omitempty"`
+	UsedBytes      *uint64   `json:"usedBytes,omitempty"`
+	InodesUsed     *uint64   `json:"inodesUsed,omitempty"`
+}
+
+// VolumeStats holds the usage of a single pod volume
+type VolumeStats struct {
+	Name string `json:"name"`
+	FsStats
+}
+
+// Retrieves the kubelet summary for the given node
+func (k *Kubelet) Summary(ctx context.Context, nodeName string) (*Summary, error) {
+
+	// Request the summary through the node proxy sub resource
+	data, err := k.clientset.CoreV1().RESTClient().Get().
+		Resource("nodes").
+		Name(nodeName).
+		SubResource("proxy").
+		Suffix("stats", "summary").
+		DoRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &Summary{}
+	if err := json.Unmarshal(data, summary); err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+// Retrieves and parses the cAdvisor metrics exposed by the kubelet of the given node
+func (k *Kubelet) Cadvisor(ctx context.Context, nodeName string) ([]prometheus.Sample, error) {
+
+	// Request the metrics through the node proxy sub resource
+	data, err := k.clientset.CoreV1().RESTClient().Get().
+		Resource("nodes").
+		Name(nodeName).
+		SubResource("proxy").
+		Suffix("metrics", "cadvisor").
+		DoRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return prometheus.Parse(bytes.NewReader(data))
+}
+
+// Returns the stats for the given pod from the summary or nil if the pod is not present
+func (s *Summary) Pod(namespace, name string) *PodStats {
+	for i := range s.Pods {
+		if s.Pods[i].PodRef.Namespace == namespace && s.Pods[i].PodRef.Name == name {
+			return &s.Pods[i]
+		}
+	}
+	return nil
+}
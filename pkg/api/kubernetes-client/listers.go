@@ -17,6 +17,7 @@ type Listers struct {
 	Ingress                        listersnetworkingv1.IngressLister
 	Deployment                     listersappsv1.DeploymentLister
 	StatefulSet                    listersappsv1.StatefulSetLister
+	ReplicaSet                     listersappsv1.ReplicaSetLister
 	DaemonSet                      listersappsv1.DaemonSetLister
 	Job                            listersbatchv1.JobLister
 	Service                        listersv1.ServiceLister
@@ -24,4 +25,5 @@ type Listers struct {
 	HorizontalPodAutoscalerV2beta2 listersautoscalingv2beta2.HorizontalPodAutoscalerLister
 	HorizontalPodAutoscalerV2      listersautoscalingv2.HorizontalPodAutoscalerLister
 	PersistentVolumeClaim          listersv1.PersistentVolumeClaimLister
+	Event                          listersv1.EventLister
 }
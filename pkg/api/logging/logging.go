@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log"
+
+	"strings"
+)
+
+// Level is the minimum severity of the messages that are logged
+type Level int
+
+const (
+	Level_Debug Level = iota
+	Level_Info
+	Level_Error
+)
+
+var levelNames = map[string]Level{
+	"debug": Level_Debug,
+	"info":  Level_Info,
+	"error": Level_Error,
+}
+
+// The current level, messages below it are discarded
+var level = Level_Info
+
+// The logger returned for levels that are disabled
+var discard = log.New(io.Discard, "", 0)
+
+// Parses a level name, one of "debug", "info" or "error"
+func ParseLevel(name string) (Level, error) {
+	parsed, exists := levelNames[strings.ToLower(name)]
+	if !exists {
+		return Level_Info, fmt.Errorf("unknown log level %q, must be one of debug, info or error", name)
+	}
+	return parsed, nil
+}
+
+// Sets the minimum level that is logged. This should be called before any goroutines start logging
+func SetLevel(newLevel Level) {
+	level = newLevel
+}
+
+// Returns the logger for verbose messages, such as each sample that is captured
+func Debug() *log.Logger {
+	return logger(Level_Debug)
+}
+
+// Returns the logger for the messages that describe what the application is doing
+func Info() *log.Logger {
+	return logger(Level_Info)
+}
+
+// Returns the logger for errors
+func Error() *log.Logger {
+	return logger(Level_Error)
+}
+
+func logger(messageLevel Level) *log.Logger {
+	if messageLevel < level {
+		return discard
+	}
+	return log.Default()
+}
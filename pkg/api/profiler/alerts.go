@@ -0,0 +1,49 @@
+package profiler
+
+import (
+	"net/http"
+	"pod_profiler/pkg/api/capture"
+	"pod_profiler/pkg/api/logging"
+	"pod_profiler/pkg/api/server"
+)
+
+// How many records of a capture can wait to be checked for alerts, records beyond that are skipped rather than
+// holding up the capture
+const alertBuffer = 100
+
+// Checks each record of the capture for alerts until it stops, recording each alert as an event of its pod and
+// passing it to the notifiers
+func (profiler *Profiler) monitorCapture(running *capture.Capture) {
+
+	for {
+		select {
+		case record := <-running.Samples:
+
+			// Without the pod only the rules relative to requests and limits are skipped
+			pod, err := profiler.K8sClient.Cache.Pod().Get(record.Pod.Name)
+			if err != nil {
+				pod = nil
+			}
+
+			for _, raised := range profiler.monitor.Check(running.Deployment, record, pod) {
+				logging.Info().Printf("alert %s on %s/%s: %s\n", raised.Rule, raised.Pod, raised.Container, raised.Message)
+
+				select {
+				case running.OnEvent <- raised.Event():
+				case <-running.Stopped():
+					return
+				}
+
+				profiler.monitor.Notify(raised)
+			}
+
+		case <-running.Stopped():
+			return
+		}
+	}
+}
+
+// Returns the most recent alerts, oldest first
+func (profiler *Profiler) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	server.WriteJSON(w, http.StatusOK, profiler.monitor.Recent())
+}
@@ -0,0 +1,185 @@
+package profiler
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"pod_profiler/pkg/api/analysis"
+	"pod_profiler/pkg/api/capture"
+	"pod_profiler/pkg/api/defaults"
+	"pod_profiler/pkg/api/results"
+	"pod_profiler/pkg/api/rollup"
+	"pod_profiler/pkg/api/server"
+	"sort"
+	"strconv"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Registers the profiler's handlers with the API server
+func (profiler *Profiler) registerHandlers() {
+	profiler.Server.Handle("GET /api/v1/config", profiler.handleConfig)
+	profiler.Server.Handle("GET /api/v1/config/reload", profiler.handleReload)
+	profiler.Server.Handle("GET /api/v1/targets", profiler.handleTargets)
+	profiler.Server.Handle("GET /api/v1/usage/{pod}", profiler.handleUsage)
+	profiler.Server.Handle("GET /api/v1/leaks", profiler.handleLeaks)
+	profiler.Server.Handle("GET /api/v1/alerts", profiler.handleAlerts)
+	profiler.Server.Handle("GET /api/v1/leader", profiler.handleLeader)
+	profiler.Server.Handle("GET /api/v1/shards", profiler.handleShards)
+}
+
+// Returns the config that is currently applied
+func (profiler *Profiler) handleConfig(w http.ResponseWriter, r *http.Request) {
+	profiler.mutex.Lock()
+	defer profiler.mutex.Unlock()
+
+	server.WriteJSON(w, http.StatusOK, profiler.Config)
+}
+
+// Returns the result of the most recent config reload
+func (profiler *Profiler) handleReload(w http.ResponseWriter, r *http.Request) {
+	profiler.mutex.Lock()
+	defer profiler.mutex.Unlock()
+
+	if profiler.lastReload == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	server.WriteJSON(w, http.StatusOK, profiler.lastReload)
+}
+
+// Returns the targets of the applied config
+func (profiler *Profiler) handleTargets(w http.ResponseWriter, r *http.Request) {
+	profiler.mutex.Lock()
+	defer profiler.mutex.Unlock()
+
+	keys := []string{}
+	for key := range targets(profiler.Config) {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	server.WriteJSON(w, http.StatusOK, keys)
+}
+
+// The response of the usage endpoint
+type usageResponse struct {
+	Pod     string          `json:"pod"`
+	Tier    string          `json:"tier"`
+	From    int64           `json:"from"`
+	To      int64           `json:"to"`
+	Buckets []rollup.Bucket `json:"buckets"`
+}
+
+// Returns the usage of a pod's containers between the from and to query parameters, which are unix timestamps that
+// default to the last hour. The tier parameter picks the raw samples or a rollup tier, by default the finest tier
+// that keeps the response within the point limit is chosen
+func (profiler *Profiler) handleUsage(w http.ResponseWriter, r *http.Request) {
+
+	pod := r.PathValue("pod")
+	if errs := validation.IsDNS1123Subdomain(pod); len(errs) > 0 {
+		server.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid pod name %q", pod))
+		return
+	}
+
+	now := time.Now()
+	to, err := timeParameter(r, "to", now)
+	if err != nil {
+		server.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	from, err := timeParameter(r, "from", to.Add(-time.Hour))
+	if err != nil {
+		server.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if !from.Before(to) {
+		server.WriteError(w, http.StatusBadRequest, fmt.Errorf("from must be before to"))
+		return
+	}
+
+	profiler.mutex.Lock()
+	resultsPath := profiler.Config.ResultsPath
+	retention := profiler.Config.RawRetentionDuration()
+	profiler.mutex.Unlock()
+
+	tier := r.URL.Query().Get("tier")
+	if tier == "" || tier == "auto" {
+		tier = rollup.SelectTier(from, to, now, defaults.POLL_INTERVAL, retention, defaults.API_MAX_POINTS)
+	}
+
+	suffix := capture.FileSuffix_Usage
+	if tier != rollup.RawTier {
+		selected, exists := rollup.TierByName(tier)
+		if !exists {
+			server.WriteError(w, http.StatusBadRequest, fmt.Errorf("unknown tier %q", tier))
+			return
+		}
+		suffix = selected.Suffix
+	}
+
+	// The pod's files are split between the shards it has been captured by
+	directories, err := results.Directories(resultsPath)
+	if err != nil {
+		server.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	buckets := []rollup.Bucket{}
+	for _, directory := range directories {
+		read, err := rollup.ReadBuckets(filepath.Join(directory, pod+suffix), tier == rollup.RawTier, from.Unix(), to.Unix())
+		if err != nil {
+			server.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		buckets = append(buckets, read...)
+	}
+
+	// A bucket can have several rows, such as when a gatherer stopped part way through it and another carried it on
+	buckets = rollup.Merge(buckets)
+
+	server.WriteJSON(w, http.StatusOK, usageResponse{
+		Pod:     pod,
+		Tier:    tier,
+		From:    from.Unix(),
+		To:      to.Unix(),
+		Buckets: buckets,
+	})
+}
+
+// Returns the unix timestamp in the query parameter, or the fallback if it isn't set
+func timeParameter(r *http.Request, name string, fallback time.Time) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be a unix timestamp", name)
+	}
+	return time.Unix(seconds, 0), nil
+}
+
+// Returns the containers whose memory keeps growing across the results directory, with all=true the trend of every
+// container is returned whether it is leaking or not
+func (profiler *Profiler) handleLeaks(w http.ResponseWriter, r *http.Request) {
+
+	profiler.mutex.Lock()
+	resultsPath := profiler.Config.ResultsPath
+	profiler.mutex.Unlock()
+
+	read, err := results.Read(resultsPath)
+	if err != nil {
+		server.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	leaks := read.DetectLeaks(analysis.DefaultLeakOptions)
+	if r.URL.Query().Get("all") != "true" {
+		leaks = results.Leaking(leaks)
+	}
+
+	server.WriteJSON(w, http.StatusOK, leaks)
+}
@@ -0,0 +1,84 @@
+package profiler
+
+import (
+	"encoding/json"
+	"pod_profiler/pkg/api/config"
+	"pod_profiler/pkg/api/logging"
+
+	v1Core "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Registers the event handler on the config map informer so the config is reloaded as soon as our config map changes,
+// rather than waiting for the kubelet to update the mounted file
+func (profiler *Profiler) watchConfigMap() error {
+
+	logging.Info().Printf("Watching config map %s\n", profiler.Config.ConfigMap)
+
+	_, err := profiler.K8sClient.Cache.Informers.ConfigMap.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: profiler.onConfigMap,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			profiler.onConfigMap(newObj)
+		},
+	})
+
+	return err
+}
+
+func (profiler *Profiler) onConfigMap(obj interface{}) {
+
+	configMap, ok := obj.(*v1Core.ConfigMap)
+	if !ok || configMap.GetName() != profiler.Config.ConfigMap {
+		return
+	}
+
+	data, exists := configMap.Data[profiler.Config.ConfigMapKey]
+	if !exists {
+		logging.Error().Printf("config map %s has no key %s\n", configMap.GetName(), profiler.Config.ConfigMapKey)
+		return
+	}
+
+	// Config maps are updated for reasons other than their data, so only reload when the config itself changed
+	if data == profiler.configMapData {
+		return
+	}
+	profiler.configMapData = data
+
+	// Keys without a recognised extension are assumed to hold JSON, which is what the chart has always used
+	format := config.TypeFromFilename(profiler.Config.ConfigMapKey)
+	if format == "" {
+		format = "json"
+	}
+
+	newConfig, err := config.Parse([]byte(data), format)
+	if err != nil {
+		logging.Error().Printf("unable to parse config map %s: %s\n", configMap.GetName(), err.Error())
+		return
+	}
+
+	profiler.mutex.Lock()
+	same := sameConfig(profiler.Config, newConfig)
+	profiler.mutex.Unlock()
+
+	if same {
+		return
+	}
+
+	profiler.applyConfig(newConfig)
+}
+
+// Returns true if the two configs have the same values
+func sameConfig(a, b *config.Config) bool {
+
+	aBytes, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+
+	bBytes, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+
+	return string(aBytes) == string(bBytes)
+}
@@ -0,0 +1,170 @@
+package profiler
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"os"
+	"pod_profiler/pkg/api/defaults"
+	"pod_profiler/pkg/api/logging"
+	"pod_profiler/pkg/api/server"
+
+	v1Meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// A change of leadership waiting to be applied by process, done is closed once the captures have started or stopped.
+// The term counts the elections this replica has run, so a change from an earlier election that arrives late is ignored
+type leadershipChange struct {
+	leading bool
+	term    int
+	done    chan struct{}
+}
+
+// The term of the change that stops the captures when the profiler stops, which no election can come after
+const finalTerm = math.MaxInt
+
+// The response of the leader endpoint
+type leaderResponse struct {
+	Identity string `json:"identity"`
+	Leader   string `json:"leader"`
+	Leading  bool   `json:"leading"`
+}
+
+// Returns the identity this replica campaigns with, the pod name when it is set through the downward API
+func identity() string {
+	if name := os.Getenv("POD_NAME"); name != "" {
+		return name
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "pod-profiler-gatherer"
+	}
+	return hostname
+}
+
+// Campaigns for the lease in the background until Stop is called. The captures are started when the lease is acquired
+// and stopped when it is lost, after which this replica campaigns again as a standby
+func (profiler *Profiler) campaign() {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	profiler.mutex.Lock()
+	profiler.cancelElection = cancel
+	profiler.electionDone = done
+	profiler.mutex.Unlock()
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: v1Meta.ObjectMeta{
+			Name:      profiler.Config.LeaseName,
+			Namespace: profiler.Config.Namespace,
+		},
+		Client:     profiler.K8sClient.Clientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: identity()},
+	}
+
+	electionConfig := leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		Name:          profiler.Config.LeaseName,
+		LeaseDuration: defaults.LEASE_DURATION,
+		RenewDeadline: defaults.LEASE_RENEW_DEADLINE,
+		RetryPeriod:   defaults.LEASE_RETRY_PERIOD,
+
+		// Stop releases the lease once the captures have stopped, so a standby takes over within the retry period
+		// rather than waiting for the lease to expire
+		ReleaseOnCancel: true,
+	}
+
+	// The elector starts OnStartedLeading in a goroutine but calls OnStoppedLeading directly, so the stop could
+	// be applied before the start. Instead the captures are stopped once the context of OnStartedLeading is done
+	callbacks := func(term int) leaderelection.LeaderCallbacks {
+		return leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				logging.Info().Printf("Acquired the %s lease as %s\n", lock.LeaseMeta.Name, lock.Identity())
+				profiler.setLeading(true, term)
+				<-ctx.Done()
+				profiler.setLeading(false, term)
+			},
+			OnStoppedLeading: func() {},
+			OnNewLeader: func(leader string) {
+				logging.Info().Printf("%s holds the %s lease\n", leader, lock.LeaseMeta.Name)
+				profiler.mutex.Lock()
+				profiler.leader = leader
+				profiler.mutex.Unlock()
+			},
+		}
+	}
+
+	go func() {
+		defer close(done)
+
+		for term := 1; ctx.Err() == nil; term++ {
+			electionConfig.Callbacks = callbacks(term)
+			elector, err := leaderelection.NewLeaderElector(electionConfig)
+			if err != nil {
+				logging.Error().Printf("error: %s\n", err.Error())
+				return
+			}
+			elector.Run(ctx)
+		}
+	}()
+}
+
+// Queues the leadership change and waits for the captures to be started or stopped
+func (profiler *Profiler) setLeading(leading bool, term int) {
+	change := leadershipChange{leading: leading, term: term, done: make(chan struct{})}
+	profiler.leadership <- change
+	<-change.done
+}
+
+// Starts the captures of every target when we become the leader and stops them all when we no longer are
+func (profiler *Profiler) changeLeadership(change leadershipChange) {
+
+	if change.term < profiler.term {
+		return
+	}
+	profiler.term = change.term
+
+	leading := change.leading
+	if leading == profiler.isLeading() {
+		return
+	}
+
+	profiler.mutex.Lock()
+	profiler.leading = leading
+	current := *profiler.Config
+	profiler.mutex.Unlock()
+
+	result := profiler.reconcile(reloadRequest{config: &current})
+	if !result.Success {
+		logging.Error().Printf("error: %s\n", result.Error)
+	}
+
+	if leading {
+		logging.Info().Printf("Leading, started capturing %v\n", result.Added)
+	} else {
+		logging.Info().Printf("Stopped capturing %v\n", result.Removed)
+	}
+}
+
+// Returns true if this replica should be capturing
+func (profiler *Profiler) isLeading() bool {
+	profiler.mutex.Lock()
+	defer profiler.mutex.Unlock()
+
+	return profiler.leading
+}
+
+// Returns which replica holds the lease and whether it is this one
+func (profiler *Profiler) handleLeader(w http.ResponseWriter, r *http.Request) {
+	profiler.mutex.Lock()
+	defer profiler.mutex.Unlock()
+
+	server.WriteJSON(w, http.StatusOK, leaderResponse{
+		Identity: identity(),
+		Leader:   profiler.leader,
+		Leading:  profiler.leading,
+	})
+}
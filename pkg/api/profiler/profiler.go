@@ -1,14 +1,24 @@
 package profiler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"os"
 	"path"
+	"path/filepath"
+	"pod_profiler/pkg/api/alert"
 	"pod_profiler/pkg/api/capture"
 	"pod_profiler/pkg/api/config"
+	"pod_profiler/pkg/api/defaults"
+	"pod_profiler/pkg/api/defaults/cloud"
 	kubernetesClient "pod_profiler/pkg/api/kubernetes-client"
+	"pod_profiler/pkg/api/logging"
+	"pod_profiler/pkg/api/results"
+	"pod_profiler/pkg/api/server"
+	"pod_profiler/pkg/api/shard"
+	"strings"
+	"sync"
 
 	"github.com/fsnotify/fsnotify"
 )
@@ -19,13 +29,49 @@ type Profiler struct {
 	// The client used to access kubernetes resources
 	K8sClient *kubernetesClient.Client
 
+	// The HTTP API used to report on the profiler
+	Server *server.Server
+
 	Errors chan error
 
-	running chan bool
+	// Config changes, or the errors from loading them, waiting to be applied by process
+	reload chan reloadRequest
+
+	// The running captures keyed by target
+	captures map[string]*capture.Capture
+
+	// The targets of the running captures
+	running map[string]target
+
+	// Leadership changes waiting to be applied by process. Captures only run while leading, which is always
+	// the case without leader election
+	leadership chan leadershipChange
+	leading    bool
+
+	// The election term of the last leadership change that was applied, only used by process
+	term int
+
+	// The identity of the replica holding the lease, and the function that stops campaigning for it
+	leader         string
+	cancelElection context.CancelFunc
+	electionDone   chan struct{}
+
+	// With sharding, the membership of this shard, the ring of the live shards and the changes to them waiting to be
+	// applied by process. The ring is nil until the members are known
+	membership *shard.Membership
+	ring       *shard.Ring
+	members    chan []string
+
+	// The result of the most recent reload
+	lastReload *ReloadResult
+
+	// Checks the records of every capture against the alert rules
+	monitor *alert.Monitor
 
-	restart chan bool
+	mutex sync.Mutex
 
-	captures []*capture.Capture
+	// The contents of the config map the config was last loaded from
+	configMapData string
 }
 
 func New() (*Profiler, error) {
@@ -37,24 +83,43 @@ func New() (*Profiler, error) {
 
 	config.VarDump()
 
+	if config.Platform != "" {
+		platform, err := cloud.ParsePlatform(config.Platform)
+		if err != nil {
+			return nil, err
+		}
+		kubernetesClient.Platform = platform
+	}
+	kubernetesClient.HPAVersion = config.HPAVersion
+
 	// create a new k8s client
-	K8sClient, err := newK8sClient(config.Namespace)
+	K8sClient, err := NewK8sClient(config.Namespace, config.ConfigMap != "")
 	if err != nil {
 		return nil, err
 	}
 
+	logging.Info().Printf("Running on the %s platform\n", K8sClient.Platform())
+
 	return &Profiler{
-		Config:    config,
-		K8sClient: K8sClient,
-		Errors:    make(chan error),
-		running:   make(chan bool),
+		Config:     config,
+		K8sClient:  K8sClient,
+		Server:     server.New(defaults.HTTP_PORT),
+		Errors:     make(chan error),
+		reload:     make(chan reloadRequest),
+		captures:   map[string]*capture.Capture{},
+		running:    map[string]target{},
+		leadership: make(chan leadershipChange),
+		leading:    !config.LeaderElection,
+		members:    make(chan []string),
+		monitor:    alert.NewMonitor(config.Alerts),
 	}, nil
 
 }
 
-func newK8sClient(namespace string) (*kubernetesClient.Client, error) {
+// Creates a kubernetes client with the cache of every resource the captures watch
+func NewK8sClient(namespace string, watchConfigMap bool) (*kubernetesClient.Client, error) {
 
-	log.Default().Println("Create kubernetes client")
+	logging.Info().Println("Create kubernetes client")
 
 	// Create the kubernetes client
 	client, err := kubernetesClient.NewClient()
@@ -65,15 +130,28 @@ func newK8sClient(namespace string) (*kubernetesClient.Client, error) {
 	// Create a list of resources to cache
 	cacheResources := []kubernetesClient.CachedResource{
 		kubernetesClient.CachedResource_Pod,
+		kubernetesClient.CachedResource_Deployment,
 		kubernetesClient.CachedResource_StatefulSet,
+		kubernetesClient.CachedResource_ReplicaSet,
+		kubernetesClient.CachedResource_Event,
+		kubernetesClient.CachedResource_Job,
+		kubernetesClient.CachedResource_HPA,
+	}
+
+	// Only cache the config maps if we are watching our config through one
+	if watchConfigMap {
+		cacheResources = append(cacheResources, kubernetesClient.CachedResource_ConfigMap)
 	}
 
-	log.Default().Println("Starting to sync the cache")
+	logging.Info().Println("Starting to sync the cache")
 
 	// Start to sync the cache
-	client.BuildAndSyncNamedspacedCache(namespace, cacheResources...)
+	err = client.BuildAndSyncNamedspacedCache(namespace, cacheResources...)
+	if err != nil {
+		return nil, err
+	}
 
-	log.Default().Println("Cache sync complete")
+	logging.Info().Println("Cache sync complete")
 
 	// Return the new client and nil error to indicate a success
 	return client, nil
@@ -81,107 +159,116 @@ func newK8sClient(namespace string) (*kubernetesClient.Client, error) {
 
 func (profiler *Profiler) Start() error {
 
-	if _, err := os.Stat(profiler.Config.ResultsPath); os.IsNotExist(err) {
-		err := os.Mkdir(profiler.Config.ResultsPath, os.ModePerm)
+	profiler.registerHandlers()
+
+	go func() {
+		err := profiler.Server.Start()
+		if err != nil {
+			profiler.Errors <- err
+		}
+	}()
+
+	if profiler.Config.ConfigMap != "" {
+		err := profiler.watchConfigMap()
 		if err != nil {
 			return err
 		}
+	} else {
+		go profiler.Config.OnConfigChange(profiler.OnConfigChange)
 	}
 
-	go profiler.Config.OnConfigChange(profiler.OnConfigChange)
-
 	go profiler.process()
 
-	profiler.running <- true
+	// Apply a copy of the initial config, so the captures are started for every target once we lead
+	initialConfig := *profiler.Config
+	profiler.reload <- reloadRequest{config: &initialConfig}
+
+	if profiler.Config.LeaderElection {
+		profiler.campaign()
+	}
+	if profiler.Config.Sharding {
+		profiler.join()
+	}
 
 	return nil
 }
 
-func (profiler *Profiler) process() {
+// Stops the captures, waiting for their files to be closed, and then releases the lease so a standby takes over
+// without both writing to the same files. A shard leaves so the others take over its pods
+func (profiler *Profiler) Stop() {
 
-	for {
-		select {
-		case running := <-profiler.running:
+	profiler.setLeading(false, finalTerm)
 
-			if running {
-				log.Default().Println("Starting captures")
+	profiler.mutex.Lock()
+	cancel, done := profiler.cancelElection, profiler.electionDone
+	membership := profiler.membership
+	profiler.mutex.Unlock()
 
-				err := profiler.initialiseCaptures()
-				if err != nil {
-					profiler.Errors <- err
-				}
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+	if membership != nil {
+		membership.Leave()
+	}
+}
 
-				err = profiler.CreateCaptureList()
-				if err != nil {
-					profiler.Errors <- err
-				}
+// Applies each config, leadership and shard change in turn, so a change never interrupts another that is still being applied
+func (profiler *Profiler) process() {
 
-				for _, capture := range profiler.captures {
-					go capture.StartCapture()
-				}
+	for {
+		select {
+		case request := <-profiler.reload:
+			result := profiler.reconcile(request)
+			profiler.reportReload(result)
 
-			} else {
-				log.Default().Println("Stopping capture")
-				for _, capture := range profiler.captures {
-					capture.StopCapture()
-				}
+		case change := <-profiler.leadership:
+			profiler.changeLeadership(change)
+			close(change.done)
 
-			}
-		case <-profiler.Errors:
-			return
+		case members := <-profiler.members:
+			profiler.changeMembers(members)
 		}
-
 	}
-
 }
 
 func (profiler *Profiler) OnConfigChange(event fsnotify.Event) {
 
 	newConfig, err := config.Load(false)
-	if err != nil {
-		log.Default().Fatalf("unable to load config: %s\n", err.Error())
-	}
-
-	*profiler.Config = *newConfig
-	log.Default().Println("config file updated")
-
-	profiler.running <- false
-
-	profiler.Config.VarDump()
-
-	profiler.running <- true
-
+	profiler.reload <- reloadRequest{config: newConfig, err: err}
 }
 
-func (profiler *Profiler) initialiseCaptures() error {
-	captures := []*capture.Capture{}
-
-	for _, deployment := range profiler.Config.PodLabels {
-		capture, err := capture.New(profiler.K8sClient, profiler.Config.ResultsPath, deployment)
-		if err != nil {
-			return err
-		}
-
-		captures = append(captures, capture)
-	}
-
-	profiler.captures = captures
-
-	return nil
+// Queues the new config to be applied
+func (profiler *Profiler) applyConfig(newConfig *config.Config) {
+	profiler.reload <- reloadRequest{config: newConfig}
 }
 
 func (profiler *Profiler) CreateCaptureList() error {
 
 	listOfFiles := []string{}
 
-	files, err := os.ReadDir(profiler.Config.ResultsPath)
+	// The files of each shard are listed by their path within the results directory
+	directories, err := results.Directories(profiler.Config.ResultsPath)
 	if err != nil {
 		return err
 	}
 
-	for _, file := range files {
-		if file.Name() != "index.json" {
-			listOfFiles = append(listOfFiles, file.Name())
+	for _, directory := range directories {
+		files, err := os.ReadDir(directory)
+		if err != nil {
+			return err
+		}
+
+		relative, err := filepath.Rel(profiler.Config.ResultsPath, directory)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			if strings.HasPrefix(file.Name(), "index.json") || (relative == "." && file.Name() == defaults.SHARDS_DIRECTORY) {
+				continue
+			}
+			listOfFiles = append(listOfFiles, path.Join(filepath.ToSlash(relative), file.Name()))
 		}
 	}
 
@@ -190,7 +277,11 @@ func (profiler *Profiler) CreateCaptureList() error {
 		return err
 	}
 
-	indexFile, err := os.OpenFile(path.Join(profiler.Config.ResultsPath, "index.json"), os.O_WRONLY|os.O_CREATE, 0777)
+	// Shards write the index at the same time, so each writes its own copy and renames it over the index
+	indexPath := path.Join(profiler.Config.ResultsPath, "index.json")
+	temporaryPath := indexPath + "." + identity()
+
+	indexFile, err := os.OpenFile(temporaryPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0777)
 	if err != nil {
 		return err
 	}
@@ -204,6 +295,6 @@ func (profiler *Profiler) CreateCaptureList() error {
 	if err != nil {
 		return err
 	}
-	return nil
+	return os.Rename(temporaryPath, indexPath)
 
 }
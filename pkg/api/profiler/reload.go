@@ -0,0 +1,248 @@
+package profiler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"pod_profiler/pkg/api/capture"
+	"pod_profiler/pkg/api/config"
+	"pod_profiler/pkg/api/logging"
+	"sort"
+	"time"
+
+	v1Core "k8s.io/api/core/v1"
+	v1Meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// A config change waiting to be applied, or the error from loading it
+type reloadRequest struct {
+	config *config.Config
+	err    error
+}
+
+// ReloadResult describes what happened when a config change was applied
+type ReloadResult struct {
+	Time         int64    `json:"time"`
+	Success      bool     `json:"success"`
+	Error        string   `json:"error,omitempty"`
+	Added        []string `json:"added"`
+	Removed      []string `json:"removed"`
+	Reconfigured []string `json:"reconfigured"`
+}
+
+// Returns true if the reload changed which captures are running
+func (result *ReloadResult) Changed() bool {
+	return len(result.Added) > 0 || len(result.Removed) > 0 || len(result.Reconfigured) > 0
+}
+
+// A target is everything a capture is created from, so two targets that differ need separate captures
+type target struct {
+	Name         string
+	Mode         capture.CaptureMode
+	Collector    capture.CollectorType
+	ResultsPath  string
+	RawRetention time.Duration
+
+	// With sharding, each shard writes to its own directory and only captures the pods it owns. The captures keep
+	// running when the shards change, they are resharded instead
+	Sharded bool
+}
+
+// Returns the targets of the config keyed by mode and name
+func targets(cfg *config.Config) map[string]target {
+
+	result := map[string]target{}
+	if cfg == nil {
+		return result
+	}
+
+	for _, name := range cfg.PodLabels {
+		t := target{name, capture.CaptureMode_Workload, capture.CollectorType(cfg.Collector), cfg.ResultsPath, cfg.RawRetentionDuration(), false}
+		result[t.key()] = t
+	}
+
+	for _, name := range cfg.Jobs {
+		t := target{name, capture.CaptureMode_Job, capture.CollectorType(cfg.Collector), cfg.ResultsPath, cfg.RawRetentionDuration(), false}
+		result[t.key()] = t
+	}
+
+	return result
+}
+
+func (t target) key() string {
+	return string(t.Mode) + "/" + t.Name
+}
+
+// Applies the new config by only starting the captures of added targets, stopping those of removed
+// targets and restarting those whose settings changed. An invalid config is rejected and the current
+// captures are left running
+func (profiler *Profiler) reconcile(request reloadRequest) *ReloadResult {
+
+	result := &ReloadResult{
+		Time:         time.Now().Unix(),
+		Added:        []string{},
+		Removed:      []string{},
+		Reconfigured: []string{},
+	}
+
+	if request.err != nil {
+		result.Error = fmt.Sprintf("unable to load config: %s", request.err.Error())
+		return result
+	}
+
+	if err := request.config.Validate(); err != nil {
+		result.Error = fmt.Sprintf("invalid config: %s", err.Error())
+		return result
+	}
+
+	if _, err := os.Stat(request.config.ResultsPath); os.IsNotExist(err) {
+		err := os.MkdirAll(request.config.ResultsPath, os.ModePerm)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+	}
+
+	// Only the leader captures, a standby keeps the config so it can start capturing as soon as it leads
+	oldTargets := profiler.running
+	newTargets := targets(request.config)
+	if !profiler.isLeading() {
+		newTargets = map[string]target{}
+	}
+	newTargets = profiler.shardTargets(newTargets)
+
+	for key, oldTarget := range oldTargets {
+		newTarget, exists := newTargets[key]
+		if exists && newTarget == oldTarget {
+			continue
+		}
+
+		if running, exists := profiler.captures[key]; exists {
+			running.StopCapture()
+			delete(profiler.captures, key)
+		}
+
+		if !exists {
+			result.Removed = append(result.Removed, key)
+		}
+	}
+
+	errors := []string{}
+	for key, newTarget := range newTargets {
+		oldTarget, existed := oldTargets[key]
+		if existed && oldTarget == newTarget {
+			continue
+		}
+
+		if existed {
+			result.Reconfigured = append(result.Reconfigured, key)
+		} else {
+			result.Added = append(result.Added, key)
+		}
+
+		// Each shard writes to its own directory
+		if newTarget.Sharded {
+			if err := os.MkdirAll(newTarget.ResultsPath, os.ModePerm); err != nil {
+				errors = append(errors, fmt.Sprintf("%s: %s", key, err.Error()))
+				continue
+			}
+		}
+
+		var created *capture.Capture
+		var err error
+		if newTarget.Sharded && newTarget.Mode == capture.CaptureMode_Workload {
+			created, err = capture.NewWithFilter(profiler.K8sClient, newTarget.ResultsPath, newTarget.Name, profiler.ownsPod, newTarget.Collector, newTarget.Mode)
+		} else {
+			created, err = capture.New(profiler.K8sClient, newTarget.ResultsPath, newTarget.Name, newTarget.Collector, newTarget.Mode)
+		}
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %s", key, err.Error()))
+			continue
+		}
+
+		created.RawRetention = newTarget.RawRetention
+		created.PodsOnly = newTarget.Sharded && !profiler.primary(key)
+		created.Samples = make(chan capture.Record, alertBuffer)
+		profiler.captures[key] = created
+		created.StartCapture()
+		go profiler.monitorCapture(created)
+	}
+
+	profiler.mutex.Lock()
+	*profiler.Config = *request.config
+	profiler.mutex.Unlock()
+	profiler.running = newTargets
+
+	// The alerts are checked as records arrive, so changing them doesn't need the captures to be restarted
+	profiler.monitor.Configure(request.config.Alerts)
+
+	if err := profiler.CreateCaptureList(); err != nil {
+		errors = append(errors, err.Error())
+	}
+
+	sort.Strings(result.Added)
+	sort.Strings(result.Removed)
+	sort.Strings(result.Reconfigured)
+
+	result.Success = len(errors) == 0
+	if !result.Success {
+		result.Error = fmt.Sprintf("unable to start captures: %v", errors)
+	}
+
+	return result
+}
+
+// Logs the result of a reload, stores it for the API and records it as a kubernetes event
+func (profiler *Profiler) reportReload(result *ReloadResult) {
+
+	profiler.mutex.Lock()
+	profiler.lastReload = result
+	profiler.mutex.Unlock()
+
+	if !result.Success {
+		logging.Error().Printf("config reload failed: %s\n", result.Error)
+	} else if result.Changed() {
+		logging.Info().Printf("config reloaded, added: %v removed: %v reconfigured: %v\n", result.Added, result.Removed, result.Reconfigured)
+		profiler.Config.VarDump()
+	} else {
+
+		// The file watcher fires several times for a single change, there is nothing to report for the repeats
+		return
+	}
+
+	// We can only attach an event to our config map if we know which one it is
+	if profiler.Config.ConfigMap == "" {
+		return
+	}
+
+	event := &v1Core.Event{
+		ObjectMeta: v1Meta.ObjectMeta{
+			GenerateName: profiler.Config.ConfigMap + "-",
+			Namespace:    profiler.Config.Namespace,
+		},
+		InvolvedObject: v1Core.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Name:       profiler.Config.ConfigMap,
+			Namespace:  profiler.Config.Namespace,
+		},
+		Type:           v1Core.EventTypeNormal,
+		Reason:         "ConfigReloaded",
+		Message:        fmt.Sprintf("added: %v removed: %v reconfigured: %v", result.Added, result.Removed, result.Reconfigured),
+		Source:         v1Core.EventSource{Component: "pod-profiler-gatherer"},
+		FirstTimestamp: v1Meta.NewTime(time.Unix(result.Time, 0)),
+		LastTimestamp:  v1Meta.NewTime(time.Unix(result.Time, 0)),
+		Count:          1,
+	}
+
+	if !result.Success {
+		event.Type = v1Core.EventTypeWarning
+		event.Reason = "ConfigReloadFailed"
+		event.Message = result.Error
+	}
+
+	_, err := profiler.K8sClient.Clientset.CoreV1().Events(profiler.Config.Namespace).Create(context.Background(), event, v1Meta.CreateOptions{})
+	if err != nil {
+		logging.Error().Printf("error: %s\n", err.Error())
+	}
+}
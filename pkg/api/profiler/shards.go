@@ -0,0 +1,123 @@
+package profiler
+
+import (
+	"net/http"
+	"path/filepath"
+	"pod_profiler/pkg/api/capture"
+	"pod_profiler/pkg/api/defaults"
+	"pod_profiler/pkg/api/logging"
+	"pod_profiler/pkg/api/server"
+	"pod_profiler/pkg/api/shard"
+
+	v1Core "k8s.io/api/core/v1"
+)
+
+// The response of the shards endpoint
+type shardsResponse struct {
+	Identity string   `json:"identity"`
+	Members  []string `json:"members"`
+}
+
+// Joins the other shards in the background. Nothing is captured until the members are known
+func (profiler *Profiler) join() {
+
+	membership := shard.NewMembership(profiler.K8sClient.Clientset, profiler.Config.Namespace, profiler.Config.LeaseName, identity())
+	membership.OnChange = func(members []string) {
+		profiler.members <- members
+	}
+
+	profiler.mutex.Lock()
+	profiler.membership = membership
+	profiler.mutex.Unlock()
+
+	membership.Join()
+}
+
+// Rebuilds the ring from the members and reshards the running captures, so each one starts the pods this shard now
+// owns and stops those it no longer does. Only the captures of jobs that moved to or from this shard are started or stopped
+func (profiler *Profiler) changeMembers(members []string) {
+
+	profiler.mutex.Lock()
+	profiler.ring = shard.NewRing(members)
+	current := *profiler.Config
+	profiler.mutex.Unlock()
+
+	result := profiler.reconcile(reloadRequest{config: &current})
+	if !result.Success {
+		logging.Error().Printf("error: %s\n", result.Error)
+	}
+
+	for key, running := range profiler.captures {
+		if profiler.running[key].Sharded {
+			running.Reshard(!profiler.primary(key))
+		}
+	}
+
+	logging.Info().Printf("Sharded across %v, capturing %d targets\n", members, len(profiler.running))
+}
+
+// Returns the targets this shard captures. Every shard captures its share of the pods of each workload, but only the
+// shard that owns a target records its rollouts and autoscaling. Without sharding every target is returned as it is
+func (profiler *Profiler) shardTargets(all map[string]target) map[string]target {
+
+	profiler.mutex.Lock()
+	ring, sharding := profiler.ring, profiler.membership != nil
+	profiler.mutex.Unlock()
+
+	if !sharding {
+		return all
+	}
+
+	self := identity()
+	result := map[string]target{}
+	if ring == nil || !ring.Contains(self) {
+		return result
+	}
+
+	for key, t := range all {
+
+		// The pods of a job are summarised together when it finishes, so each job is captured by a single shard
+		if t.Mode == capture.CaptureMode_Job && ring.Owner(key) != self {
+			continue
+		}
+
+		t.ResultsPath = filepath.Join(t.ResultsPath, defaults.SHARDS_DIRECTORY, self)
+		t.Sharded = true
+		result[key] = t
+	}
+
+	return result
+}
+
+// Returns true if this shard records the rollouts and autoscaling of the target with the given key
+func (profiler *Profiler) primary(key string) bool {
+
+	profiler.mutex.Lock()
+	ring := profiler.ring
+	profiler.mutex.Unlock()
+
+	return ring != nil && ring.Owner(key) == identity()
+}
+
+// Returns true if the pod belongs to this shard
+func (profiler *Profiler) ownsPod(pod *v1Core.Pod) bool {
+
+	profiler.mutex.Lock()
+	ring := profiler.ring
+	profiler.mutex.Unlock()
+
+	return ring != nil && ring.Owner(string(pod.GetUID())) == identity()
+}
+
+// Returns the identity of this shard and of every live shard
+func (profiler *Profiler) handleShards(w http.ResponseWriter, r *http.Request) {
+	profiler.mutex.Lock()
+	defer profiler.mutex.Unlock()
+
+	response := shardsResponse{Identity: identity(), Members: []string{}}
+	if profiler.ring != nil {
+		response.Members = profiler.ring.Members()
+	}
+
+	server.WriteJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,176 @@
+package prometheus
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Sample is a single sample from a metrics endpoint using the Prometheus text exposition format
+type Sample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+
+	// The timestamp in milliseconds since the epoch, or zero if the sample did not include one
+	Timestamp int64
+}
+
+// Parse reads every sample from the Prometheus text exposition format. This does not require access
+// to a cluster, so it can be used on scrapes that have been saved to disk as well as live responses
+func Parse(reader io.Reader) ([]Sample, error) {
+
+	samples := []Sample{}
+
+	scanner := bufio.NewScanner(reader)
+
+	// cAdvisor responses can contain very long lines, so allow for lines of up to 1MB
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+
+		line := strings.TrimSpace(scanner.Text())
+
+		// Skip blank lines along with the HELP, TYPE and comment lines
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		sample, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNumber, err.Error())
+		}
+
+		samples = append(samples, sample)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return samples, nil
+}
+
+// Parses a single sample line in the form: name{label="value",...} value [timestamp]
+func parseLine(line string) (Sample, error) {
+
+	sample := Sample{
+		Labels: map[string]string{},
+	}
+
+	// The metric name runs until the label set or the first whitespace
+	end := strings.IndexAny(line, "{ \t")
+	if end <= 0 {
+		return sample, fmt.Errorf("missing metric name or value")
+	}
+	sample.Name = line[:end]
+	rest := line[end:]
+
+	if strings.HasPrefix(rest, "{") {
+		var err error
+		rest, err = parseLabels(rest[1:], sample.Labels)
+		if err != nil {
+			return sample, err
+		}
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) < 1 || len(fields) > 2 {
+		return sample, fmt.Errorf("expected a value and an optional timestamp")
+	}
+
+	value, err := parseValue(fields[0])
+	if err != nil {
+		return sample, err
+	}
+	sample.Value = value
+
+	if len(fields) == 2 {
+		sample.Timestamp, err = strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return sample, fmt.Errorf("invalid timestamp %q", fields[1])
+		}
+	}
+
+	return sample, nil
+}
+
+// Parses the label pairs into labels and returns the remainder of the line after the closing brace
+func parseLabels(input string, labels map[string]string) (string, error) {
+
+	for {
+		input = strings.TrimLeft(input, " \t")
+
+		if strings.HasPrefix(input, "}") {
+			return input[1:], nil
+		}
+
+		equals := strings.Index(input, "=")
+		if equals <= 0 {
+			return "", fmt.Errorf("invalid label set")
+		}
+		name := strings.TrimSpace(input[:equals])
+		input = strings.TrimLeft(input[equals+1:], " \t")
+
+		if !strings.HasPrefix(input, "\"") {
+			return "", fmt.Errorf("label %q value is not quoted", name)
+		}
+
+		// Read the quoted value, handling the escape sequences allowed by the format
+		var value strings.Builder
+		closed := false
+		i := 1
+		for ; i < len(input); i++ {
+			c := input[i]
+			if c == '\\' && i+1 < len(input) {
+				i++
+				switch input[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(input[i])
+				}
+				continue
+			}
+			if c == '"' {
+				closed = true
+				break
+			}
+			value.WriteByte(c)
+		}
+
+		if !closed {
+			return "", fmt.Errorf("label %q value is not terminated", name)
+		}
+
+		labels[name] = value.String()
+		input = strings.TrimLeft(input[i+1:], " \t")
+
+		if strings.HasPrefix(input, ",") {
+			input = input[1:]
+		}
+	}
+}
+
+// Parses a sample value, including the special values allowed by the format
+func parseValue(value string) (float64, error) {
+	switch value {
+	case "+Inf":
+		return math.Inf(1), nil
+	case "-Inf":
+		return math.Inf(-1), nil
+	case "NaN":
+		return math.NaN(), nil
+	}
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", value)
+	}
+	return parsed, nil
+}
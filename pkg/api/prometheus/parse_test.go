@@ -0,0 +1,151 @@
+package prometheus
+
+import (
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCadvisorFixture(t *testing.T) {
+
+	file, err := os.Open("testdata/cadvisor-1.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	samples, err := Parse(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The HELP and TYPE lines are skipped
+	if len(samples) != 20 {
+		t.Fatalf("expected 20 samples, got %d", len(samples))
+	}
+
+	first := samples[0]
+	if first.Name != "cadvisor_version_info" || first.Value != 1 || first.Timestamp != 0 {
+		t.Errorf("unexpected first sample %+v", first)
+	}
+	if first.Labels["osVersion"] != "Ubuntu 22.04.4 LTS" || first.Labels["cadvisorVersion"] != "" {
+		t.Errorf("unexpected first sample labels %v", first.Labels)
+	}
+
+	expected := Sample{
+		Name: "container_cpu_cfs_throttled_periods_total",
+		Labels: map[string]string{
+			"container": "api",
+			"id":        "/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod3f1c0a2e_1a2b_4c5d_8e9f_0123456789ab.slice/cri-containerd-api3f1c0a2e1a2b4.scope",
+			"image":     "registry.example.com/sps-api:1.2.0",
+			"name":      "api3f1c0a2e1a2b4",
+			"namespace": "sps",
+			"pod":       "sps-api-7d9f8-abcde",
+		},
+		Value:     300,
+		Timestamp: 1700000000000,
+	}
+	if !reflect.DeepEqual(samples[4], expected) {
+		t.Errorf("expected %+v, got %+v", expected, samples[4])
+	}
+
+	values := map[string]float64{}
+	for _, sample := range samples {
+		if sample.Labels["namespace"] == "sps" && sample.Labels["container"] == "api" {
+			values[sample.Name] = sample.Value
+		}
+	}
+	for name, value := range map[string]float64{
+		"container_cpu_cfs_periods_total":           12000,
+		"container_cpu_cfs_throttled_periods_total": 300,
+		"container_cpu_usage_seconds_total":         100,
+		"container_memory_working_set_bytes":        104857600,
+	} {
+		if values[name] != value {
+			t.Errorf("%s: expected %g, got %g", name, value, values[name])
+		}
+	}
+}
+
+func TestParseLine(t *testing.T) {
+
+	tests := []struct {
+		line     string
+		expected Sample
+	}{
+		{
+			line:     `up 1`,
+			expected: Sample{Name: "up", Labels: map[string]string{}, Value: 1},
+		},
+		{
+			line:     `http_requests_total{method="post",code="200"} 1027 1395066363000`,
+			expected: Sample{Name: "http_requests_total", Labels: map[string]string{"method": "post", "code": "200"}, Value: 1027, Timestamp: 1395066363000},
+		},
+		{
+			line:     `msdos_file_access_time_seconds{path="C:\\DIR\\FILE.TXT",error="Cannot find file:\n\"FILE.TXT\""} 1.458255915e9`,
+			expected: Sample{Name: "msdos_file_access_time_seconds", Labels: map[string]string{"path": `C:\DIR\FILE.TXT`, "error": "Cannot find file:\n\"FILE.TXT\""}, Value: 1.458255915e9},
+		},
+		{
+			line:     `metric_with_trailing_comma{a="1",} -2.5`,
+			expected: Sample{Name: "metric_with_trailing_comma", Labels: map[string]string{"a": "1"}, Value: -2.5},
+		},
+		{
+			line:     `spaced{ a = "x" , b="y" }   3`,
+			expected: Sample{Name: "spaced", Labels: map[string]string{"a": "x", "b": "y"}, Value: 3},
+		},
+		{
+			line:     `braces_in_value{a="}{"} 4`,
+			expected: Sample{Name: "braces_in_value", Labels: map[string]string{"a": "}{"}, Value: 4},
+		},
+		{
+			line:     `positive_infinity +Inf`,
+			expected: Sample{Name: "positive_infinity", Labels: map[string]string{}, Value: math.Inf(1)},
+		},
+		{
+			line:     `negative_infinity -Inf`,
+			expected: Sample{Name: "negative_infinity", Labels: map[string]string{}, Value: math.Inf(-1)},
+		},
+	}
+
+	for _, test := range tests {
+		sample, err := parseLine(test.line)
+		if err != nil {
+			t.Errorf("%s: %s", test.line, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(sample, test.expected) {
+			t.Errorf("%s: expected %+v, got %+v", test.line, test.expected, sample)
+		}
+	}
+
+	sample, err := parseLine(`not_a_number NaN`)
+	if err != nil || !math.IsNaN(sample.Value) {
+		t.Errorf("expected NaN, got %v (%v)", sample.Value, err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{"up", "line 1: missing metric name or value"},
+		{"# comment\nup 1\n{a=\"1\"} 2", "line 3: missing metric name or value"},
+		{"up one", "line 1: invalid value \"one\""},
+		{"up 1 2 3", "line 1: expected a value and an optional timestamp"},
+		{"up 1 soon", "line 1: invalid timestamp \"soon\""},
+		{"up{a=1} 1", "line 1: label \"a\" value is not quoted"},
+		{"up{a=\"1} 1", "line 1: label \"a\" value is not terminated"},
+		{"up{\"1\"} 1", "line 1: invalid label set"},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(strings.NewReader(test.input))
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%q: expected error %q, got %v", test.input, test.err, err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package report
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"math"
+	"strings"
+	"time"
+)
+
+// The size of the charts and the space around the plot for the axis labels
+const (
+	chartWidth   = 860
+	chartHeight  = 260
+	marginLeft   = 70
+	marginRight  = 20
+	marginTop    = 20
+	marginBottom = 30
+)
+
+// The colours of the series, which repeat once there are more series than colours
+var palette = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#9467bd", "#8c564b", "#e377c2", "#17becf", "#bcbd22"}
+
+// A chart of one resource of a container with a series per pod
+type chart struct {
+	Title   string
+	Series  []series
+	Lines   []line
+	Markers []marker
+	Format  func(value float64) string
+}
+
+type series struct {
+	Name   string
+	Points []point
+}
+
+type point struct {
+	Time  int64
+	Value float64
+}
+
+// A horizontal line across the chart, such as a request or limit
+type line struct {
+	Label string
+	Value float64
+}
+
+// A vertical line at the time of an event or annotation
+type marker struct {
+	Time  int64
+	Label string
+}
+
+// Returns the colour of the series at the given index
+func colour(index int) string {
+	return palette[index%len(palette)]
+}
+
+// Renders the chart as an inline SVG
+func (c *chart) SVG() template.HTML {
+
+	start, end := int64(math.MaxInt64), int64(math.MinInt64)
+	top := 0.0
+	for _, s := range c.Series {
+		for _, p := range s.Points {
+			start = min(start, p.Time)
+			end = max(end, p.Time)
+			top = max(top, p.Value)
+		}
+	}
+	for _, l := range c.Lines {
+		top = max(top, l.Value)
+	}
+
+	if start > end {
+		return template.HTML(`<p class="empty">No samples</p>`)
+	}
+	if start == end {
+		start, end = start-1, end+1
+	}
+	if top == 0 {
+		top = 1
+	}
+	top *= 1.1
+
+	plotWidth := float64(chartWidth - marginLeft - marginRight)
+	plotHeight := float64(chartHeight - marginTop - marginBottom)
+	x := func(t int64) float64 {
+		return marginLeft + float64(t-start)/float64(end-start)*plotWidth
+	}
+	y := func(v float64) float64 {
+		return marginTop + plotHeight - v/top*plotHeight
+	}
+
+	svg := &strings.Builder{}
+	fmt.Fprintf(svg, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" class="chart" role="img" aria-label="%s">`, chartWidth, chartHeight, html.EscapeString(c.Title))
+
+	// Gridlines and labels for the value axis
+	for i := 0; i <= 4; i++ {
+		value := top * float64(i) / 4
+		fmt.Fprintf(svg, `<line x1="%d" x2="%d" y1="%.1f" y2="%.1f" class="grid"/>`, marginLeft, chartWidth-marginRight, y(value), y(value))
+		fmt.Fprintf(svg, `<text x="%d" y="%.1f" class="axis" text-anchor="end">%s</text>`, marginLeft-6, y(value)+4, html.EscapeString(c.Format(value)))
+	}
+
+	// Labels for the time axis
+	for i := 0; i <= 4; i++ {
+		t := start + (end-start)*int64(i)/4
+		fmt.Fprintf(svg, `<text x="%.1f" y="%d" class="axis" text-anchor="middle">%s</text>`, x(t), chartHeight-8, time.Unix(t, 0).UTC().Format("15:04:05"))
+	}
+
+	for _, m := range c.Markers {
+		if m.Time < start || m.Time > end {
+			continue
+		}
+		fmt.Fprintf(svg, `<line x1="%.1f" x2="%.1f" y1="%d" y2="%d" class="marker"><title>%s</title></line>`, x(m.Time), x(m.Time), marginTop, chartHeight-marginBottom, html.EscapeString(m.Label))
+	}
+
+	for _, l := range c.Lines {
+		fmt.Fprintf(svg, `<line x1="%d" x2="%d" y1="%.1f" y2="%.1f" class="overlay"/>`, marginLeft, chartWidth-marginRight, y(l.Value), y(l.Value))
+		fmt.Fprintf(svg, `<text x="%d" y="%.1f" class="overlay-label" text-anchor="end">%s %s</text>`, chartWidth-marginRight, y(l.Value)-4, html.EscapeString(l.Label), html.EscapeString(c.Format(l.Value)))
+	}
+
+	for i, s := range c.Series {
+		points := []string{}
+		for _, p := range s.Points {
+			points = append(points, fmt.Sprintf("%.1f,%.1f", x(p.Time), y(p.Value)))
+		}
+		fmt.Fprintf(svg, `<polyline points="%s" fill="none" stroke="%s" stroke-width="1.5"><title>%s</title></polyline>`, strings.Join(points, " "), colour(i), html.EscapeString(s.Name))
+	}
+
+	svg.WriteString(`</svg>`)
+
+	return template.HTML(svg.String())
+}
@@ -0,0 +1,195 @@
+package report
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"pod_profiler/pkg/api/capture"
+	"pod_profiler/pkg/api/results"
+	"sort"
+	"time"
+)
+
+//go:embed report.html
+var reportTemplate string
+
+// The data the HTML template is rendered with
+type page struct {
+	Generated string
+	Source    string
+	Targets   []targetSection
+}
+
+type targetSection struct {
+	Name       string
+	Summaries  []results.ContainerSummary
+	Containers []containerSection
+	Events     []eventRow
+	Leaks      []results.Leak
+}
+
+type containerSection struct {
+	Name   string
+	Pods   []podLegend
+	Cpu    *chart
+	Memory *chart
+}
+
+type podLegend struct {
+	Name   string
+	Colour string
+}
+
+type eventRow struct {
+	Time    string
+	Pod     string
+	Type    string
+	Reason  string
+	Message string
+}
+
+var templateFunctions = template.FuncMap{
+	"cpu":    results.FormatCpu,
+	"memory": results.FormatMemory,
+	"cpuResource": func(value *int64) string {
+		return results.FormatResource(value, results.FormatCpu)
+	},
+	"memoryResource": func(value *int64) string {
+		return results.FormatResource(value, results.FormatMemory)
+	},
+	"growth": func(value float64) string {
+		return results.FormatMemory(int64(value))
+	},
+	"timeToLimit": results.FormatTimeToLimit,
+}
+
+// Writes the results as a single HTML file with the charts and styles inline, so it can be read without the frontend.
+// Only the containers with a summary are charted, and the leaks that are leaking are listed under their target
+func WriteHTML(writer io.Writer, read *results.Results, summaries []results.ContainerSummary, leaks []results.Leak) error {
+
+	tmpl, err := template.New("report").Funcs(templateFunctions).Parse(reportTemplate)
+	if err != nil {
+		return err
+	}
+
+	data := page{
+		Generated: time.Now().UTC().Format(time.RFC1123),
+		Source:    read.Path,
+	}
+
+	for _, target := range read.Targets {
+		section := targetSection{Name: target.Name}
+
+		for _, summary := range summaries {
+			if summary.Target == target.Name {
+				section.Summaries = append(section.Summaries, summary)
+			}
+		}
+
+		for _, leak := range results.Leaking(leaks) {
+			if leak.Target == target.Name {
+				section.Leaks = append(section.Leaks, leak)
+			}
+		}
+
+		markers := targetMarkers(target)
+		for _, summary := range section.Summaries {
+			section.Containers = append(section.Containers, containerCharts(target, summary, markers))
+		}
+
+		for _, pod := range target.Pods {
+			for _, event := range pod.Events {
+				section.Events = append(section.Events, eventRow{
+					Time:    time.Unix(event.DateStamp, 0).UTC().Format(time.DateTime),
+					Pod:     pod.Name,
+					Type:    string(event.Type),
+					Reason:  event.Reason,
+					Message: event.Message,
+				})
+			}
+		}
+		sort.SliceStable(section.Events, func(i, j int) bool {
+			return section.Events[i].Time < section.Events[j].Time
+		})
+
+		data.Targets = append(data.Targets, section)
+	}
+
+	return tmpl.Execute(writer, data)
+}
+
+// Returns a marker for each of the target's annotations and each of its pods' events other than phase changes,
+// which happen to every pod and would crowd out the rest
+func targetMarkers(target *results.Target) []marker {
+
+	markers := []marker{}
+	for _, annotation := range target.Annotations {
+		markers = append(markers, marker{annotation.DateStamp, fmt.Sprintf("%s: %s", annotation.Workload, annotation.Message)})
+	}
+
+	for _, pod := range target.Pods {
+		for _, event := range pod.Events {
+			if event.Type == capture.EventType_Phase {
+				continue
+			}
+			markers = append(markers, marker{event.DateStamp, fmt.Sprintf("%s %s: %s %s", pod.Name, event.Container, event.Reason, event.Message)})
+		}
+	}
+
+	return markers
+}
+
+// Returns the cpu and memory charts of a container, with a series for each pod and the container's requests and limits overlaid
+func containerCharts(target *results.Target, summary results.ContainerSummary, markers []marker) containerSection {
+
+	section := containerSection{
+		Name:   summary.Container,
+		Cpu:    &chart{Title: summary.Container + " cpu", Markers: markers, Format: formatCpu},
+		Memory: &chart{Title: summary.Container + " memory", Markers: markers, Format: formatMemory},
+	}
+
+	for _, pod := range target.Pods {
+		for _, container := range pod.Containers {
+			if container.Name != summary.Container || len(container.Samples) == 0 {
+				continue
+			}
+
+			cpu := series{Name: pod.Name}
+			memory := series{Name: pod.Name}
+			for _, sample := range container.Samples {
+				cpu.Points = append(cpu.Points, point{sample.DateStamp, float64(sample.Cpu)})
+				memory.Points = append(memory.Points, point{sample.DateStamp, float64(sample.Memory)})
+			}
+
+			section.Pods = append(section.Pods, podLegend{pod.Name, colour(len(section.Pods))})
+			section.Cpu.Series = append(section.Cpu.Series, cpu)
+			section.Memory.Series = append(section.Memory.Series, memory)
+		}
+	}
+
+	section.Cpu.Lines = overlays(summary.Requests.Cpu, summary.Limits.Cpu)
+	section.Memory.Lines = overlays(summary.Requests.Memory, summary.Limits.Memory)
+
+	return section
+}
+
+// Returns the lines for the request and limit that are set
+func overlays(request, limit *int64) []line {
+	lines := []line{}
+	if request != nil {
+		lines = append(lines, line{"request", float64(*request)})
+	}
+	if limit != nil {
+		lines = append(lines, line{"limit", float64(*limit)})
+	}
+	return lines
+}
+
+func formatCpu(value float64) string {
+	return results.FormatCpu(int64(value))
+}
+
+func formatMemory(value float64) string {
+	return results.FormatMemory(int64(value))
+}
@@ -0,0 +1,77 @@
+package results
+
+import (
+	"pod_profiler/pkg/api/analysis"
+	"pod_profiler/pkg/api/capture"
+	"time"
+)
+
+// Leak is the memory trend of a container in one pod, since each pod's container leaks on its own
+type Leak struct {
+	Target    string `json:"target"`
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	analysis.Trend
+}
+
+// Returns the memory trend of every container of every pod, using the pod's restart events to split the series
+func (results *Results) DetectLeaks(options analysis.LeakOptions) []Leak {
+
+	leaks := []Leak{}
+
+	for _, target := range results.Targets {
+		for _, pod := range target.Pods {
+			for _, container := range pod.Containers {
+				if len(container.Samples) == 0 {
+					continue
+				}
+
+				points := []analysis.Point{}
+				for _, sample := range container.Samples {
+					points = append(points, analysis.Point{Time: sample.DateStamp, Value: float64(sample.Memory)})
+				}
+
+				restarts := []int64{}
+				for _, event := range pod.Events {
+					if event.Container == container.Name && (event.Type == capture.EventType_Restart || event.Type == capture.EventType_Terminated) {
+						restarts = append(restarts, event.DateStamp)
+					}
+				}
+
+				var limit *float64
+				if container.Limits.Memory != nil {
+					value := float64(*container.Limits.Memory)
+					limit = &value
+				}
+
+				leaks = append(leaks, Leak{
+					Target:    target.Name,
+					Pod:       pod.Name,
+					Container: container.Name,
+					Trend:     analysis.DetectLeak(points, restarts, limit, options),
+				})
+			}
+		}
+	}
+
+	return leaks
+}
+
+// Returns only the trends that are leaking
+func Leaking(leaks []Leak) []Leak {
+	leaking := []Leak{}
+	for _, leak := range leaks {
+		if leak.Leaking {
+			leaking = append(leaking, leak)
+		}
+	}
+	return leaking
+}
+
+// Formats the time until the limit is reached, which is shown as a dash without a limit
+func FormatTimeToLimit(seconds *int64) string {
+	if seconds == nil {
+		return "-"
+	}
+	return (time.Duration(*seconds) * time.Second).Round(time.Minute).String()
+}
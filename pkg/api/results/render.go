@@ -0,0 +1,204 @@
+package results
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Writes the summaries as a table aligned for the terminal
+func WriteTable(writer io.Writer, summaries []ContainerSummary) error {
+
+	table := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "TARGET\tCONTAINER\tROLE\tPODS\tSAMPLES\tCPU MEAN\tCPU P95\tCPU PEAK\tCPU REQUEST\tCPU LIMIT\tMEMORY MEAN\tMEMORY P95\tMEMORY PEAK\tMEMORY REQUEST\tMEMORY LIMIT")
+
+	for _, summary := range summaries {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%d\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			summary.Target,
+			summary.Container,
+			summary.Role,
+			summary.Pods,
+			summary.Samples,
+			FormatCpu(summary.Cpu.Mean),
+			FormatCpu(summary.Cpu.P95),
+			FormatCpu(summary.Cpu.Peak),
+			FormatResource(summary.Requests.Cpu, FormatCpu),
+			FormatResource(summary.Limits.Cpu, FormatCpu),
+			FormatMemory(summary.Memory.Mean),
+			FormatMemory(summary.Memory.P95),
+			FormatMemory(summary.Memory.Peak),
+			FormatResource(summary.Requests.Memory, FormatMemory),
+			FormatResource(summary.Limits.Memory, FormatMemory),
+		)
+	}
+
+	return table.Flush()
+}
+
+// The version of the summary document's schema. Fields are only ever added within a version,
+// anything that would break existing readers requires a new version
+const SummarySchemaVersion = "pod-profiler/summary/v1"
+
+// SummaryDocument is the JSON summary archived by CI pipelines
+type SummaryDocument struct {
+	SchemaVersion string             `json:"schemaVersion"`
+	Generated     int64              `json:"generated"`
+	Source        string             `json:"source"`
+	Containers    []ContainerSummary `json:"containers"`
+	Leaks         []Leak             `json:"leaks"`
+}
+
+// Writes the summaries and the containers that are leaking memory as a versioned JSON document
+func WriteSummaryJSON(writer io.Writer, source string, summaries []ContainerSummary, leaks []Leak) error {
+
+	document := SummaryDocument{
+		SchemaVersion: SummarySchemaVersion,
+		Generated:     time.Now().Unix(),
+		Source:        source,
+		Containers:    summaries,
+		Leaks:         Leaking(leaks),
+	}
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(document)
+}
+
+// Writes the summaries as a Markdown table, which can be posted to merge requests. Requests are shown
+// with the share of them the 95th percentile uses, and limits with the share the peak uses. The containers
+// that are leaking memory follow in a second table
+func WriteMarkdown(writer io.Writer, summaries []ContainerSummary, leaks []Leak) error {
+
+	rows := []string{
+		"| Target | Container | Role | Samples | CPU mean | CPU p95 | CPU peak | CPU request | CPU limit | Memory mean | Memory p95 | Memory peak | Memory request | Memory limit |",
+		"| --- | --- | --- | ---: | ---: | ---: | ---: | ---: | ---: | ---: | ---: | ---: | ---: | ---: |",
+	}
+
+	compared := func(value *int64, utilisation *float64, format func(int64) string) string {
+		if value == nil {
+			return "-"
+		}
+		return fmt.Sprintf("%s (%s)", format(*value), FormatPercentage(utilisation))
+	}
+
+	for _, summary := range summaries {
+		rows = append(rows, fmt.Sprintf("| %s | %s | %s | %d | %s | %s | %s | %s | %s | %s | %s | %s | %s | %s |",
+			markdownEscape(summary.Target),
+			markdownEscape(summary.Container),
+			summary.Role,
+			summary.Samples,
+			FormatCpu(summary.Cpu.Mean),
+			FormatCpu(summary.Cpu.P95),
+			FormatCpu(summary.Cpu.Peak),
+			compared(summary.Requests.Cpu, summary.Utilisation.CpuRequest, FormatCpu),
+			compared(summary.Limits.Cpu, summary.Utilisation.CpuLimit, FormatCpu),
+			FormatMemory(summary.Memory.Mean),
+			FormatMemory(summary.Memory.P95),
+			FormatMemory(summary.Memory.Peak),
+			compared(summary.Requests.Memory, summary.Utilisation.MemoryRequest, FormatMemory),
+			compared(summary.Limits.Memory, summary.Utilisation.MemoryLimit, FormatMemory),
+		))
+	}
+
+	if leaking := Leaking(leaks); len(leaking) > 0 {
+		rows = append(rows,
+			"",
+			"### Memory leaks",
+			"",
+			"| Target | Pod | Container | Growth per hour | Confidence | Time to limit |",
+			"| --- | --- | --- | ---: | ---: | ---: |",
+		)
+		for _, leak := range leaking {
+			rows = append(rows, fmt.Sprintf("| %s | %s | %s | %s | %.2f | %s |",
+				markdownEscape(leak.Target),
+				markdownEscape(leak.Pod),
+				markdownEscape(leak.Container),
+				FormatMemory(int64(leak.GrowthPerHour)),
+				leak.Confidence,
+				FormatTimeToLimit(leak.TimeToLimit),
+			))
+		}
+	}
+
+	_, err := io.WriteString(writer, strings.Join(rows, "\n")+"\n")
+	return err
+}
+
+// Writes the containers that are leaking memory as a table aligned for the terminal, nothing is written if none are
+func WriteLeakTable(writer io.Writer, leaks []Leak) error {
+
+	leaking := Leaking(leaks)
+	if len(leaking) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(writer, "\nMemory leaks:")
+	table := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "TARGET\tPOD\tCONTAINER\tGROWTH PER HOUR\tCONFIDENCE\tTIME TO LIMIT")
+
+	for _, leak := range leaking {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%.2f\t%s\n",
+			leak.Target,
+			leak.Pod,
+			leak.Container,
+			FormatMemory(int64(leak.GrowthPerHour)),
+			leak.Confidence,
+			FormatTimeToLimit(leak.TimeToLimit),
+		)
+	}
+
+	return table.Flush()
+}
+
+// Escapes the characters that would break a Markdown table cell
+func markdownEscape(value string) string {
+	return strings.NewReplacer("|", "\\|", "*", "\\*", "_", "\\_").Replace(value)
+}
+
+// Writes the results as a single JSON document, which Read accepts in place of a results directory
+func WriteJSON(writer io.Writer, results *Results) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(results)
+}
+
+// Writes a CSV row for each sample of each container
+func WriteCSV(writer io.Writer, results *Results) error {
+
+	csvWriter := csv.NewWriter(writer)
+
+	err := csvWriter.Write([]string{"target", "pod", "container", "role", "time", "cpu", "memory", "revision"})
+	if err != nil {
+		return err
+	}
+
+	for _, target := range results.Targets {
+		for _, pod := range target.Pods {
+			for _, container := range pod.Containers {
+				for _, sample := range container.Samples {
+					err := csvWriter.Write([]string{
+						target.Name,
+						pod.Name,
+						container.Name,
+						string(container.Role),
+						strconv.FormatInt(sample.DateStamp, 10),
+						strconv.FormatInt(sample.Cpu, 10),
+						strconv.FormatInt(sample.Memory, 10),
+						sample.Revision,
+					})
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
@@ -0,0 +1,429 @@
+package results
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"pod_profiler/pkg/api/capture"
+	"pod_profiler/pkg/api/defaults"
+	"pod_profiler/pkg/api/rollup"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Results are the files the gatherer wrote to a results directory, grouped by the target they were captured for
+type Results struct {
+	Path    string    `json:"path"`
+	Targets []*Target `json:"targets"`
+}
+
+// Target is a deployment or job that was profiled
+type Target struct {
+	Name        string               `json:"name"`
+	Pods        []*Pod               `json:"pods"`
+	Annotations []capture.Annotation `json:"annotations,omitempty"`
+}
+
+type Pod struct {
+	Name       string          `json:"name"`
+	Containers []*Container    `json:"containers"`
+	Events     []capture.Event `json:"events,omitempty"`
+}
+
+type Container struct {
+	Name     string                `json:"name"`
+	Role     capture.ContainerRole `json:"role"`
+	Image    string                `json:"image"`
+	Requests Resources             `json:"requests"`
+	Limits   Resources             `json:"limits"`
+	Samples  []Sample              `json:"samples"`
+}
+
+// Resources are the cpu in millicores and the memory in bytes, either is nil if it was not set
+type Resources struct {
+	Cpu    *int64 `json:"cpu,omitempty"`
+	Memory *int64 `json:"memory,omitempty"`
+}
+
+// Sample is the usage of a container at a point in time, the cpu is in millicores and the memory in bytes
+type Sample struct {
+	DateStamp int64  `json:"datestamp"`
+	Cpu       int64  `json:"cpu"`
+	Memory    int64  `json:"memory"`
+	Revision  string `json:"revision,omitempty"`
+}
+
+// The name of the target that pods are grouped under when the results don't say which target they belong to,
+// such as results gathered by older versions
+const UnknownTarget = "unknown"
+
+// Returns the results directory followed by the directory of each shard, which sharded gatherers write to instead
+func Directories(path string) ([]string, error) {
+
+	directories := []string{path}
+
+	entries, err := os.ReadDir(filepath.Join(path, defaults.SHARDS_DIRECTORY))
+	if os.IsNotExist(err) {
+		return directories, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			directories = append(directories, filepath.Join(path, defaults.SHARDS_DIRECTORY, entry.Name()))
+		}
+	}
+
+	return directories, nil
+}
+
+// Reads the results directory written by the gatherer, or a results document written by WriteJSON. The results of
+// every shard are merged, as a pod's files are split between shards when it moves from one to another
+func Read(path string) (*Results, error) {
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return readDocument(path)
+	}
+
+	directories, err := Directories(path)
+	if err != nil {
+		return nil, err
+	}
+
+	results := &Results{Path: path}
+	targets := map[string]*Target{}
+	pods := map[string]*Pod{}
+
+	// Read the usage files last, so the roles and images they record take precedence over the pods files
+	usageFiles := []string{}
+	for _, directory := range directories {
+		entries, err := os.ReadDir(directory)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() {
+				continue
+			}
+
+			filename := filepath.Join(directory, name)
+			switch {
+			case strings.HasSuffix(name, capture.FileSuffix_Pods):
+				err = readPods(filename, target(targets, strings.TrimSuffix(name, capture.FileSuffix_Pods)), pods)
+			case strings.HasSuffix(name, capture.FileSuffix_Annotations):
+				err = readAnnotations(filename, target(targets, strings.TrimSuffix(name, capture.FileSuffix_Annotations)))
+			case strings.HasSuffix(name, capture.FileSuffix_Events):
+				err = readEvents(filename, pod(pods, strings.TrimSuffix(name, capture.FileSuffix_Events)))
+			case strings.HasSuffix(name, capture.FileSuffix_Usage) && !otherResults(name):
+				usageFiles = append(usageFiles, filename)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", name, err.Error())
+			}
+		}
+	}
+
+	for _, filename := range usageFiles {
+		name := filepath.Base(filename)
+		err = readUsage(filename, pod(pods, strings.TrimSuffix(name, capture.FileSuffix_Usage)))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err.Error())
+		}
+	}
+
+	// The files of a pod that moved between shards are split between them, so put its samples and events back in order
+	if len(directories) > 1 {
+		for _, current := range pods {
+			for _, container := range current.Containers {
+				sort.SliceStable(container.Samples, func(i, j int) bool {
+					return container.Samples[i].DateStamp < container.Samples[j].DateStamp
+				})
+			}
+			sort.SliceStable(current.Events, func(i, j int) bool {
+				return current.Events[i].DateStamp < current.Events[j].DateStamp
+			})
+		}
+	}
+
+	// Pods that aren't listed by any target are grouped together rather than dropped
+	listed := map[string]bool{}
+	for _, target := range targets {
+		for _, pod := range target.Pods {
+			listed[pod.Name] = true
+		}
+	}
+	for name, pod := range pods {
+		if !listed[name] && len(pod.Containers) > 0 {
+			unknown := target(targets, UnknownTarget)
+			unknown.Pods = append(unknown.Pods, pod)
+		}
+	}
+
+	for _, target := range targets {
+		if len(target.Pods) == 0 && len(target.Annotations) == 0 {
+			continue
+		}
+		sort.Slice(target.Pods, func(i, j int) bool {
+			return target.Pods[i].Name < target.Pods[j].Name
+		})
+		results.Targets = append(results.Targets, target)
+	}
+	sort.Slice(results.Targets, func(i, j int) bool {
+		return results.Targets[i].Name < results.Targets[j].Name
+	})
+
+	return results, nil
+}
+
+func readDocument(filename string) (*Results, error) {
+
+	if filepath.Ext(filename) != ".json" {
+		return nil, fmt.Errorf("%s is neither a results directory nor a JSON results document", filename)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	results := &Results{}
+	if err := json.Unmarshal(data, results); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err.Error())
+	}
+
+	return results, nil
+}
+
+// The suffixes of the results files that also end with the usage file suffix
+var otherSuffixes = []string{
+	capture.FileSuffix_Kubelet,
+	capture.FileSuffix_Network,
+	capture.FileSuffix_Volumes,
+	capture.FileSuffix_Throttling,
+	capture.FileSuffix_Events,
+	capture.FileSuffix_Annotations,
+	capture.FileSuffix_HPA,
+	capture.FileSuffix_Pods,
+}
+
+// Returns true if the file is one of the results files other than a usage file. Pod names can contain
+// dots, so we can't tell usage files apart by the number of dots in the name
+func otherResults(filename string) bool {
+	for _, tier := range rollup.Tiers {
+		if strings.HasSuffix(filename, tier.Suffix) {
+			return true
+		}
+	}
+	for _, suffix := range otherSuffixes {
+		if strings.HasSuffix(filename, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the target with the given name, adding it if it hasn't been seen yet
+func target(targets map[string]*Target, name string) *Target {
+	if existing, exists := targets[name]; exists {
+		return existing
+	}
+	targets[name] = &Target{Name: name}
+	return targets[name]
+}
+
+// Returns the pod with the given name, adding it if it hasn't been seen yet
+func pod(pods map[string]*Pod, name string) *Pod {
+	if existing, exists := pods[name]; exists {
+		return existing
+	}
+	pods[name] = &Pod{Name: name}
+	return pods[name]
+}
+
+// Returns the container with the given name, adding it to the pod if it hasn't been seen yet
+func (pod *Pod) container(name string) *Container {
+	for _, container := range pod.Containers {
+		if container.Name == name {
+			return container
+		}
+	}
+	container := &Container{Name: name}
+	pod.Containers = append(pod.Containers, container)
+	return container
+}
+
+// Reads the rows of a results file as maps keyed by the header, so files written before a column was added can still be read
+func readRows(filename string) ([]map[string]string, error) {
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []map[string]string{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		row := map[string]string{}
+		for i, value := range record {
+			if i < len(header) {
+				row[header[i]] = value
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func readPods(filename string, target *Target, pods map[string]*Pod) error {
+
+	rows, err := readRows(filename)
+	if err != nil {
+		return err
+	}
+
+	listed := map[string]bool{}
+	for _, listedPod := range target.Pods {
+		listed[listedPod.Name] = true
+	}
+
+	for _, row := range rows {
+		current := pod(pods, row["pod"])
+		if !listed[current.Name] {
+			listed[current.Name] = true
+			target.Pods = append(target.Pods, current)
+		}
+
+		// The pod is listed again each time the gatherer restarts, the latest row wins
+		container := current.container(row["container"])
+		container.Role = capture.ContainerRole(row["role"])
+		container.Requests = Resources{Cpu: optionalInt(row["cpurequest"]), Memory: optionalInt(row["memoryrequest"])}
+		container.Limits = Resources{Cpu: optionalInt(row["cpulimit"]), Memory: optionalInt(row["memorylimit"])}
+	}
+
+	return nil
+}
+
+func readUsage(filename string, pod *Pod) error {
+
+	rows, err := readRows(filename)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		container := pod.container(row["name"])
+
+		sample := Sample{Revision: row["revision"]}
+		if sample.DateStamp, err = strconv.ParseInt(row["time"], 10, 64); err != nil {
+			return err
+		}
+		if sample.Cpu, err = strconv.ParseInt(row["cpu"], 10, 64); err != nil {
+			return err
+		}
+		if sample.Memory, err = strconv.ParseInt(row["memory"], 10, 64); err != nil {
+			return err
+		}
+		container.Samples = append(container.Samples, sample)
+
+		if row["role"] != "" {
+			container.Role = capture.ContainerRole(row["role"])
+		}
+		if row["image"] != "" {
+			container.Image = row["image"]
+		}
+	}
+
+	return nil
+}
+
+func readEvents(filename string, pod *Pod) error {
+
+	rows, err := readRows(filename)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		event := capture.Event{
+			Pod:       pod.Name,
+			Container: row["name"],
+			Type:      capture.EventType(row["type"]),
+			Reason:    row["reason"],
+			Message:   row["message"],
+		}
+		if event.DateStamp, err = strconv.ParseInt(row["time"], 10, 64); err != nil {
+			return err
+		}
+		if exitCode := optionalInt(row["exitcode"]); exitCode != nil {
+			code := int32(*exitCode)
+			event.ExitCode = &code
+		}
+		pod.Events = append(pod.Events, event)
+	}
+
+	return nil
+}
+
+func readAnnotations(filename string, target *Target) error {
+
+	rows, err := readRows(filename)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		annotation := capture.Annotation{
+			Workload: row["workload"],
+			Type:     capture.AnnotationType(row["type"]),
+			Revision: row["revision"],
+			Message:  row["message"],
+		}
+		if annotation.DateStamp, err = strconv.ParseInt(row["time"], 10, 64); err != nil {
+			return err
+		}
+		target.Annotations = append(target.Annotations, annotation)
+	}
+
+	return nil
+}
+
+// Returns nil for a blank value or one that isn't a number
+func optionalInt(value string) *int64 {
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &parsed
+}
@@ -0,0 +1,205 @@
+package results
+
+import (
+	"fmt"
+	"math"
+	"pod_profiler/pkg/api/analysis"
+	"pod_profiler/pkg/api/capture"
+	"sort"
+)
+
+// ContainerSummary is the usage of a container across all of the pods of a target
+type ContainerSummary struct {
+	Target    string                `json:"target"`
+	Container string                `json:"container"`
+	Role      capture.ContainerRole `json:"role"`
+	Pods      int                   `json:"pods"`
+	Samples   int                   `json:"samples"`
+	Cpu       Usage                 `json:"cpu"`
+	Memory    Usage                 `json:"memory"`
+	Requests  Resources             `json:"requests"`
+	Limits    Resources             `json:"limits"`
+
+	// How much of the requests and limits the container used
+	Utilisation Utilisation `json:"utilisation"`
+}
+
+// Utilisation compares the usage with the requests and limits. Requests are compared with the 95th percentile,
+// since that is what they should cover, and limits with the peak. Each is nil if the request or limit isn't set
+type Utilisation struct {
+	CpuRequest    *float64 `json:"cpuRequest,omitempty"`
+	CpuLimit      *float64 `json:"cpuLimit,omitempty"`
+	MemoryRequest *float64 `json:"memoryRequest,omitempty"`
+	MemoryLimit   *float64 `json:"memoryLimit,omitempty"`
+}
+
+// Usage summarises the samples of a single resource
+type Usage struct {
+	Mean int64 `json:"mean"`
+	P95  int64 `json:"p95"`
+	Peak int64 `json:"peak"`
+}
+
+// Returns the summary of each container of each target, ordered by target and then container.
+// Sidecars are left out unless includeSidecars is set
+func (results *Results) Summarise(includeSidecars bool) []ContainerSummary {
+
+	summaries := []ContainerSummary{}
+
+	for _, target := range results.Targets {
+
+		byName := map[string]*ContainerSummary{}
+		usage := analysis.NewRecordAggregator(true)
+		names := []string{}
+
+		for _, pod := range target.Pods {
+			for _, container := range pod.Containers {
+				if container.Role == capture.ContainerRole_Sidecar && !includeSidecars {
+					continue
+				}
+
+				summary, exists := byName[container.Name]
+				if !exists {
+					summary = &ContainerSummary{Target: target.Name, Container: container.Name}
+					byName[container.Name] = summary
+					names = append(names, container.Name)
+				}
+
+				if container.Role != "" {
+					summary.Role = container.Role
+				}
+				if summary.Requests == (Resources{}) {
+					summary.Requests = container.Requests
+				}
+				if summary.Limits == (Resources{}) {
+					summary.Limits = container.Limits
+				}
+
+				if len(container.Samples) > 0 {
+					summary.Pods++
+				}
+				for _, sample := range container.Samples {
+					usage.AddSample(sample.DateStamp, container.Name, container.Role, sample.Cpu, sample.Memory)
+				}
+			}
+		}
+
+		sort.Strings(names)
+		for _, name := range names {
+			summary := byName[name]
+			if container := usage.Container(name); container != nil {
+				summary.Samples = container.Cpu.Count()
+				summary.Cpu = summariseUsage(container.Cpu)
+				summary.Memory = summariseUsage(container.Memory)
+			}
+			summary.Utilisation = Utilisation{
+				CpuRequest:    fraction(summary.Cpu.P95, summary.Requests.Cpu),
+				CpuLimit:      fraction(summary.Cpu.Peak, summary.Limits.Cpu),
+				MemoryRequest: fraction(summary.Memory.P95, summary.Requests.Memory),
+				MemoryLimit:   fraction(summary.Memory.Peak, summary.Limits.Memory),
+			}
+			summaries = append(summaries, *summary)
+		}
+	}
+
+	return summaries
+}
+
+// Rounds the summary of a series of millicores or bytes back to whole units
+func summariseUsage(summary *analysis.Summary) Usage {
+	return Usage{
+		Mean: int64(math.Round(summary.Mean())),
+		P95:  int64(summary.Percentile(95)),
+		Peak: int64(summary.Max()),
+	}
+}
+
+// Returns the usage as a fraction of the request or limit, or nil if it isn't set
+func fraction(usage int64, of *int64) *float64 {
+	if of == nil || *of == 0 {
+		return nil
+	}
+	value := float64(usage) / float64(*of)
+	return &value
+}
+
+// Recommendation is the requests and limits suggested for a container from its usage
+type Recommendation struct {
+	ContainerSummary
+	RecommendedRequests Resources `json:"recommendedRequests"`
+	RecommendedLimits   Resources `json:"recommendedLimits"`
+}
+
+// The smallest cpu request we recommend, in millicores
+const minimumCpu int64 = 10
+
+// The granularity recommendations are rounded up to, in millicores and bytes
+const (
+	cpuStep    int64 = 5
+	memoryStep int64 = 1024 * 1024
+)
+
+// Returns a recommendation for each summary that has samples. Requests cover the 95th percentile and limits
+// cover the peak, both with the given headroom added, such as 0.2 for 20%
+func Recommend(summaries []ContainerSummary, headroom float64) []Recommendation {
+
+	recommendations := []Recommendation{}
+
+	for _, summary := range summaries {
+		if summary.Samples == 0 {
+			continue
+		}
+
+		cpuRequest := max(roundUp(summary.Cpu.P95, headroom, cpuStep), minimumCpu)
+		cpuLimit := max(roundUp(summary.Cpu.Peak, headroom, cpuStep), cpuRequest)
+		memoryRequest := roundUp(summary.Memory.P95, headroom, memoryStep)
+		memoryLimit := max(roundUp(summary.Memory.Peak, headroom, memoryStep), memoryRequest)
+
+		recommendations = append(recommendations, Recommendation{
+			ContainerSummary:    summary,
+			RecommendedRequests: Resources{Cpu: &cpuRequest, Memory: &memoryRequest},
+			RecommendedLimits:   Resources{Cpu: &cpuLimit, Memory: &memoryLimit},
+		})
+	}
+
+	return recommendations
+}
+
+// Adds the headroom to the value and rounds it up to a multiple of step
+func roundUp(value int64, headroom float64, step int64) int64 {
+	withHeadroom := int64(math.Ceil(float64(value) * (1 + headroom)))
+	if withHeadroom <= 0 {
+		return step
+	}
+	return ((withHeadroom + step - 1) / step) * step
+}
+
+// Formats millicores the way kubernetes quantities are written, such as 250m
+func FormatCpu(millicores int64) string {
+	return fmt.Sprintf("%dm", millicores)
+}
+
+// Formats bytes in mebibytes, such as 128Mi
+func FormatMemory(bytes int64) string {
+	mebibytes := float64(bytes) / float64(memoryStep)
+	if mebibytes == math.Trunc(mebibytes) {
+		return fmt.Sprintf("%dMi", int64(mebibytes))
+	}
+	return fmt.Sprintf("%.1fMi", mebibytes)
+}
+
+// Formats a fraction as a percentage, which is shown as a dash when there is nothing to compare with
+func FormatPercentage(value *float64) string {
+	if value == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", *value*100)
+}
+
+// Formats a request or limit, which is shown as a dash when it isn't set
+func FormatResource(value *int64, format func(int64) string) string {
+	if value == nil {
+		return "-"
+	}
+	return format(*value)
+}
@@ -0,0 +1,413 @@
+package rollup
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Tier is a resolution the usage is aggregated to once it is older than the raw retention
+type Tier struct {
+	Name     string
+	Duration time.Duration
+
+	// The suffix of each pod's file for the tier, alongside its raw usage file
+	Suffix string
+}
+
+var (
+	Tier_Minute      = Tier{"1m", time.Minute, ".1m.csv"}
+	Tier_FiveMinutes = Tier{"5m", 5 * time.Minute, ".5m.csv"}
+	Tier_Hour        = Tier{"1h", time.Hour, ".1h.csv"}
+)
+
+// The tiers from the finest to the coarsest
+var Tiers = []Tier{Tier_Minute, Tier_FiveMinutes, Tier_Hour}
+
+// The name used for the raw samples when choosing a tier
+const RawTier = "raw"
+
+// The header of the tier files
+var Header = []string{"time", "name", "samples", "cpumin", "cpumax", "cpuavg", "cpup95", "memorymin", "memorymax", "memoryavg", "memoryp95"}
+
+// Bucket is the usage of a container over one interval of a tier, the time is the start of the interval
+type Bucket struct {
+	DateStamp int64  `json:"datestamp"`
+	Container string `json:"container"`
+	Samples   int    `json:"samples"`
+	Cpu       Stats  `json:"cpu"`
+	Memory    Stats  `json:"memory"`
+}
+
+// Stats summarise the samples of a resource within a bucket
+type Stats struct {
+	Min int64 `json:"min"`
+	Max int64 `json:"max"`
+	Avg int64 `json:"avg"`
+	P95 int64 `json:"p95"`
+}
+
+// Aggregator collects the samples of a pod's containers into the buckets of a tier. A bucket that was flushed before
+// it ended, such as by a gatherer that stopped, is continued by another row with the same start, and the rows are
+// combined with Merge when they are read
+type Aggregator struct {
+	tier Tier
+
+	// The bucket that is currently open for each container
+	open map[string]*openBucket
+}
+
+type openBucket struct {
+	start  int64
+	cpu    []int64
+	memory []int64
+}
+
+// Create an aggregator for the given tier
+func NewAggregator(tier Tier) *Aggregator {
+	return &Aggregator{tier: tier, open: map[string]*openBucket{}}
+}
+
+// Adds a sample and returns the container's previous bucket once the sample falls after it
+func (aggregator *Aggregator) Add(timestamp int64, container string, cpu, memory int64) []Bucket {
+
+	start := timestamp - timestamp%int64(aggregator.tier.Duration.Seconds())
+	closed := []Bucket{}
+
+	current, exists := aggregator.open[container]
+	if exists && current.start != start {
+		closed = append(closed, current.bucket(container))
+		exists = false
+	}
+	if !exists {
+		current = &openBucket{start: start}
+		aggregator.open[container] = current
+	}
+
+	current.cpu = append(current.cpu, cpu)
+	current.memory = append(current.memory, memory)
+
+	return closed
+}
+
+// Closes and returns every open bucket, such as when the pod has stopped
+func (aggregator *Aggregator) Flush() []Bucket {
+
+	closed := []Bucket{}
+	for container, open := range aggregator.open {
+		closed = append(closed, open.bucket(container))
+	}
+	aggregator.open = map[string]*openBucket{}
+
+	sort.Slice(closed, func(i, j int) bool {
+		return closed[i].Container < closed[j].Container
+	})
+
+	return closed
+}
+
+func (open *openBucket) bucket(container string) Bucket {
+	return Bucket{
+		DateStamp: open.start,
+		Container: container,
+		Samples:   len(open.cpu),
+		Cpu:       stats(open.cpu),
+		Memory:    stats(open.memory),
+	}
+}
+
+func stats(values []int64) Stats {
+
+	sorted := append([]int64{}, values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total float64
+	for _, value := range sorted {
+		total += float64(value)
+	}
+
+	// Nearest rank, so the percentile is always one of the samples
+	rank := int(math.Ceil(0.95*float64(len(sorted)))) - 1
+
+	return Stats{
+		Min: sorted[0],
+		Max: sorted[len(sorted)-1],
+		Avg: int64(math.Round(total / float64(len(sorted)))),
+		P95: sorted[rank],
+	}
+}
+
+// Returns the bucket as a row of a tier file
+func (bucket Bucket) Row() []string {
+	format := func(value int64) string {
+		return strconv.FormatInt(value, 10)
+	}
+	return []string{
+		format(bucket.DateStamp),
+		bucket.Container,
+		strconv.Itoa(bucket.Samples),
+		format(bucket.Cpu.Min),
+		format(bucket.Cpu.Max),
+		format(bucket.Cpu.Avg),
+		format(bucket.Cpu.P95),
+		format(bucket.Memory.Min),
+		format(bucket.Memory.Max),
+		format(bucket.Memory.Avg),
+		format(bucket.Memory.P95),
+	}
+}
+
+// Returns the tier the API should serve for a range, which is the finest one that keeps the range within maxPoints per
+// container. Raw samples are only served while the whole range is still within the raw retention, zero keeps them forever
+func SelectTier(from, to, now time.Time, rawInterval, rawRetention time.Duration, maxPoints int) string {
+
+	span := to.Sub(from)
+
+	rawAvailable := rawRetention == 0 || from.After(now.Add(-rawRetention))
+	if rawAvailable && span/rawInterval <= time.Duration(maxPoints) {
+		return RawTier
+	}
+
+	for _, tier := range Tiers {
+		if span/tier.Duration <= time.Duration(maxPoints) {
+			return tier.Name
+		}
+	}
+
+	return Tiers[len(Tiers)-1].Name
+}
+
+// Returns the tier with the given name
+func TierByName(name string) (Tier, bool) {
+	for _, tier := range Tiers {
+		if tier.Name == name {
+			return tier, true
+		}
+	}
+	return Tier{}, false
+}
+
+// Reads the buckets of a tier file, or the samples of a raw usage file as buckets of a single sample,
+// that fall within the range
+func ReadBuckets(filename string, raw bool, from, to int64) ([]Bucket, error) {
+
+	buckets := []Bucket{}
+
+	err := readRows(filename, func(row map[string]string) error {
+		timestamp, err := strconv.ParseInt(row["time"], 10, 64)
+		if err != nil {
+			return err
+		}
+		if timestamp < from || timestamp > to {
+			return nil
+		}
+
+		bucket := Bucket{DateStamp: timestamp, Container: row["name"]}
+
+		if raw {
+			cpu, err := strconv.ParseInt(row["cpu"], 10, 64)
+			if err != nil {
+				return err
+			}
+			memory, err := strconv.ParseInt(row["memory"], 10, 64)
+			if err != nil {
+				return err
+			}
+			bucket.Samples = 1
+			bucket.Cpu = Stats{cpu, cpu, cpu, cpu}
+			bucket.Memory = Stats{memory, memory, memory, memory}
+		} else {
+			values := []*int64{&bucket.Cpu.Min, &bucket.Cpu.Max, &bucket.Cpu.Avg, &bucket.Cpu.P95, &bucket.Memory.Min, &bucket.Memory.Max, &bucket.Memory.Avg, &bucket.Memory.P95}
+			for i, column := range Header[3:] {
+				if *values[i], err = strconv.ParseInt(row[column], 10, 64); err != nil {
+					return err
+				}
+			}
+			if bucket.Samples, err = strconv.Atoi(row["samples"]); err != nil {
+				return err
+			}
+		}
+
+		buckets = append(buckets, bucket)
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return buckets, nil
+	}
+
+	return buckets, err
+}
+
+// Returns the buckets in order, combining the buckets of a container that start at the same time, such as a bucket
+// that was continued after a gatherer stopped or the partial buckets written by two shards when a pod moves between
+// them. The samples themselves aren't kept, so the p95 of a combined bucket is the larger of the two
+func Merge(buckets []Bucket) []Bucket {
+
+	sort.SliceStable(buckets, func(i, j int) bool {
+		if buckets[i].DateStamp == buckets[j].DateStamp {
+			return buckets[i].Container < buckets[j].Container
+		}
+		return buckets[i].DateStamp < buckets[j].DateStamp
+	})
+
+	// The averages are weighted by the samples of each row and only rounded once every row has been added, so
+	// merging several rows doesn't compound the rounding
+	type totals struct {
+		cpu, memory float64
+	}
+
+	merged := []Bucket{}
+	sums := []totals{}
+	for _, bucket := range buckets {
+		sum := totals{float64(bucket.Cpu.Avg) * float64(bucket.Samples), float64(bucket.Memory.Avg) * float64(bucket.Samples)}
+
+		last := len(merged) - 1
+		if last < 0 || merged[last].DateStamp != bucket.DateStamp || merged[last].Container != bucket.Container {
+			merged = append(merged, bucket)
+			sums = append(sums, sum)
+			continue
+		}
+
+		merged[last].Cpu = merged[last].Cpu.merge(bucket.Cpu)
+		merged[last].Memory = merged[last].Memory.merge(bucket.Memory)
+		merged[last].Samples += bucket.Samples
+		sums[last].cpu += sum.cpu
+		sums[last].memory += sum.memory
+
+		if merged[last].Samples > 0 {
+			merged[last].Cpu.Avg = int64(math.Round(sums[last].cpu / float64(merged[last].Samples)))
+			merged[last].Memory.Avg = int64(math.Round(sums[last].memory / float64(merged[last].Samples)))
+		}
+	}
+
+	return merged
+}
+
+// Returns the extremes and p95 of the samples of both, the average is left to the caller
+func (stat Stats) merge(other Stats) Stats {
+	return Stats{
+		Min: min(stat.Min, other.Min),
+		Max: max(stat.Max, other.Max),
+		Avg: stat.Avg,
+		P95: max(stat.P95, other.P95),
+	}
+}
+
+// Rewrites a raw usage file without the samples from before the cutoff. The rows are streamed to the new file as
+// they are read, so the file is never loaded at once
+func Prune(filename string, cutoff int64) error {
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Files created with an older header have fewer columns than the rows written since
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Write the kept samples next to the file and swap it in, so the file is never left half written
+	temporary := filename + ".tmp"
+	output, err := os.Create(temporary)
+	if err != nil {
+		return err
+	}
+
+	pruned, err := pruneRows(reader, csv.NewWriter(output), header, cutoff)
+	if closeErr := output.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || pruned == 0 {
+		os.Remove(temporary)
+		return err
+	}
+
+	return os.Rename(temporary, filename)
+}
+
+// Copies the header and the rows from the cutoff onwards to the writer and returns the number of rows left out
+func pruneRows(reader *csv.Reader, writer *csv.Writer, header []string, cutoff int64) (int, error) {
+
+	if err := writer.Write(header); err != nil {
+		return 0, err
+	}
+
+	pruned := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return pruned, err
+		}
+
+		timestamp, err := strconv.ParseInt(record[0], 10, 64)
+		if err == nil && timestamp < cutoff {
+			pruned++
+			continue
+		}
+		if err := writer.Write(record); err != nil {
+			return pruned, err
+		}
+	}
+
+	writer.Flush()
+	return pruned, writer.Error()
+}
+
+// Calls onRow with each row of the CSV file as a map keyed by the header
+func readRows(filename string, onRow func(row map[string]string) error) error {
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		row := map[string]string{}
+		for i, value := range record {
+			if i < len(header) {
+				row[header[i]] = value
+			}
+		}
+
+		if err := onRow(row); err != nil {
+			return err
+		}
+	}
+}
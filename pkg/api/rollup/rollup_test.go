@@ -0,0 +1,287 @@
+package rollup
+
+import (
+	"encoding/csv"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type sample struct {
+	timestamp   int64
+	container   string
+	cpu, memory int64
+}
+
+func TestAggregator(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		tier    Tier
+		samples []sample
+		closed  []Bucket
+		flushed []Bucket
+	}{
+		{
+			name:    "empty",
+			tier:    Tier_Minute,
+			closed:  []Bucket{},
+			flushed: []Bucket{},
+		},
+		{
+			name:    "open bucket",
+			tier:    Tier_Minute,
+			samples: []sample{{60, "api", 1, 10}, {119, "api", 2, 20}},
+			closed:  []Bucket{},
+			flushed: []Bucket{
+				// The average rounds half away from zero and the p95 is the nearest rank
+				{DateStamp: 60, Container: "api", Samples: 2, Cpu: Stats{1, 2, 2, 2}, Memory: Stats{10, 20, 15, 20}},
+			},
+		},
+		{
+			name:    "bucket closed by the next",
+			tier:    Tier_Minute,
+			samples: []sample{{60, "api", 10, 100}, {70, "api", 30, 300}, {65, "proxy", 5, 50}, {125, "api", 50, 500}},
+			closed: []Bucket{
+				{DateStamp: 60, Container: "api", Samples: 2, Cpu: Stats{10, 30, 20, 30}, Memory: Stats{100, 300, 200, 300}},
+			},
+			flushed: []Bucket{
+				{DateStamp: 120, Container: "api", Samples: 1, Cpu: Stats{50, 50, 50, 50}, Memory: Stats{500, 500, 500, 500}},
+				{DateStamp: 60, Container: "proxy", Samples: 1, Cpu: Stats{5, 5, 5, 5}, Memory: Stats{50, 50, 50, 50}},
+			},
+		},
+		{
+			name:    "five minutes",
+			tier:    Tier_FiveMinutes,
+			samples: []sample{{300, "api", 1, 1}, {599, "api", 3, 3}, {600, "api", 5, 5}},
+			closed: []Bucket{
+				{DateStamp: 300, Container: "api", Samples: 2, Cpu: Stats{1, 3, 2, 3}, Memory: Stats{1, 3, 2, 3}},
+			},
+			flushed: []Bucket{
+				{DateStamp: 600, Container: "api", Samples: 1, Cpu: Stats{5, 5, 5, 5}, Memory: Stats{5, 5, 5, 5}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		aggregator := NewAggregator(test.tier)
+
+		closed := []Bucket{}
+		for _, sample := range test.samples {
+			closed = append(closed, aggregator.Add(sample.timestamp, sample.container, sample.cpu, sample.memory)...)
+		}
+
+		if !reflect.DeepEqual(closed, test.closed) {
+			t.Errorf("%s: expected closed %+v, got %+v", test.name, test.closed, closed)
+		}
+		if flushed := aggregator.Flush(); !reflect.DeepEqual(flushed, test.flushed) {
+			t.Errorf("%s: expected flushed %+v, got %+v", test.name, test.flushed, flushed)
+		}
+		if flushed := aggregator.Flush(); len(flushed) != 0 {
+			t.Errorf("%s: expected nothing left to flush, got %+v", test.name, flushed)
+		}
+	}
+}
+
+func TestP95(t *testing.T) {
+
+	values := []int64{}
+	for i := int64(100); i > 0; i-- {
+		values = append(values, i)
+	}
+
+	if p95 := stats(values).P95; p95 != 95 {
+		t.Errorf("expected p95 95, got %d", p95)
+	}
+}
+
+func TestMerge(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		buckets  []Bucket
+		expected []Bucket
+	}{
+		{
+			name:     "empty",
+			buckets:  []Bucket{},
+			expected: []Bucket{},
+		},
+		{
+			name: "different starts and containers",
+			buckets: []Bucket{
+				{DateStamp: 120, Container: "api", Samples: 1, Cpu: Stats{1, 1, 1, 1}},
+				{DateStamp: 60, Container: "proxy", Samples: 1, Cpu: Stats{2, 2, 2, 2}},
+				{DateStamp: 60, Container: "api", Samples: 1, Cpu: Stats{3, 3, 3, 3}},
+			},
+			expected: []Bucket{
+				{DateStamp: 60, Container: "api", Samples: 1, Cpu: Stats{3, 3, 3, 3}},
+				{DateStamp: 60, Container: "proxy", Samples: 1, Cpu: Stats{2, 2, 2, 2}},
+				{DateStamp: 120, Container: "api", Samples: 1, Cpu: Stats{1, 1, 1, 1}},
+			},
+		},
+		{
+			// A bucket continued after a restart, the average is weighted by the samples of each row
+			name: "shared start",
+			buckets: []Bucket{
+				{DateStamp: 60, Container: "api", Samples: 2, Cpu: Stats{10, 30, 20, 30}, Memory: Stats{100, 300, 200, 300}},
+				{DateStamp: 60, Container: "api", Samples: 1, Cpu: Stats{40, 40, 40, 40}, Memory: Stats{50, 50, 50, 50}},
+			},
+			expected: []Bucket{
+				{DateStamp: 60, Container: "api", Samples: 3, Cpu: Stats{10, 40, 27, 40}, Memory: Stats{50, 300, 150, 300}},
+			},
+		},
+		{
+			name: "three rows",
+			buckets: []Bucket{
+				{DateStamp: 0, Container: "api", Samples: 1, Cpu: Stats{6, 6, 6, 6}},
+				{DateStamp: 0, Container: "api", Samples: 1, Cpu: Stats{3, 3, 3, 3}},
+				{DateStamp: 0, Container: "api", Samples: 2, Cpu: Stats{0, 0, 0, 0}},
+			},
+			expected: []Bucket{
+				{DateStamp: 0, Container: "api", Samples: 4, Cpu: Stats{0, 6, 2, 6}},
+			},
+		},
+		{
+			// Rows without samples don't move the average
+			name: "no samples",
+			buckets: []Bucket{
+				{DateStamp: 0, Container: "api", Samples: 0},
+				{DateStamp: 0, Container: "api", Samples: 0},
+			},
+			expected: []Bucket{
+				{DateStamp: 0, Container: "api", Samples: 0},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		if merged := Merge(test.buckets); !reflect.DeepEqual(merged, test.expected) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.expected, merged)
+		}
+	}
+}
+
+func TestSelectTier(t *testing.T) {
+
+	now := time.Unix(1700000000, 0)
+	ago := func(duration time.Duration) time.Time {
+		return now.Add(-duration)
+	}
+
+	tests := []struct {
+		name      string
+		from, to  time.Time
+		retention time.Duration
+		expected  string
+	}{
+		{"raw", ago(1000 * time.Second), now, 0, RawTier},
+		{"last raw point", ago(100 * 10 * time.Second), now, 0, RawTier},
+		{"too many raw points", ago(101 * 10 * time.Second), now, 0, "1m"},
+		{"within the retention", ago(30 * time.Minute), ago(20 * time.Minute), time.Hour, RawTier},
+		{"older than the retention", ago(2 * time.Hour), ago(119 * time.Minute), time.Hour, "1m"},
+		{"last minute point", ago(100 * time.Minute), now, 0, "1m"},
+		{"too many minute points", ago(101 * time.Minute), now, 0, "5m"},
+		{"last five minute point", ago(500 * time.Minute), now, 0, "5m"},
+		{"too many five minute points", ago(505 * time.Minute), now, 0, "1h"},
+		{"beyond the coarsest tier", ago(1000 * time.Hour), now, 0, "1h"},
+	}
+
+	for _, test := range tests {
+		if tier := SelectTier(test.from, test.to, now, 10*time.Second, test.retention, 100); tier != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.name, test.expected, tier)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		rows     [][]string
+		cutoff   int64
+		expected [][]string
+	}{
+		{
+			name:     "empty",
+			rows:     [][]string{},
+			cutoff:   100,
+			expected: [][]string{},
+		},
+		{
+			name:     "nothing to prune",
+			rows:     [][]string{{"time", "name", "cpu", "memory"}, {"100", "api", "1", "10"}},
+			cutoff:   100,
+			expected: [][]string{{"time", "name", "cpu", "memory"}, {"100", "api", "1", "10"}},
+		},
+		{
+			name:     "pruned",
+			rows:     [][]string{{"time", "name", "cpu", "memory"}, {"90", "api", "1", "10"}, {"100", "api", "2", "20"}, {"110", "api", "3", "30"}},
+			cutoff:   100,
+			expected: [][]string{{"time", "name", "cpu", "memory"}, {"100", "api", "2", "20"}, {"110", "api", "3", "30"}},
+		},
+		{
+			name:     "everything pruned",
+			rows:     [][]string{{"time", "name", "cpu", "memory"}, {"90", "api", "1", "10"}},
+			cutoff:   100,
+			expected: [][]string{{"time", "name", "cpu", "memory"}},
+		},
+		{
+			// The rows written since the header gained a column are longer than the header
+			name:     "older header",
+			rows:     [][]string{{"time", "name", "cpu", "memory"}, {"90", "api", "1", "10"}, {"100", "api", "2", "20", "sidecar"}},
+			cutoff:   100,
+			expected: [][]string{{"time", "name", "cpu", "memory"}, {"100", "api", "2", "20", "sidecar"}},
+		},
+		{
+			// A row that can't be parsed is kept rather than lost
+			name:     "unparsable time",
+			rows:     [][]string{{"time", "name", "cpu", "memory"}, {"", "api", "1", "10"}, {"90", "api", "1", "10"}},
+			cutoff:   100,
+			expected: [][]string{{"time", "name", "cpu", "memory"}, {"", "api", "1", "10"}},
+		},
+	}
+
+	for _, test := range tests {
+		filename := path.Join(t.TempDir(), "pod.usage.csv")
+
+		file, err := os.Create(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		csv.NewWriter(file).WriteAll(test.rows)
+		file.Close()
+
+		if err := Prune(filename, test.cutoff); err != nil {
+			t.Errorf("%s: %s", test.name, err.Error())
+			continue
+		}
+
+		file, err = os.Open(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader := csv.NewReader(file)
+		reader.FieldsPerRecord = -1
+		rows, err := reader.ReadAll()
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(rows) != 0 || len(test.expected) != 0 {
+			if !reflect.DeepEqual(rows, test.expected) {
+				t.Errorf("%s: expected %v, got %v", test.name, test.expected, rows)
+			}
+		}
+		if _, err := os.Stat(filename + ".tmp"); !os.IsNotExist(err) {
+			t.Errorf("%s: expected the temporary file to be removed", test.name)
+		}
+	}
+
+	if err := Prune(path.Join(t.TempDir(), "missing.csv"), 100); !os.IsNotExist(err) {
+		t.Errorf("expected a missing file error, got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"pod_profiler/pkg/api/logging"
+	"time"
+)
+
+// Server is the HTTP API of the gatherer. Each package that exposes data registers its own handlers
+type Server struct {
+	mux    *http.ServeMux
+	server *http.Server
+}
+
+// Create a new server that listens on the given port once started
+func New(port int) *Server {
+
+	mux := http.NewServeMux()
+
+	return &Server{
+		mux: mux,
+		server: &http.Server{
+			Addr:              fmt.Sprintf(":%d", port),
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+	}
+}
+
+// Registers the handler for the given pattern, patterns use the http.ServeMux syntax such as "GET /api/v1/config"
+func (server *Server) Handle(pattern string, handler http.HandlerFunc) {
+	server.mux.HandleFunc(pattern, handler)
+}
+
+// Starts serving requests, this blocks until the server is closed
+func (server *Server) Start() error {
+
+	logging.Info().Printf("Starting API server on %s\n", server.server.Addr)
+
+	err := server.server.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// Stops serving requests
+func (server *Server) Close() error {
+	return server.server.Close()
+}
+
+// Writes the value as a JSON response with the given status code
+func WriteJSON(w http.ResponseWriter, status int, value interface{}) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(value)
+	if err != nil {
+		logging.Error().Printf("error: %s\n", err.Error())
+	}
+}
+
+// Writes the error as a JSON response with the given status code
+func WriteError(w http.ResponseWriter, status int, err error) {
+	WriteJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,193 @@
+package shard
+
+import (
+	"context"
+	"pod_profiler/pkg/api/defaults"
+	"pod_profiler/pkg/api/logging"
+	"reflect"
+	"sort"
+	"time"
+
+	v1Coordination "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1Meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Membership keeps a lease for this gatherer and watches the leases of the others in its group. A member whose lease
+// hasn't been renewed within the lease duration has stopped and its share of the pods moves to the others
+type Membership struct {
+	client    kubernetes.Interface
+	namespace string
+	group     string
+	identity  string
+
+	// Called with the identities of the live members in order whenever they change. The members are empty while this
+	// gatherer can't renew its own lease, so it stops capturing rather than overlapping with the others
+	OnChange func(members []string)
+
+	members []string
+	renewed time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Creates the membership of the gatherer with the given identity in the group, whose leases are named after the group
+func NewMembership(client kubernetes.Interface, namespace, group, identity string) *Membership {
+	return &Membership{
+		client:    client,
+		namespace: namespace,
+		group:     group,
+		identity:  identity,
+	}
+}
+
+// Returns the name of the lease of this gatherer
+func (membership *Membership) leaseName() string {
+	return membership.group + "-" + membership.identity
+}
+
+// Renews the lease and checks the members every retry period until Leave is called
+func (membership *Membership) Join() {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	membership.cancel = cancel
+	membership.done = make(chan struct{})
+
+	go func() {
+		defer close(membership.done)
+
+		ticker := time.NewTicker(defaults.LEASE_RETRY_PERIOD)
+		defer ticker.Stop()
+
+		for {
+			membership.refresh(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stops renewing the lease and deletes it, so the others take over this gatherer's pods without waiting for it to expire
+func (membership *Membership) Leave() {
+
+	if membership.cancel == nil {
+		return
+	}
+	membership.cancel()
+	<-membership.done
+
+	err := membership.client.CoordinationV1().Leases(membership.namespace).Delete(context.Background(), membership.leaseName(), v1Meta.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		logging.Error().Printf("error: %s\n", err.Error())
+	}
+}
+
+// Renews our lease, lists the leases of the group and calls OnChange if the live members changed
+func (membership *Membership) refresh(ctx context.Context) {
+
+	now := time.Now()
+
+	if err := membership.renew(ctx, now); err != nil {
+		logging.Error().Printf("error: %s\n", err.Error())
+	} else {
+		membership.renewed = now
+	}
+
+	members, err := membership.list(ctx, now)
+	if err != nil {
+		logging.Error().Printf("error: %s\n", err.Error())
+		members = membership.members
+	}
+
+	// The others will have taken over our pods once our lease has expired
+	if now.Sub(membership.renewed) > defaults.LEASE_DURATION {
+		members = nil
+	}
+
+	if ctx.Err() != nil || reflect.DeepEqual(members, membership.members) {
+		return
+	}
+	membership.members = members
+
+	logging.Info().Printf("Shards of %s: %v\n", membership.group, members)
+	if membership.OnChange != nil {
+		membership.OnChange(members)
+	}
+}
+
+// Creates our lease if it doesn't exist and otherwise updates its renew time
+func (membership *Membership) renew(ctx context.Context, now time.Time) error {
+
+	leases := membership.client.CoordinationV1().Leases(membership.namespace)
+	renewTime := v1Meta.NewMicroTime(now)
+
+	lease, err := leases.Get(ctx, membership.leaseName(), v1Meta.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		duration := int32(defaults.LEASE_DURATION.Seconds())
+		_, err = leases.Create(ctx, &v1Coordination.Lease{
+			ObjectMeta: v1Meta.ObjectMeta{
+				Name:      membership.leaseName(),
+				Namespace: membership.namespace,
+				Labels:    map[string]string{defaults.SHARD_GROUP_LABEL: membership.group},
+			},
+			Spec: v1Coordination.LeaseSpec{
+				HolderIdentity:       &membership.identity,
+				LeaseDurationSeconds: &duration,
+				AcquireTime:          &renewTime,
+				RenewTime:            &renewTime,
+			},
+		}, v1Meta.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	lease.Spec.RenewTime = &renewTime
+	_, err = leases.Update(ctx, lease, v1Meta.UpdateOptions{})
+	return err
+}
+
+// Returns the identities of the members whose leases are live in order, and deletes the leases of members that stopped
+// without leaving, such as those whose node failed
+func (membership *Membership) list(ctx context.Context, now time.Time) ([]string, error) {
+
+	leases := membership.client.CoordinationV1().Leases(membership.namespace)
+
+	list, err := leases.List(ctx, v1Meta.ListOptions{LabelSelector: defaults.SHARD_GROUP_LABEL + "=" + membership.group})
+	if err != nil {
+		return nil, err
+	}
+
+	members := []string{}
+	for _, lease := range list.Items {
+		if lease.Spec.HolderIdentity == nil || lease.Spec.RenewTime == nil {
+			continue
+		}
+
+		duration := defaults.LEASE_DURATION
+		if lease.Spec.LeaseDurationSeconds != nil {
+			duration = time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
+		}
+
+		if expiry := lease.Spec.RenewTime.Add(duration); now.Before(expiry) {
+			members = append(members, *lease.Spec.HolderIdentity)
+		} else if now.Sub(expiry) > duration {
+
+			// Give the member another lease duration to come back before removing its lease
+			err := leases.Delete(ctx, lease.Name, v1Meta.DeleteOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				logging.Error().Printf("error: %s\n", err.Error())
+			}
+		}
+	}
+	sort.Strings(members)
+
+	return members, nil
+}
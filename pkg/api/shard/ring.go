@@ -0,0 +1,79 @@
+package shard
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"pod_profiler/pkg/api/defaults"
+	"sort"
+	"strconv"
+)
+
+// A position on the ring and the member it belongs to
+type point struct {
+	hash   uint64
+	member string
+}
+
+// Ring is a consistent hash ring of the gatherers sharing the capture. A key belongs to the first member clockwise
+// from its hash, so when a member joins or leaves only the keys next to its points move
+type Ring struct {
+	members []string
+	points  []point
+}
+
+// Creates a ring of the members, each placed on it SHARD_VIRTUAL_NODES times so the keys are spread evenly
+func NewRing(members []string) *Ring {
+
+	ring := &Ring{members: append([]string{}, members...)}
+	sort.Strings(ring.members)
+
+	for _, member := range ring.members {
+		for i := 0; i < defaults.SHARD_VIRTUAL_NODES; i++ {
+			ring.points = append(ring.points, point{hash(member + "#" + strconv.Itoa(i)), member})
+		}
+	}
+
+	// Ties are broken by member so every gatherer builds the same ring
+	sort.Slice(ring.points, func(i, j int) bool {
+		if ring.points[i].hash == ring.points[j].hash {
+			return ring.points[i].member < ring.points[j].member
+		}
+		return ring.points[i].hash < ring.points[j].hash
+	})
+
+	return ring
+}
+
+// Returns the member the key belongs to, or an empty string if the ring has no members
+func (ring *Ring) Owner(key string) string {
+
+	if len(ring.points) == 0 {
+		return ""
+	}
+
+	keyHash := hash(key)
+	i := sort.Search(len(ring.points), func(i int) bool {
+		return ring.points[i].hash >= keyHash
+	})
+	if i == len(ring.points) {
+		i = 0
+	}
+
+	return ring.points[i].member
+}
+
+// Returns the members of the ring in order
+func (ring *Ring) Members() []string {
+	return append([]string{}, ring.members...)
+}
+
+// Returns true if the member is on the ring
+func (ring *Ring) Contains(member string) bool {
+	i := sort.SearchStrings(ring.members, member)
+	return i < len(ring.members) && ring.members[i] == member
+}
+
+func hash(key string) uint64 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
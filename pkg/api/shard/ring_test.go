@@ -0,0 +1,133 @@
+package shard
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// Returns the keys of the pods the ring is tested with
+func keys(count int) []string {
+	result := []string{}
+	for i := 0; i < count; i++ {
+		result = append(result, fmt.Sprintf("3f1c0a2e-1a2b-4c5d-8e9f-%012d", i))
+	}
+	return result
+}
+
+func TestRingEmpty(t *testing.T) {
+
+	ring := NewRing(nil)
+
+	if owner := ring.Owner("pod"); owner != "" {
+		t.Errorf("expected no owner, got %s", owner)
+	}
+	if ring.Contains("gatherer-0") {
+		t.Errorf("expected no members")
+	}
+}
+
+func TestRingMembers(t *testing.T) {
+
+	members := []string{"gatherer-2", "gatherer-0", "gatherer-1"}
+	ring := NewRing(members)
+
+	if expected := []string{"gatherer-0", "gatherer-1", "gatherer-2"}; !reflect.DeepEqual(ring.Members(), expected) {
+		t.Errorf("expected %v, got %v", expected, ring.Members())
+	}
+	if members[0] != "gatherer-2" {
+		t.Errorf("expected the members passed in to be left in their order, got %v", members)
+	}
+	for _, member := range members {
+		if !ring.Contains(member) {
+			t.Errorf("expected %s to be on the ring", member)
+		}
+	}
+	if ring.Contains("gatherer-3") {
+		t.Errorf("expected gatherer-3 not to be on the ring")
+	}
+}
+
+// Every gatherer builds the ring from the members it lists, so the order they are listed in can't change the owners
+func TestRingOrder(t *testing.T) {
+
+	ring := NewRing([]string{"gatherer-0", "gatherer-1", "gatherer-2"})
+	reversed := NewRing([]string{"gatherer-2", "gatherer-1", "gatherer-0"})
+
+	for _, key := range keys(1000) {
+		if ring.Owner(key) != reversed.Owner(key) {
+			t.Fatalf("%s: expected the same owner, got %s and %s", key, ring.Owner(key), reversed.Owner(key))
+		}
+	}
+}
+
+// Each pod is polled by exactly one of the gatherers, and each gatherer gets a share of them
+func TestRingSingleOwner(t *testing.T) {
+
+	members := []string{"gatherer-0", "gatherer-1", "gatherer-2", "gatherer-3"}
+
+	// Each gatherer builds its own ring
+	rings := map[string]*Ring{}
+	for _, member := range members {
+		rings[member] = NewRing(members)
+	}
+
+	owned := map[string]int{}
+	for _, key := range keys(4000) {
+		owners := []string{}
+		for member, ring := range rings {
+			if ring.Owner(key) == member {
+				owners = append(owners, member)
+			}
+		}
+		if len(owners) != 1 {
+			t.Errorf("%s: expected one owner, got %v", key, owners)
+			continue
+		}
+		owned[owners[0]]++
+	}
+
+	// The virtual nodes keep the shares close to even
+	for _, member := range members {
+		if owned[member] < 700 || owned[member] > 1300 {
+			t.Errorf("%s: expected about 1000 of the 4000 keys, got %d", member, owned[member])
+		}
+	}
+}
+
+// Only the keys of a member that leaves, or that a member joining takes over, change owner
+func TestRingStability(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		before, after []string
+	}{
+		{"join", []string{"gatherer-0", "gatherer-1", "gatherer-2"}, []string{"gatherer-0", "gatherer-1", "gatherer-2", "gatherer-3"}},
+		{"leave", []string{"gatherer-0", "gatherer-1", "gatherer-2"}, []string{"gatherer-0", "gatherer-2"}},
+		{"replace", []string{"gatherer-0", "gatherer-1", "gatherer-2"}, []string{"gatherer-0", "gatherer-1", "gatherer-3"}},
+		{"first", []string{"gatherer-0"}, []string{"gatherer-0", "gatherer-1"}},
+		{"last", []string{"gatherer-0", "gatherer-1"}, []string{"gatherer-1"}},
+	}
+
+	for _, test := range tests {
+		before, after := NewRing(test.before), NewRing(test.after)
+
+		moved := 0
+		for _, key := range keys(2000) {
+			oldOwner, newOwner := before.Owner(key), after.Owner(key)
+			if oldOwner == newOwner {
+				continue
+			}
+			moved++
+
+			// A key only moves away from a member that left, or to a member that joined
+			if after.Contains(oldOwner) && before.Contains(newOwner) {
+				t.Errorf("%s: %s moved from %s to %s, which are both still members", test.name, key, oldOwner, newOwner)
+			}
+		}
+
+		if moved == 0 {
+			t.Errorf("%s: expected some keys to move", test.name)
+		}
+	}
+}
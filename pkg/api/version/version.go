@@ -0,0 +1,14 @@
+package version
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// The version of the build, release builds set this with -ldflags "-X pod_profiler/pkg/api/version.Version=1.2.3"
+var Version string = "devel"
+
+// Returns the version line printed by the version subcommands of our binaries
+func String(binary string) string {
+	return fmt.Sprintf("%s %s (%s %s/%s)", binary, Version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}